@@ -3,24 +3,82 @@ package cli
 import (
 	"fitness/data"
 	"fitness/printer"
+	"fitness/semantics"
+	"flag"
 	"fmt"
 	"os"
+	"time"
 )
 
 // Start the command line interface
 func StartCLI() {
 	fmt.Println()
 
-	// Parse command line flags
+	// Parse command line flags and build the effective configuration
 	flags := ParseFlags()
-	opts := CreatePrintOptions(flags)
+	cfg, err := LoadConfig(&flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Run a subcommand if one was given
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "config": // Inspect the configuration
+			err = RunConfigCommand(cfg, args[1:])
+		case "import": // Ingest export files or report their status
+			err = RunImportCommand(cfg, args[1:])
+		case "watch": // Ingest new export files continuously
+			err = RunWatchCommand(cfg, args[1:])
+		case "stats": // Group workouts and summarize each group
+			err = RunStatsCommand(cfg, flags, args[1:])
+		case "workout": // Show a single workout in detail
+			err = RunWorkoutCommand(cfg, flags, args[1:])
+		default: // Unknown subcommand
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", args[0])
+			flag.Usage()
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Build the print options and date range first so invalid flags fail before loading any data
+	opts, err := CreatePrintOptions(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	span, err := CreateTimeRange(flags, opts.Week, time.Now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Describe the metrics, the config file adds to the built-in descriptions
+	opts.Metrics, err = semantics.Builtin.Extend(cfg.Metrics)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Import data from cache and cloud drive
+	store, err := data.ImportData(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	var err error
 	switch flags.DataType {
 	case "workouts": // Print workout data
-		err = printer.PrintHealthData(data.AllWorkouts, opts)
+		err = printer.PrintHealthData(store.Workouts(span), opts)
 	case "metrics": // Print metric data
-		err = printer.PrintHealthData(data.AllMetrics, opts)
+		err = printer.PrintHealthData(store.Metrics(span), opts)
 	default: // Invalid data type
 		fmt.Fprintf(os.Stderr, "Invalid data type: %s\n", flags.DataType)
 		os.Exit(1)
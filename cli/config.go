@@ -0,0 +1,58 @@
+// cli/config.go
+package cli
+
+import (
+	"fitness/config"
+	"fitness/semantics"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RunConfigCommand handles the `fitness config` subcommands
+func RunConfigCommand(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] == "show" {
+		PrintConfig(cfg)
+		return nil
+	}
+	return fmt.Errorf("unknown config command: %s (expected: show)", args[0])
+}
+
+// PrintConfig prints every effective setting along with where it came from
+func PrintConfig(cfg *config.Config) {
+	fmt.Printf("Config file: %s\n", cfg.Path)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-12s %-8s %s\n", "Setting", "Source", "Value")
+	for _, entry := range cfg.Entries() {
+		value := entry.Value
+		if value == "" {
+			value = "-"
+		}
+		fmt.Printf("%-12s %-8s %s\n", entry.Key, entry.Source, value)
+	}
+
+	// Metrics described in the config file, as they are after merging with the built-in descriptions
+	if len(cfg.Metrics) == 0 {
+		return
+	}
+	registry, err := semantics.Builtin.Extend(cfg.Metrics)
+	if err != nil {
+		fmt.Printf("\nInvalid metrics: %v\n", err)
+		return
+	}
+	fmt.Println()
+	fmt.Printf("%-30s %-26s %-11s %s\n", "Metric", "Name", "Aggregation", "Units and range")
+	names := make([]string, 0, len(cfg.Metrics))
+	for name := range cfg.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := registry.Info(name)
+		units := info.Range()
+		if units == "" {
+			units = info.Units
+		}
+		fmt.Printf("%-30s %-26s %-11s %s\n", name, info.Display, info.Aggregation, units)
+	}
+}
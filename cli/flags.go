@@ -3,52 +3,86 @@ package cli
 
 import (
 	"fitness/config"
+	"fitness/data"
+	"fitness/fields"
 	"fitness/models"
 	"fitness/printer"
+	"fitness/quantity"
+	"fitness/query"
+	"fitness/utils"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 // CLIFlags stores all command-line flags that can be passed to the application
 type CLIFlags struct {
-	MaxItems           int    // Maximum number of items to display (0 means show all)
-	Compact            bool   // Whether to use compact display mode
-	TimeFormat         string // Format string for displaying timestamps
-	FilterType         string // Type of filter to apply (name, distance, etc.)
-	FilterValue        string // Value to filter by
-	SortBy             string // Field to sort results by
-	SortDesc           bool   // Whether to sort in descending order
-	DataType           string // Type of data to display (workouts/metrics)
-	Include            string // Comma-separated list of fields to include
-	Exclude            string // Comma-separated list of fields to exclude
-	WorkoutsPerMonth   bool   // Whether to show total workouts per month
-	DistancePerWorkout bool   // Whether to show distance per workout
-	DistancePerWeek    bool   // Whether to show total distance per week
-	EnergyPerWeek      bool   // Whether to show total energy per week
+	MaxItems           int      // Maximum number of items to display (0 means show all)
+	Compact            bool     // Whether to use compact display mode
+	TimeFormat         string   // Format string for displaying timestamps
+	FilterType         string   // Type of filter to apply (name, distance, etc.)
+	FilterValue        string   // Value to filter by
+	Filters            listFlag // Conditions every displayed workout must satisfy, e.g. distance>5km
+	Since              string   // First date to select, absolute or relative such as 7d
+	Until              string   // Last date to select, absolute or relative such as last-month
+	Period             string   // Date range to select, such as this-month or 2024-Q3
+	Where              string   // Filter expression such as name ~ "run" && distance > 5km
+	Metrics            string   // Comma-separated metric names or globs to show, e.g. heart_rate*
+	SortBy             string   // Field to sort results by
+	SortDesc           bool     // Whether to sort in descending order
+	DataType           string   // Type of data to display (workouts/metrics)
+	Include            string   // Comma-separated list of fields to include
+	Exclude            string   // Comma-separated list of fields to exclude
+	WorkoutsPerMonth   bool     // Whether to show total workouts per month
+	DistancePerWorkout bool     // Whether to show distance per workout
+	DistancePerWeek    bool     // Whether to show total distance per week
+	EnergyPerWeek      bool     // Whether to show total energy per week
+	ReportsOnly        bool     // Whether to show only the reports, without the listing
+	ConfigPath         string   // Path to the config file
+	ICloudDir          string   // Directory containing Health Auto Export files
+	CacheFile          string   // Location of the local cache file
+	SeriesFile         string   // Location of the side file for workout series
+	Units              string   // Unit system for display (metric or imperial)
+	Timezone           string   // Time zone policy for display and bucketing
+	WeekStart          string   // First day of the week for weekly totals, or iso
+	MergePolicy        string   // Conflict policy when merging duplicate records
 }
 
+// listFlag is a flag that can be given several times, collecting every value
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ", ") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
 // ParseFlags sets up and processes all command-line flags
 // Returns: A CLIFlags struct containing all parsed flag values
 func ParseFlags() CLIFlags {
 	flags := CLIFlags{}
+	defaults := config.Default()
 
 	// Define basic display flags
 	flag.IntVar(&flags.MaxItems, "n", 0, "Maximum number of items to display (0 for all)")
 	flag.BoolVar(&flags.Compact, "c", false, "Use compact display mode")
 
 	// Define filtering flags
-	flag.StringVar(&flags.TimeFormat, "time-format", config.TimeFormat, "Time format string")
-	flag.StringVar(&flags.FilterType, "f", "", "Filter type (name, distance, duration, energy)")
+	flag.StringVar(&flags.TimeFormat, "time-format", defaults.TimeFormat, "Time format string")
+	flag.StringVar(&flags.FilterType, "f", "", "Filter field for -value, any field -filter accepts")
 	flag.StringVar(&flags.FilterValue, "value", "", "Filter value")
+	flag.StringVar(&flags.Since, "since", "", "Only select data from this date on (e.g. 2024-07-01, 7d, last-week)")
+	flag.StringVar(&flags.Until, "until", "", "Only select data up to and including this date (e.g. 2024-07-31, yesterday)")
+	flag.StringVar(&flags.Period, "period", "", "Only select data in this period (e.g. this-month, ytd, 2024-Q3)")
+	flag.StringVar(&flags.Metrics, "metric", "", "Show only these metrics, comma-separated names or globs (e.g. heart_rate*,step_count)")
+	flag.StringVar(&flags.Where, "where", "", "Filter expression, e.g. 'name ~ \"run\" && (distance > 5km || duration > 1h)'")
+	flag.Var(&flags.Filters, "filter", "Filter condition such as distance>5km or duration=30m..1h (repeatable, all must match)")
 
 	// Define sorting flags
-	flag.StringVar(&flags.SortBy, "sort", "", "Sort by field (name, date, duration, distance, energy)")
+	flag.StringVar(&flags.SortBy, "sort", "", "Sort by comma-separated fields, prefix - for descending (e.g. name,-distance)")
 	flag.BoolVar(&flags.SortDesc, "desc", false, "Sort in descending order")
 
 	// Define data selection flags
-	flag.StringVar(&flags.DataType, "type", "workouts", "Data type to display (workouts or metrics)")
+	flag.StringVar(&flags.DataType, "type", defaults.DataType, "Data type to display (workouts or metrics)")
 
 	// Define field selection flags
 	flag.StringVar(&flags.Include, "i", "", "Include only specific fields (comma-separated)")
@@ -59,18 +93,40 @@ func ParseFlags() CLIFlags {
 	flag.BoolVar(&flags.DistancePerWorkout, "distance-per-workout", false, "Show distance per workout")
 	flag.BoolVar(&flags.DistancePerWeek, "distance-per-week", false, "Show total distance per week")
 	flag.BoolVar(&flags.EnergyPerWeek, "energy-per-week", false, "Show total energy burned per week")
+	flag.BoolVar(&flags.ReportsOnly, "reports-only", false, "Show only the reports of -workouts-per-month and the like, without the listing")
+
+	// Define configuration flags, these override the config file and environment
+	flag.StringVar(&flags.ConfigPath, "config", "", "Path to the config file (default ~/.config/fitness/config.json)")
+	flag.StringVar(&flags.ICloudDir, "icloud-dir", defaults.ICloudDirPath, "Directory containing Health Auto Export files")
+	flag.StringVar(&flags.CacheFile, "cache-file", defaults.CacheFilePath, "Location of the local cache file")
+	flag.StringVar(&flags.SeriesFile, "series-file", defaults.SeriesFilePath, "Keep workout routes and heart rate, step and elevation series in this file instead of the cache")
+	flag.StringVar(&flags.Units, "units", defaults.Units, "Unit system for display (metric or imperial)")
+	flag.StringVar(&flags.Timezone, "timezone", defaults.Timezone, "Time zone for dates and weeks (original, utc, local or a zone name)")
+	flag.StringVar(&flags.WeekStart, "week-start", defaults.WeekStart, "First day of the week for weekly totals (a weekday, or iso for ISO weeks)")
+	flag.StringVar(&flags.MergePolicy, "merge-policy", defaults.MergePolicy, "Conflict policy for duplicate records (newest, max or first)")
 
 	// Set up custom usage message with examples
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of Health Fitness Data Printer:\n")
-		fmt.Fprintf(os.Stderr, "  fitness [options]\n\n")
+		fmt.Fprintf(os.Stderr, "  fitness [options]\n")
+		fmt.Fprintf(os.Stderr, "  fitness [options] config show\n")
+		fmt.Fprintf(os.Stderr, "  fitness [options] import [--status] [--apple-export export.zip] [--routes path]\n")
+		fmt.Fprintf(os.Stderr, "  fitness [options] watch [--interval 30s] [--settle 10s]\n")
+		fmt.Fprintf(os.Stderr, "  fitness [options] stats [--by name,month] [--measure distance:sum,duration:mean]\n")
+		fmt.Fprintf(os.Stderr, "  fitness [options] workout show <id>\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  fitness -n 10 -c                    # Show 10 items in compact mode\n")
-		fmt.Fprintf(os.Stderr, "  fitness -f name -v \"Pool Swim\"      # Show only Pool Swim workouts\n")
+		fmt.Fprintf(os.Stderr, "  fitness -f name -value \"Pool Swim\"  # Show only Pool Swim workouts\n")
+		fmt.Fprintf(os.Stderr, "  fitness -filter \"distance>5km\"      # Show workouts longer than 5 km\n")
+		fmt.Fprintf(os.Stderr, "  fitness -period last-month          # Show last month's workouts\n")
+		fmt.Fprintf(os.Stderr, "  fitness -where \"hour < 7\"           # Show workouts started before 7am\n")
+		fmt.Fprintf(os.Stderr, "  fitness -type metrics -since 7d -c  # List metrics with data in the last week\n")
 		fmt.Fprintf(os.Stderr, "  fitness -sort duration -desc        # Sort by duration descending\n")
+		fmt.Fprintf(os.Stderr, "  fitness -sort name,-distance        # Sort by name, then longest distance first\n")
 		fmt.Fprintf(os.Stderr, "  fitness -i \"name,duration,distance\" # Show only specific fields\n")
+		fmt.Fprintf(os.Stderr, "  fitness -units metric config show   # Show effective settings and their sources\n")
 		fmt.Println()
 	}
 
@@ -79,50 +135,90 @@ func ParseFlags() CLIFlags {
 	return flags
 }
 
-// CreateFilterFunction creates a filter function based on the provided flags
-// Returns: A FilterFunc that returns true if an item should be included in the output
-func CreateFilterFunction(flags CLIFlags) printer.FilterFunc {
-	// If no filter type or value is specified, return nil (no filtering)
-	if flags.FilterType == "" || flags.FilterValue == "" {
+// LoadConfig builds the effective configuration from the config file, environment and flags
+// Values taken from the config are copied back onto the flags so the rest of the CLI sees them
+func LoadConfig(flags *CLIFlags) (*config.Config, error) {
+	cfg, err := config.Load(flags.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Flags explicitly set on the command line take precedence over everything else
+	if err := cfg.ApplyFlags(flag.CommandLine); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Sync the effective settings back onto the flags
+	flags.ICloudDir = cfg.ICloudDirPath
+	flags.CacheFile = cfg.CacheFilePath
+	flags.SeriesFile = cfg.SeriesFilePath
+	flags.DataType = cfg.DataType
+	flags.TimeFormat = cfg.TimeFormat
+	flags.Units = cfg.Units
+	flags.Timezone = cfg.Timezone
+	flags.WeekStart = cfg.WeekStart
+	flags.SortBy = cfg.SortBy
+	flags.MergePolicy = cfg.MergePolicy
+
+	return cfg, nil
+}
+
+// ParseConditions reads the -filter conditions and the -f/-value shorthand
+func ParseConditions(flags CLIFlags, system quantity.System, zone models.Zone) ([]data.Condition, error) {
+	var conditions []data.Condition
+	for _, spec := range flags.Filters {
+		condition, err := data.ParseCondition(spec, system, zone)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	if flags.FilterType != "" && flags.FilterValue != "" {
+		condition, err := data.ShorthandCondition(flags.FilterType, flags.FilterValue, system, zone)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// CreateFilterFunction creates a filter function from the conditions and the -metric patterns
+// Returns: A FilterFunc that returns true if an item should be included in the output, nil if there is nothing to filter
+func CreateFilterFunction(conditions []data.Condition, patterns []string) printer.FilterFunc {
+	// If no conditions or patterns are specified, return nil (no filtering)
+	if len(conditions) == 0 && len(patterns) == 0 {
 		return nil
 	}
 
-	// Return a function that filters Workout objects based on the specified criteria
-	// `ok` will be true if the cast is successful
-	// `w` will contain the Workout object if the cast is successful
-	// `value` is the interface{} type that we are trying to cast
 	return func(value interface{}) bool {
-		// Try to cast the interface to a Workout type
-		if workout, ok := value.(models.Workout); ok {
-			switch flags.FilterType {
-			case "name":
-				// Case-insensitive substring match for workout names
-				return strings.Contains(strings.ToLower(workout.Name),
-					strings.ToLower(flags.FilterValue))
-			case "distance":
-				// Match exact distance value if available
-				if workout.Distance != nil {
-					val := workout.Distance.Qty
-					return fmt.Sprintf("%.1f", val) == flags.FilterValue
-				}
-			case "duration":
-				// Match exact duration value
-				return fmt.Sprintf("%.1f", workout.Duration) == flags.FilterValue
-			case "energy":
-				// Match exact energy value if available
-				if workout.ActiveEnergyBurned != nil {
-					val := workout.ActiveEnergyBurned.Qty
-					return fmt.Sprintf("%.1f", val) == flags.FilterValue
-				}
-			}
+		switch v := value.(type) {
+		case models.Workout:
+			return data.MatchAll(conditions, v)
+		case models.Metric:
+			return data.MatchMetricName(patterns, v.Name) && data.MatchAllMetric(conditions, v)
 		}
 		return false
 	}
 }
 
+// CreateTimeRange resolves the -since, -until and -period flags against the clock
+// Days and weeks follow the zone and week start of the print options' weekly buckets
+func CreateTimeRange(flags CLIFlags, week utils.Bucketer, now func() time.Time) (data.TimeRange, error) {
+	resolver := utils.DateResolver{Now: now, Week: week}
+	from, to, err := resolver.Range(flags.Since, flags.Until, flags.Period)
+	if err != nil {
+		return data.AllTime, err
+	}
+	return data.TimeRange{From: from, To: to, Zone: week.Zone}, nil
+}
+
 // CreatePrintOptions creates a PrintOptions struct based on the provided flags
 // Returns: A PrintOptions struct configured according to the command-line flags
-func CreatePrintOptions(flags CLIFlags) printer.PrintOptions {
+func CreatePrintOptions(flags CLIFlags) (printer.PrintOptions, error) {
 	// Start with default print options
 	opts := printer.DefaultPrintOptions()
 
@@ -130,14 +226,69 @@ func CreatePrintOptions(flags CLIFlags) printer.PrintOptions {
 	opts.TimeFormat = flags.TimeFormat
 	opts.MaxItems = flags.MaxItems
 	opts.Compact = flags.Compact
-	opts.Filter = CreateFilterFunction(flags)
+	opts.SortBy = flags.SortBy
 	opts.SortDesc = flags.SortDesc
+	if units, err := quantity.ParseSystem(flags.Units); err == nil {
+		opts.Units = units
+	}
+	if zone, err := models.ParseZone(flags.Timezone); err == nil {
+		opts.Zone = zone
+	}
+	if week, err := utils.ParseWeekStart(flags.WeekStart); err == nil {
+		opts.Week = week
+	}
+	opts.Week.Zone = opts.Zone
+
+	// Reject unknown filter and sort fields before any data is loaded
+	conditions, err := ParseConditions(flags, opts.Units, opts.Zone)
+	if err != nil {
+		return opts, err
+	}
+	if flags.DataType != "metrics" {
+		if err := data.CheckWorkoutFields(conditions); err != nil {
+			return opts, err
+		}
+	}
+	patterns, err := data.ParseMetricPatterns(flags.Metrics)
+	if err != nil {
+		return opts, err
+	}
+	filter := CreateFilterFunction(conditions, patterns)
+	opts.Filter = filter
+	if len(conditions) > 0 {
+		// Metrics keep only the data points that satisfy the conditions
+		opts.PointFilter = func(m models.Metric, d models.MetricData) bool {
+			return data.MatchAllPoints(conditions, m, d)
+		}
+	}
+	if flags.Where != "" {
+		where, err := query.Compile(flags.Where, query.Env{Units: opts.Units, Zone: opts.Zone, Dates: utils.DateResolver{Week: opts.Week}})
+		if err != nil {
+			return opts, fmt.Errorf("invalid -where expression: %v", err)
+		}
+		// Both the conditions and the expression must match, metrics keep only the matching data points
+		opts.Filter = func(value interface{}) bool {
+			return (filter == nil || filter(value)) && where.Match(value)
+		}
+		pointFilter := opts.PointFilter
+		opts.PointFilter = func(m models.Metric, d models.MetricData) bool {
+			return (pointFilter == nil || pointFilter(m, d)) && where.MatchPoint(m, d)
+		}
+	}
+	if _, err := printer.ParseSortKeys(flags.SortBy, flags.SortDesc); err != nil {
+		return opts, err
+	}
 
 	// Apply custom display options
 	opts.WorkoutsPerMonth = flags.WorkoutsPerMonth
 	opts.DistancePerWorkout = flags.DistancePerWorkout
 	opts.DistancePerWeek = flags.DistancePerWeek
 	opts.EnergyPerWeek = flags.EnergyPerWeek
+	opts.ReportsOnly = flags.ReportsOnly
+	reports := opts.WorkoutsPerMonth || opts.DistancePerWorkout || opts.DistancePerWeek || opts.EnergyPerWeek
+	if opts.ReportsOnly && !reports {
+		return opts, fmt.Errorf("-reports-only needs a report flag such as -distance-per-week")
+	}
 
 	// Process included fields if specified
 	if flags.Include != "" {
@@ -157,5 +308,14 @@ func CreatePrintOptions(flags CLIFlags) printer.PrintOptions {
 		}
 	}
 
-	return opts
+	// Reject unknown field names, metrics and their data points have fields of their own
+	known := []fields.Set{fields.Workouts}
+	if flags.DataType == "metrics" {
+		known = []fields.Set{fields.Metrics, fields.Points}
+	}
+	if err := fields.Check("field", append(append([]string(nil), opts.IncludeFields...), opts.ExcludeFields...), known...); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
 }
@@ -0,0 +1,112 @@
+// cli/import.go
+package cli
+
+import (
+	"fitness/config"
+	"fitness/data"
+	"fitness/models"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RunImportCommand handles `fitness import`, either ingesting new files or reporting their status
+func RunImportCommand(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	status := fs.Bool("status", false, "List export files and whether they have been ingested")
+	appleExport := fs.String("apple-export", "", "Path to an Apple Health export.zip or export.xml to import")
+	routes := fs.String("routes", "", "Path to a GPX/TCX file or a directory of them to attach to workouts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *status {
+		return PrintImportStatus(cfg)
+	}
+
+	// Import the Apple Health archive or route files instead of the export directory
+	if *appleExport != "" || *routes != "" {
+		store, err := data.OpenStore(cfg)
+		if err != nil {
+			return err
+		}
+		if *appleExport != "" {
+			err = data.ImportAppleExport(store, *appleExport)
+		} else {
+			err = data.ImportRoutes(store, *routes)
+		}
+		if err != nil {
+			return err
+		}
+		if store.Changed() {
+			if err := store.Save(); err != nil {
+				return err
+			}
+		}
+		printStoreSize(store)
+		return nil
+	}
+
+	// Ingest any new or changed files into the cache
+	store, err := data.ImportData(cfg)
+	if err != nil {
+		return err
+	}
+	printStoreSize(store)
+	return nil
+}
+
+// PrintImportStatus lists the files in the export directory with their manifest status
+func PrintImportStatus(cfg *config.Config) error {
+	// Read the manifest from the cache, an absent cache means nothing was ingested yet
+	store, err := data.OpenStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	files, err := data.ScanDirectory(cfg.ICloudDirPath, store.Manifest())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Export directory: %s\n", cfg.ICloudDirPath)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-9s %-8s %-8s %-8s %-16s %s\n", "Status", "Workouts", "Metrics", "Tracks", "Ingested", "File")
+
+	counts := make(map[string]int)
+	for _, file := range files {
+		// Files that failed are shown as such, although the next run retries them
+		status := file.Status
+		if file.Failed() {
+			status = models.FileFailed
+		}
+		counts[status]++
+
+		// Show the recorded counts for files that are in the manifest
+		workouts, metrics, tracks, ingested := "-", "-", "-", "-"
+		if file.Entry != nil {
+			workouts = fmt.Sprintf("%d", file.Entry.Workouts)
+			metrics = fmt.Sprintf("%d", file.Entry.Metrics)
+			tracks = fmt.Sprintf("%d", file.Entry.Tracks)
+			ingested = file.Entry.IngestedAt.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-9s %-8s %-8s %-8s %-16s %s\n", status, workouts, metrics, tracks, ingested, filepath.Base(file.Path))
+		if file.Failed() {
+			fmt.Printf("%-9s %s\n", "", file.Entry.Error)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("%d ingested, %d pending, %d changed, %d failed\n",
+		counts[models.FileIngested], counts[models.FilePending], counts[models.FileChanged], counts[models.FileFailed])
+	if counts[models.FileFailed] > 0 {
+		fmt.Println("Failed files are retried on every run")
+	}
+	return nil
+}
+
+// printStoreSize prints how many workouts and metrics the store holds
+func printStoreSize(store data.Store) {
+	fmt.Printf("%d workouts and %d metrics in cache\n", len(store.Workouts(data.AllTime)), len(store.Metrics(data.AllTime)))
+}
@@ -0,0 +1,74 @@
+// cli/stats.go
+package cli
+
+import (
+	"fitness/config"
+	"fitness/data"
+	"fitness/fields"
+	"fitness/printer"
+	"fitness/semantics"
+	"fitness/stats"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// RunStatsCommand handles `fitness stats`, grouping the selected workouts or metric data points and summarizing each group
+// The options before the command select the records, e.g. fitness -since 90d stats -by name,month
+func RunStatsCommand(cfg *config.Config, flags CLIFlags, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	by := fs.String("by", "", "Dimensions to group by, comma-separated: name, location, tag, weekday, hour or a period (day, week, isoweek, month, quarter, year, 7d) (default month, or name,month for metrics)")
+	measure := fs.String("measure", "count", "Measures of each group, comma-separated: count or field:statistic (sum, mean, min, max, median, p90, stddev, auto), e.g. distance:sum or value for each metric's own aggregation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *by == "" {
+		// Values of different metrics rarely add up, so metrics are kept apart unless asked otherwise
+		*by = "month"
+		if flags.DataType == "metrics" {
+			*by = "name,month"
+		}
+	}
+
+	// Check the options, dimensions and measures before loading any data
+	opts, err := CreatePrintOptions(flags)
+	if err != nil {
+		return err
+	}
+	span, err := CreateTimeRange(flags, opts.Week, time.Now)
+	if err != nil {
+		return err
+	}
+	opts.Metrics, err = semantics.Builtin.Extend(cfg.Metrics)
+	if err != nil {
+		return err
+	}
+	title := *measure + " by " + *by
+	ctx := fields.Context{Units: opts.Units, Zone: opts.Zone, TimeFormat: opts.TimeFormat, Metrics: opts.Metrics}
+
+	switch flags.DataType {
+	case "workouts": // Group the selected workouts
+		report, err := stats.ParseReport(*by, *measure, fields.Workouts, opts.Week)
+		if err != nil {
+			return err
+		}
+		store, err := data.ImportData(cfg)
+		if err != nil {
+			return err
+		}
+		workouts := printer.SelectWorkouts(store.Workouts(span), opts)
+		return printer.PrintStats(report.Group(workouts, ctx), title, opts)
+	case "metrics": // Group the selected data points of the selected metrics
+		report, err := stats.ParseReport(*by, *measure, fields.Points, opts.Week)
+		if err != nil {
+			return err
+		}
+		store, err := data.ImportData(cfg)
+		if err != nil {
+			return err
+		}
+		points := fields.PointsOf(printer.SelectMetrics(store.Metrics(span), opts))
+		return printer.PrintStats(report.Group(points, ctx), title, opts)
+	}
+	return fmt.Errorf("invalid data type: %s", flags.DataType)
+}
@@ -0,0 +1,51 @@
+// cli/watch.go
+package cli
+
+import (
+	"context"
+	"fitness/config"
+	"fitness/data"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// RunWatchCommand handles `fitness watch`, ingesting new export files until interrupted
+func RunWatchCommand(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", data.DefaultWatchInterval, "Time between scans of the export directory")
+	settle := fs.Duration("settle", data.DefaultWatchSettle, "Time a file must stay unchanged before it is ingested")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", *interval)
+	}
+
+	store, err := data.OpenStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Stop after the current scan on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	watcher := data.NewWatcher(store, cfg.ICloudDirPath)
+	watcher.Save = store.Save
+	watcher.Interval = *interval
+	watcher.Settle = *settle
+
+	fmt.Printf("Watching %s every %s, press Ctrl+C to stop\n", cfg.ICloudDirPath, *interval)
+	watcher.Run(ctx, func(summary data.WatchSummary) {
+		fmt.Printf("%s %s\n", time.Now().Format("2006-01-02 15:04:05"), summary)
+	}, func(err error) {
+		fmt.Fprintf(os.Stderr, "%s Error: %v, retrying in %s\n", time.Now().Format("2006-01-02 15:04:05"), err, *interval)
+	})
+	fmt.Println("Stopped watching")
+	printStoreSize(store)
+	return nil
+}
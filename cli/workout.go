@@ -0,0 +1,35 @@
+// cli/workout.go
+package cli
+
+import (
+	"fitness/config"
+	"fitness/data"
+	"fitness/printer"
+	"fmt"
+)
+
+// RunWorkoutCommand handles the `fitness workout` subcommands
+// `fitness workout show <id>` prints a single workout with a summary of its series, the id may be shortened
+func RunWorkoutCommand(cfg *config.Config, flags CLIFlags, args []string) error {
+	if len(args) > 0 && args[0] != "show" {
+		return fmt.Errorf("unknown workout command: %s (expected: show <id>)", args[0])
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: fitness workout show <id>")
+	}
+
+	// Check the options before loading any data
+	opts, err := CreatePrintOptions(flags)
+	if err != nil {
+		return err
+	}
+	store, err := data.ImportData(cfg)
+	if err != nil {
+		return err
+	}
+	workout, err := data.FindWorkout(store.Workouts(data.AllTime), args[1])
+	if err != nil {
+		return err
+	}
+	return printer.PrintWorkoutDetail(workout, opts)
+}
@@ -0,0 +1,292 @@
+// config/config.go
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sources describing where an effective setting came from, in order of precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// ConfigPathEnv is the environment variable that overrides the config file location
+const ConfigPathEnv = "FITNESS_CONFIG"
+
+// Config holds the effective application settings
+type Config struct {
+	ICloudDirPath  string // Directory containing Health Auto Export files
+	CacheFilePath  string // Location of the local cache file
+	SeriesFilePath string // Location of the side file for workout series, empty to keep them in the cache
+	DataType       string // Default data type to display (workouts or metrics)
+	TimeFormat     string // Format string for displaying timestamps
+	Units          string // Unit system for display (metric or imperial)
+	Timezone       string // Time zone policy for display and bucketing (original, utc, local or a zone name)
+	WeekStart      string // First day of the week for weekly totals, or iso for ISO weeks
+	SortBy         string // Default field to sort results by
+	MergePolicy    string // Conflict policy when merging duplicate records (newest, max or first)
+
+	Metrics map[string]MetricSpec // Definitions of metrics from the config file, keyed by metric name
+
+	Path    string            // Path of the config file that was consulted
+	Sources map[string]string // Source of each setting, keyed by setting name
+}
+
+// MetricSpec describes a metric in the config file, adding to or overriding the built-in definitions
+// Fields left empty keep the built-in value
+type MetricSpec struct {
+	Name        string   `json:"name,omitempty"`        // Display name, e.g. Resting Heart Rate
+	Aggregation string   `json:"aggregation,omitempty"` // How values of a period combine: sum, mean, latest or sleep
+	Units       string   `json:"units,omitempty"`       // Canonical units of the values, e.g. count/min
+	Min         *float64 `json:"min,omitempty"`         // Smallest valid value in the canonical units
+	Max         *float64 `json:"max,omitempty"`         // Largest valid value in the canonical units
+}
+
+// metricsKey is the config file key holding metric definitions
+const metricsKey = "metrics"
+
+// Entry is a single effective setting along with its origin
+type Entry struct {
+	Key    string // Setting name as used in the config file
+	Value  string // Effective value
+	Source string // Where the value came from (default, file, env or flag)
+}
+
+// setting describes a configurable value and every way it can be provided
+type setting struct {
+	key   string                // Name used in the config file
+	env   string                // Environment variable name
+	flag  string                // Command-line flag name
+	path  bool                  // Whether the value is a filesystem path
+	value func(*Config) *string // Accessor for the value within a Config
+}
+
+// settings lists every configurable value in display order
+var settings = []setting{
+	{key: "icloudDir", env: "FITNESS_ICLOUD_DIR", flag: "icloud-dir", path: true, value: func(c *Config) *string { return &c.ICloudDirPath }},
+	{key: "cacheFile", env: "FITNESS_CACHE_FILE", flag: "cache-file", path: true, value: func(c *Config) *string { return &c.CacheFilePath }},
+	{key: "seriesFile", env: "FITNESS_SERIES_FILE", flag: "series-file", path: true, value: func(c *Config) *string { return &c.SeriesFilePath }},
+	{key: "type", env: "FITNESS_TYPE", flag: "type", value: func(c *Config) *string { return &c.DataType }},
+	{key: "timeFormat", env: "FITNESS_TIME_FORMAT", flag: "time-format", value: func(c *Config) *string { return &c.TimeFormat }},
+	{key: "units", env: "FITNESS_UNITS", flag: "units", value: func(c *Config) *string { return &c.Units }},
+	{key: "timezone", env: "FITNESS_TIMEZONE", flag: "timezone", value: func(c *Config) *string { return &c.Timezone }},
+	{key: "weekStart", env: "FITNESS_WEEK_START", flag: "week-start", value: func(c *Config) *string { return &c.WeekStart }},
+	{key: "sort", env: "FITNESS_SORT", flag: "sort", value: func(c *Config) *string { return &c.SortBy }},
+	{key: "mergePolicy", env: "FITNESS_MERGE_POLICY", flag: "merge-policy", value: func(c *Config) *string { return &c.MergePolicy }},
+}
+
+// Default returns the built-in settings used when nothing else is provided
+func Default() *Config {
+	home, _ := os.UserHomeDir()
+	cfg := &Config{
+		ICloudDirPath: filepath.Join(home, "Library", "Mobile Documents", "iCloud~com~ifunography~HealthExport", "Documents", "Go Application"),
+		CacheFilePath: filepath.Join(home, ".config", "fitness", "cache.json"),
+		DataType:      "workouts",
+		TimeFormat:    TimeFormat,
+		Units:         "imperial",
+		Timezone:      "original",
+		WeekStart:     "monday",
+		SortBy:        "",
+		MergePolicy:   "newest",
+		Sources:       make(map[string]string),
+	}
+	for _, s := range settings {
+		cfg.Sources[s.key] = SourceDefault
+	}
+	return cfg
+}
+
+// DefaultPath returns the config file location, honoring FITNESS_CONFIG
+func DefaultPath() string {
+	if path := os.Getenv(ConfigPathEnv); path != "" {
+		return expandHome(path)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "fitness", "config.json")
+}
+
+// Load builds the configuration from defaults, the config file and the environment
+// Flags are applied afterwards by the caller with ApplyFlags
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = DefaultPath()
+	}
+
+	cfg := Default()
+	if err := cfg.LoadFile(path); err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadFile applies the settings found in a JSON config file
+// A missing file is not an error; the defaults simply remain in effect
+func (c *Config) LoadFile(path string) error {
+	c.Path = expandHome(path)
+
+	content, err := os.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+
+	// Decode into raw values so unknown keys can be reported
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", c.Path, err)
+	}
+
+	for key, raw := range values {
+		if key == metricsKey {
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&c.Metrics); err != nil {
+				return fmt.Errorf("setting %q in %s must map metric names to definitions: %v", key, c.Path, err)
+			}
+			continue
+		}
+		s, ok := lookupSetting(key)
+		if !ok {
+			return fmt.Errorf("unknown setting %q in %s", key, c.Path)
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("setting %q in %s must be a string", key, c.Path)
+		}
+		if err := c.Set(s.key, value, SourceFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyEnv applies any settings provided through environment variables
+func (c *Config) ApplyEnv() error {
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := c.Set(s.key, value, SourceEnv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyFlags applies the settings whose flags were explicitly set on the command line
+func (c *Config) ApplyFlags(fs *flag.FlagSet) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && err == nil {
+				err = c.Set(s.key, f.Value.String(), SourceFlag)
+			}
+		}
+	})
+	return err
+}
+
+// Set updates a setting by name and records where the value came from
+func (c *Config) Set(key, value, source string) error {
+	s, ok := lookupSetting(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if s.path {
+		value = expandHome(value)
+	}
+	*s.value(c) = value
+	c.Sources[s.key] = source
+	return nil
+}
+
+// Validate checks that every setting holds a supported value
+func (c *Config) Validate() error {
+	if c.DataType != "workouts" && c.DataType != "metrics" {
+		return fmt.Errorf("invalid type %q (%s): must be workouts or metrics", c.DataType, c.Sources["type"])
+	}
+	if c.Units != "metric" && c.Units != "imperial" {
+		return fmt.Errorf("invalid units %q (%s): must be metric or imperial", c.Units, c.Sources["units"])
+	}
+	if !validTimezone(c.Timezone) {
+		return fmt.Errorf("invalid timezone %q (%s): must be original, utc, local or a zone name such as Europe/Berlin", c.Timezone, c.Sources["timezone"])
+	}
+	if !validWeekStart(c.WeekStart) {
+		return fmt.Errorf("invalid week start %q (%s): must be a weekday or iso", c.WeekStart, c.Sources["weekStart"])
+	}
+	if c.MergePolicy != "newest" && c.MergePolicy != "max" && c.MergePolicy != "first" {
+		return fmt.Errorf("invalid merge policy %q (%s): must be newest, max or first", c.MergePolicy, c.Sources["mergePolicy"])
+	}
+	if c.CacheFilePath == "" {
+		return fmt.Errorf("cache file path must not be empty")
+	}
+	return nil
+}
+
+// Entries returns every effective setting with its source, in display order
+func (c *Config) Entries() []Entry {
+	entries := make([]Entry, 0, len(settings))
+	for _, s := range settings {
+		entries = append(entries, Entry{Key: s.key, Value: *s.value(c), Source: c.Sources[s.key]})
+	}
+	return entries
+}
+
+// validTimezone reports whether a timezone setting names a policy or a known zone
+func validTimezone(name string) bool {
+	switch strings.ToLower(name) {
+	case "original", "utc", "local":
+		return true
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil && name != ""
+}
+
+// validWeekStart reports whether a week start names a weekday or ISO weeks
+func validWeekStart(name string) bool {
+	if strings.EqualFold(name, "iso") {
+		return true
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupSetting finds a setting by its config file name
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
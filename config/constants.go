@@ -3,14 +3,6 @@ package config
 
 // Constants used throughout the application
 const (
-	DateFormat       = "2006-01-02"
-	TimeFormat       = "2006-01-02 15:04:05 -0700"
-	ICloudDirPath    = "/Users/saavedj/Library/Mobile Documents/iCloud~com~ifunography~HealthExport/Documents/Go Application"
-	CacheFilePath    = "/Users/saavedj/Projects/apple-fitness-health-app/backend/data/cache.json"
-	DateRegexPattern = `\d{4}-\d{2}-\d{2}`
+	DateFormat = "2006-01-02"
+	TimeFormat = "2006-01-02 15:04:05 -0700"
 )
-
-// var (
-// 	ICloudDirPath = ""
-// 	CacheFilePath = ""
-// )
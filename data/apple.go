@@ -0,0 +1,501 @@
+// data/apple.go
+// Streaming importer for the Apple Health "Export All Health Data" archive
+
+package data
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fitness/config"
+	"fitness/models"
+	"fitness/quantity"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Ways Apple Health records are rolled up into one data point per day
+const (
+	appleSum  = "sum"  // Cumulative values such as steps are summed
+	appleAvg  = "avg"  // Discrete samples such as heart rate are averaged
+	appleLast = "last" // Measurements such as body mass keep the latest value
+)
+
+// appleMetric maps an HK type identifier onto a Health Auto Export metric
+type appleMetric struct {
+	Name      string  // Health Auto Export metric name
+	Aggregate string  // How samples within a day are combined
+	Scale     float64 // Multiplier applied to values (e.g. fractions to percent)
+}
+
+// appleRecordTypes lists the HK quantity types that are imported as metrics
+var appleRecordTypes = map[string]appleMetric{
+	"HKQuantityTypeIdentifierStepCount":                  {"step_count", appleSum, 1},
+	"HKQuantityTypeIdentifierActiveEnergyBurned":         {"active_energy", appleSum, 1},
+	"HKQuantityTypeIdentifierBasalEnergyBurned":          {"basal_energy_burned", appleSum, 1},
+	"HKQuantityTypeIdentifierDistanceWalkingRunning":     {"walking_running_distance", appleSum, 1},
+	"HKQuantityTypeIdentifierDistanceCycling":            {"cycling_distance", appleSum, 1},
+	"HKQuantityTypeIdentifierDistanceSwimming":           {"swimming_distance", appleSum, 1},
+	"HKQuantityTypeIdentifierFlightsClimbed":             {"flights_climbed", appleSum, 1},
+	"HKQuantityTypeIdentifierAppleExerciseTime":          {"apple_exercise_time", appleSum, 1},
+	"HKQuantityTypeIdentifierAppleStandTime":             {"apple_stand_time", appleSum, 1},
+	"HKQuantityTypeIdentifierDietaryWater":               {"dietary_water", appleSum, 1},
+	"HKQuantityTypeIdentifierHeartRate":                  {"heart_rate", appleAvg, 1},
+	"HKQuantityTypeIdentifierRestingHeartRate":           {"resting_heart_rate", appleAvg, 1},
+	"HKQuantityTypeIdentifierWalkingHeartRateAverage":    {"walking_heart_rate_average", appleAvg, 1},
+	"HKQuantityTypeIdentifierHeartRateVariabilitySDNN":   {"heart_rate_variability", appleAvg, 1},
+	"HKQuantityTypeIdentifierRespiratoryRate":            {"respiratory_rate", appleAvg, 1},
+	"HKQuantityTypeIdentifierOxygenSaturation":           {"blood_oxygen_saturation", appleAvg, 100},
+	"HKQuantityTypeIdentifierVO2Max":                     {"vo2_max", appleAvg, 1},
+	"HKQuantityTypeIdentifierBodyMass":                   {"weight_body_mass", appleLast, 1},
+	"HKQuantityTypeIdentifierBodyMassIndex":              {"body_mass_index", appleLast, 1},
+	"HKQuantityTypeIdentifierBodyFatPercentage":          {"body_fat_percentage", appleLast, 100},
+	"HKQuantityTypeIdentifierLeanBodyMass":               {"lean_body_mass", appleLast, 1},
+	"HKQuantityTypeIdentifierAppleWalkingSteadiness":     {"walking_steadiness", appleLast, 100},
+	"HKQuantityTypeIdentifierEnvironmentalAudioExposure": {"environmental_audio_exposure", appleAvg, 1},
+}
+
+// appleActivityNames maps HK workout activity types onto Health Auto Export workout names
+// The first name is used for outdoor workouts, the second for indoor ones
+var appleActivityNames = map[string][2]string{
+	"Running":  {"Outdoor Run", "Indoor Run"},
+	"Walking":  {"Outdoor Walk", "Indoor Walk"},
+	"Cycling":  {"Outdoor Cycling", "Indoor Cycling"},
+	"Swimming": {"Open Water Swim", "Pool Swim"},
+	"Hiking":   {"Hiking", "Hiking"},
+}
+
+// appleWorkout mirrors the attributes and children of a <Workout> element
+type appleWorkout struct {
+	ActivityType      string  `xml:"workoutActivityType,attr"`
+	Duration          float64 `xml:"duration,attr"`
+	DurationUnit      string  `xml:"durationUnit,attr"`
+	TotalDistance     string  `xml:"totalDistance,attr"`
+	TotalDistanceUnit string  `xml:"totalDistanceUnit,attr"`
+	TotalEnergy       string  `xml:"totalEnergyBurned,attr"`
+	TotalEnergyUnit   string  `xml:"totalEnergyBurnedUnit,attr"`
+	StartDate         string  `xml:"startDate,attr"`
+	EndDate           string  `xml:"endDate,attr"`
+	Metadata          []struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"MetadataEntry"`
+	Statistics []struct {
+		Type string `xml:"type,attr"`
+		Sum  string `xml:"sum,attr"`
+		Unit string `xml:"unit,attr"`
+	} `xml:"WorkoutStatistics"`
+}
+
+// appleDay accumulates the samples of one metric on one day
+type appleDay struct {
+	Date    models.Timestamp        // Day of the samples at local midnight
+	Sum     float64                 // Running total of sample values
+	Count   int                     // Number of samples seen
+	Last    float64                 // Value of the latest sample
+	At      time.Time               // Start time of the latest sample
+	Sources map[string]*appleSource // Totals of each source, cumulative metrics take a single source's total
+}
+
+// appleSource accumulates the samples a single app or device recorded for a metric on one day
+type appleSource struct {
+	Rank int     // Priority of the source, lower ranks win
+	Sum  float64 // Running total of sample values
+}
+
+// AppleExport is the data read from an Apple Health export
+type AppleExport struct {
+	Health   *models.HealthData // Workouts and daily metrics of the export
+	Warnings []string           // Records that were left out and why, one line per metric and reason
+}
+
+// appleParser holds the state of a pass over export.xml
+type appleParser struct {
+	days    map[string]map[string]*appleDay // Daily totals by metric name and day
+	units   map[string]string               // Units of each metric, the units of its first record
+	skipped map[string]int                  // Number of records left out, by metric name and reason
+}
+
+// ParseAppleExport reads an Apple Health export.zip or a bare export.xml
+func ParseAppleExport(exportPath string) (*AppleExport, error) {
+	if !strings.EqualFold(filepath.Ext(exportPath), ".zip") {
+		file, err := os.Open(exportPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return ParseAppleXML(file)
+	}
+
+	archive, err := zip.OpenReader(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening archive: %v", err)
+	}
+	defer archive.Close()
+
+	// Find export.xml inside the archive, ignoring the export_cda.xml sibling
+	var exportData *AppleExport
+	for _, entry := range archive.File {
+		if path.Base(entry.Name) != "export.xml" {
+			continue
+		}
+		if exportData, err = parseAppleZipEntry(entry, ParseAppleXML); err != nil {
+			return nil, err
+		}
+		break
+	}
+	if exportData == nil {
+		return nil, fmt.Errorf("no export.xml found in %s", exportPath)
+	}
+
+	// Attach the routes in the workout-routes folder to the exported workouts
+	var tracks []Track
+	for _, entry := range archive.File {
+		if path.Base(path.Dir(entry.Name)) != "workout-routes" || !IsRouteFile(entry.Name) {
+			continue
+		}
+		routeData, err := parseAppleZipEntry(entry, func(r io.Reader) ([]Track, error) { return ParseRoute(entry.Name, r) })
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading route %s: %v\n", path.Base(entry.Name), err)
+			continue
+		}
+		tracks = append(tracks, routeData...)
+	}
+	workouts, standalone := AttachRoutes(exportData.Health.Data.Workouts, tracks)
+	exportData.Health.Data.Workouts = append(workouts, standalone...)
+
+	return exportData, nil
+}
+
+// parseAppleZipEntry opens a file inside the export archive and parses it
+func parseAppleZipEntry[T any](entry *zip.File, parse func(io.Reader) (T, error)) (T, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("error opening %s: %v", entry.Name, err)
+	}
+	defer reader.Close()
+	return parse(reader)
+}
+
+// ParseAppleXML stream-parses export.xml, keeping only one element in memory at a time
+// Records are rolled up into one data point per metric per day
+func ParseAppleXML(r io.Reader) (*AppleExport, error) {
+	decoder := xml.NewDecoder(r)
+	parser := appleParser{
+		days:    make(map[string]map[string]*appleDay),
+		units:   make(map[string]string),
+		skipped: make(map[string]int),
+	}
+	var workouts []models.Workout
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing export.xml: %v", err)
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch element.Name.Local {
+		case "Record":
+			parser.addRecord(element)
+			if err := decoder.Skip(); err != nil {
+				return nil, fmt.Errorf("error parsing export.xml: %v", err)
+			}
+		case "Workout":
+			var w appleWorkout
+			if err := decoder.DecodeElement(&w, &element); err != nil {
+				return nil, fmt.Errorf("error parsing workout: %v", err)
+			}
+			if workout, ok := convertAppleWorkout(w); ok {
+				workouts = append(workouts, workout)
+			}
+		}
+	}
+
+	return &AppleExport{
+		Health: &models.HealthData{
+			Data: models.DataCollection{
+				Workouts: workouts,
+				Metrics:  parser.metrics(),
+			},
+		},
+		Warnings: parser.warnings(),
+	}, nil
+}
+
+// addRecord folds a single <Record> into the daily totals of its metric
+// Records without a readable start date or in units that do not convert to the metric's are left out and counted
+func (p *appleParser) addRecord(element xml.StartElement) {
+	attrs := make(map[string]string, len(element.Attr))
+	for _, attr := range element.Attr {
+		attrs[attr.Name.Local] = attr.Value
+	}
+
+	metric, ok := appleRecordTypes[attrs["type"]]
+	if !ok {
+		return
+	}
+	value, err := strconv.ParseFloat(attrs["value"], 64)
+	if err != nil {
+		p.skipped[metric.Name+": unreadable value"]++
+		return
+	}
+	start, err := models.ParseTimestamp(attrs["startDate"])
+	if err != nil || !start.Valid() {
+		p.skipped[metric.Name+": unreadable start date"]++
+		return
+	}
+
+	// Bring the value into the units of the metric, fractions are stored as percentages
+	unit := normalizeAppleUnit(attrs["unit"])
+	if metric.Scale == 100 {
+		unit = "%"
+	}
+	target, ok := p.units[metric.Name]
+	if !ok {
+		target = unit
+		p.units[metric.Name] = unit
+	}
+	value, ok = convertAppleValue(value, unit, target)
+	if !ok {
+		p.skipped[fmt.Sprintf("%s: units %s that do not convert to %s", metric.Name, unit, target)]++
+		return
+	}
+	value *= metric.Scale
+
+	// Bucket the sample by its local calendar day
+	dayStart := time.Date(start.Time.Year(), start.Time.Month(), start.Time.Day(), 0, 0, 0, 0, start.Time.Location())
+	key := dayStart.Format(config.DateFormat)
+	if p.days[metric.Name] == nil {
+		p.days[metric.Name] = make(map[string]*appleDay)
+	}
+	day, ok := p.days[metric.Name][key]
+	if !ok {
+		day = &appleDay{Date: models.NewTimestamp(dayStart), Sources: make(map[string]*appleSource)}
+		p.days[metric.Name][key] = day
+	}
+
+	day.Sum += value
+	day.Count++
+	if !start.Time.Before(day.At) {
+		day.Last, day.At = value, start.Time
+	}
+
+	// The Watch and the iPhone both count steps, distance and energy, so totals are kept apart per source
+	source, ok := day.Sources[attrs["sourceName"]]
+	if !ok {
+		source = &appleSource{Rank: appleSourceRank(attrs["sourceName"], attrs["device"])}
+		day.Sources[attrs["sourceName"]] = source
+	}
+	source.Sum += value
+}
+
+// appleSourceRank orders sources the way Apple Health prioritizes them by default:
+// an Apple Watch first, then the iPhone, then other apps and devices
+func appleSourceRank(sourceName, device string) int {
+	switch {
+	case strings.Contains(device, "model:Watch") || strings.Contains(sourceName, "Watch"):
+		return 0
+	case strings.Contains(device, "model:iPhone") || strings.Contains(sourceName, "iPhone"):
+		return 1
+	}
+	return 2
+}
+
+// total is the day's total of a cumulative metric, taken from the source with the highest priority
+// Sources of the same priority are told apart by the larger total, then by name
+func (d *appleDay) total() float64 {
+	var best *appleSource
+	var bestName string
+	for name, source := range d.Sources {
+		switch {
+		case best == nil, source.Rank < best.Rank,
+			source.Rank == best.Rank && source.Sum > best.Sum,
+			source.Rank == best.Rank && source.Sum == best.Sum && name < bestName:
+			best, bestName = source, name
+		}
+	}
+	if best == nil {
+		return d.Sum
+	}
+	return best.Sum
+}
+
+// convertAppleValue converts a value to the target units, false if the units do not convert
+func convertAppleValue(value float64, unit, target string) (float64, bool) {
+	if unit == target {
+		return value, true
+	}
+	q, err := quantity.New(value, unit)
+	if err != nil {
+		return 0, false
+	}
+	to, err := quantity.ParseUnit(target)
+	if err != nil {
+		return 0, false
+	}
+	converted, err := q.In(to)
+	if err != nil {
+		return 0, false
+	}
+	return converted.Value, true
+}
+
+// warnings describes the records that were left out, in order of metric and reason
+func (p *appleParser) warnings() []string {
+	reasons := make([]string, 0, len(p.skipped))
+	for reason := range p.skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	warnings := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		metric, why, _ := strings.Cut(reason, ": ")
+		warnings = append(warnings, fmt.Sprintf("%d %s records left out: %s", p.skipped[reason], metric, why))
+	}
+	return warnings
+}
+
+// metrics turns the daily totals into metric series sorted by name and date
+func (p *appleParser) metrics() []models.Metric {
+	aggregates := make(map[string]string)
+	for _, metric := range appleRecordTypes {
+		aggregates[metric.Name] = metric.Aggregate
+	}
+
+	var metrics []models.Metric
+	for name, byDay := range p.days {
+		metric := models.Metric{Name: name, Units: p.units[name]}
+		for _, day := range byDay {
+			var qty float64
+			switch aggregates[name] {
+			case appleSum:
+				qty = day.total()
+			case appleAvg:
+				qty = day.Sum / float64(day.Count)
+			case appleLast:
+				qty = day.Last
+			}
+			metric.Data = append(metric.Data, models.MetricData{Date: day.Date, Qty: qty})
+		}
+		sort.Slice(metric.Data, func(i, j int) bool {
+			return metric.Data[i].Date.Before(metric.Data[j].Date)
+		})
+		metrics = append(metrics, metric)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+	return metrics
+}
+
+// convertAppleWorkout maps a <Workout> element onto a models.Workout
+func convertAppleWorkout(w appleWorkout) (models.Workout, bool) {
+	if w.StartDate == "" {
+		return models.Workout{}, false
+	}
+
+	metadata := make(map[string]string, len(w.Metadata))
+	for _, entry := range w.Metadata {
+		metadata[entry.Key] = entry.Value
+	}
+
+	// Keep workouts with unreadable dates, they are reported as import warnings
+	start, _ := models.ParseTimestamp(w.StartDate)
+	end, _ := models.ParseTimestamp(w.EndDate)
+	workout := models.Workout{
+		Name:     appleWorkoutName(w.ActivityType, metadata),
+		Start:    start,
+		End:      end,
+		Duration: w.Duration,
+	}
+
+	// Durations are usually recorded in minutes
+	switch w.DurationUnit {
+	case "min":
+		workout.Duration *= 60
+	case "hr":
+		workout.Duration *= 3600
+	}
+
+	// Older exports use attributes, newer ones use WorkoutStatistics children
+	workout.Distance = appleMeasurement(w.TotalDistance, w.TotalDistanceUnit)
+	workout.ActiveEnergyBurned = appleMeasurement(w.TotalEnergy, w.TotalEnergyUnit)
+	for _, stat := range w.Statistics {
+		switch {
+		case stat.Type == "HKQuantityTypeIdentifierActiveEnergyBurned":
+			workout.ActiveEnergyBurned = appleMeasurement(stat.Sum, stat.Unit)
+		case strings.HasPrefix(stat.Type, "HKQuantityTypeIdentifierDistance") && workout.Distance == nil:
+			workout.Distance = appleMeasurement(stat.Sum, stat.Unit)
+		}
+	}
+
+	// Weather is stored as metadata such as "68 degF"
+	if value, ok := metadata["HKWeatherTemperature"]; ok {
+		fields := strings.Fields(value)
+		if len(fields) == 2 {
+			workout.Temperature = appleMeasurement(fields[0], fields[1])
+		}
+	}
+	return workout, true
+}
+
+// appleWorkoutName converts an HK activity type into a Health Auto Export workout name
+func appleWorkoutName(activityType string, metadata map[string]string) string {
+	activity := strings.TrimPrefix(activityType, "HKWorkoutActivityType")
+	if names, ok := appleActivityNames[activity]; ok {
+		indoor := metadata["HKIndoorWorkout"] == "1"
+		if activity == "Swimming" {
+			indoor = metadata["HKSwimmingLocationType"] != "2"
+		}
+		if indoor {
+			return names[1]
+		}
+		return names[0]
+	}
+
+	// Split the remaining CamelCase names into words
+	var b strings.Builder
+	for i, r := range activity {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// appleMeasurement builds a measurement from attribute strings, nil if the value is missing
+func appleMeasurement(value, unit string) *models.Measurement {
+	qty, err := strconv.ParseFloat(value, 64)
+	if err != nil || value == "" {
+		return nil
+	}
+	return &models.Measurement{Units: normalizeAppleUnit(unit), Qty: qty}
+}
+
+// normalizeAppleUnit converts Apple unit names into the ones used by Health Auto Export
+func normalizeAppleUnit(unit string) string {
+	switch unit {
+	case "Cal":
+		return "kcal"
+	default:
+		return unit
+	}
+}
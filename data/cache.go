@@ -0,0 +1,206 @@
+// data/cache.go
+// Crash-safe, versioned reading and writing of the cache file
+
+package data
+
+import (
+	"encoding/json"
+	"fitness/models"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CacheSchemaVersion is the version of the cache format written by this program
+const CacheSchemaVersion = 2
+
+// cacheBackups is the number of previous versions kept next to the cache and the series file
+const cacheBackups = 3
+
+// migration upgrades a raw cache document by one schema version
+type migration func(doc map[string]json.RawMessage) error
+
+// migrations holds the upgrade from each version to the next, keyed by the old version
+// Versions that only add fields need no migration, the new fields are simply absent
+var migrations = map[int]migration{
+	1: migrateRouteTracks,
+}
+
+// migrateRouteTracks upgrades a version 1 cache, whose manifest counted the tracks of route files as workouts
+// Version 2 records them as tracks, so the workout counts of route files move there
+func migrateRouteTracks(doc map[string]json.RawMessage) error {
+	raw, ok := doc["manifest"]
+	if !ok {
+		return nil
+	}
+	var manifest map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("invalid manifest: %v", err)
+	}
+	for path, entry := range manifest {
+		if workouts, ok := entry["workouts"]; ok && IsRouteFile(path) {
+			entry["tracks"] = workouts
+			entry["workouts"] = json.RawMessage("0")
+		}
+	}
+	upgraded, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	doc["manifest"] = upgraded
+	return nil
+}
+
+// BackupPath returns the location of the n-th most recent backup of the cache or the series file
+func BackupPath(cachePath string, n int) string {
+	return fmt.Sprintf("%s.bak.%d", cachePath, n)
+}
+
+// ReadCacheFile reads and migrates the cache, recovering from a backup if it is corrupted
+// The returned flag reports whether the data was migrated or recovered and should be rewritten
+func ReadCacheFile(cachePath string) (*models.HealthData, bool, error) {
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cache, migrated, err := DecodeCache(content)
+	if err == nil {
+		return cache, migrated, nil
+	}
+
+	// Fall back to the most recent backup that can still be read
+	for n := 1; n <= cacheBackups; n++ {
+		backup := BackupPath(cachePath, n)
+		backupContent, readErr := os.ReadFile(backup)
+		if readErr != nil {
+			continue
+		}
+		if recovered, _, decodeErr := DecodeCache(backupContent); decodeErr == nil {
+			fmt.Fprintf(os.Stderr, "Warning: cache %s is corrupted (%v)\n", cachePath, err)
+			fmt.Fprintf(os.Stderr, "Recovered cache from backup %s\n", backup)
+			return recovered, true, nil
+		}
+	}
+	return nil, false, fmt.Errorf("cache %s is corrupted and no readable backup was found: %v", cachePath, err)
+}
+
+// DecodeCache parses a cache document, upgrading it to the current schema version
+// The returned flag reports whether any migration was applied
+func DecodeCache(content []byte) (*models.HealthData, bool, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, false, err
+	}
+
+	// Caches without a schema version predate versioning, they have the layout of version 1 without
+	// the manifest, so every export file is ingested once more, and are rewritten with a version
+	version, migrated := 1, true
+	if raw, ok := doc["schemaVersion"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, false, fmt.Errorf("invalid schemaVersion: %v", err)
+		}
+		migrated = version < CacheSchemaVersion
+	}
+	if version > CacheSchemaVersion {
+		return nil, false, fmt.Errorf("cache schema version %d is newer than the supported version %d", version, CacheSchemaVersion)
+	}
+	if version < 1 {
+		return nil, false, fmt.Errorf("invalid cache schema version %d", version)
+	}
+
+	// Apply each migration in turn up to the current version
+	for ; version < CacheSchemaVersion; version++ {
+		upgrade, ok := migrations[version]
+		if !ok {
+			return nil, false, fmt.Errorf("no migration from cache schema version %d", version)
+		}
+		if err := upgrade(doc); err != nil {
+			return nil, false, fmt.Errorf("error migrating cache from version %d: %v", version, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	var cache models.HealthData
+	if err := json.Unmarshal(upgraded, &cache); err != nil {
+		return nil, false, err
+	}
+	cache.SchemaVersion = CacheSchemaVersion
+	return &cache, migrated, nil
+}
+
+// writeFileAtomic replaces a file so that readers see either the old or the new contents
+// The data is written to a temporary file, synced to disk and renamed over the target
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file if anything fails before the rename
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	committed = true
+
+	// Sync the directory so the rename itself survives a crash
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// rotateBackups shifts the existing backups and copies the current file into the newest slot
+// A file that does not decode is not backed up, so it never pushes out a good backup
+func rotateBackups(path string, decode func([]byte) error) error {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := decode(content); err != nil {
+		return nil
+	}
+
+	for n := cacheBackups - 1; n >= 1; n-- {
+		if err := os.Rename(BackupPath(path, n), BackupPath(path, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeFileAtomic(BackupPath(path, 1), content, 0644)
+}
+
+// decodesAsCache checks that content is a cache document before it is backed up
+func decodesAsCache(content []byte) error {
+	_, _, err := DecodeCache(content)
+	return err
+}
@@ -0,0 +1,425 @@
+// data/condition.go
+// Comparison filters on the fields of workouts and metric data points, such as distance>5km or duration=30m..1h
+
+package data
+
+import (
+	"fitness/config"
+	"fitness/fields"
+	"fitness/models"
+	"fitness/quantity"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// conditionField is a field conditions can compare, read from workouts, metric data points or both
+type conditionField struct {
+	kind      fields.Kind
+	dimension quantity.Dimension            // Dimension of a quantity field, empty if it depends on each value's unit
+	workout   *fields.Field[models.Workout] // The field of workouts, nil if workouts do not have it
+	point     *fields.Field[fields.Point]   // The field of metric data points, nil if they do not have it
+}
+
+// lookupField finds a field of workouts or metric data points by name or alias
+func lookupField(name string) (string, conditionField, bool) {
+	w, _ := fields.Workouts.Lookup(name)
+	p, _ := fields.Points.Lookup(name)
+	switch {
+	case w != nil:
+		return w.Name, conditionField{kind: w.Kind, dimension: w.Dimension, workout: w, point: p}, true
+	case p != nil:
+		return p.Name, conditionField{kind: p.Kind, dimension: p.Dimension, point: p}, true
+	}
+	return "", conditionField{}, false
+}
+
+// displayUnit is the unit numbers without a unit are read in, the display unit of the field
+func (f conditionField) displayUnit(system quantity.System) quantity.Unit {
+	switch {
+	case f.workout != nil:
+		return f.workout.DisplayUnit(system)
+	case f.point != nil:
+		return f.point.DisplayUnit(system)
+	}
+	return system.Unit(f.dimension)
+}
+
+// dynamic reports whether the field's dimension depends on each value's unit, as for metric values
+func (f conditionField) dynamic() bool {
+	return f.kind == fields.Quantity && f.dimension == ""
+}
+
+// conditionPattern splits a condition into its field, operator and value, trying >= before >
+var conditionPattern = regexp.MustCompile(`^\s*([A-Za-z_]+)\s*(>=|<=|!=|>|<|=|~)\s*(.*?)\s*$`)
+
+// numberPattern splits a quantity literal into its number and unit
+var numberPattern = regexp.MustCompile(`^([-+]?(?:[0-9]+\.?[0-9]*|\.[0-9]+))\s*(.*)$`)
+
+// bound is a literal a field is compared with
+type bound struct {
+	number    float64       // Value of a quantity literal
+	tolerance float64       // Difference within which a quantity equals the literal, only used by = and !=
+	unit      quantity.Unit // Unit of a quantity literal, unset for fields compared as recorded
+	text      string        // Lower-cased text literal, or the day of a date literal
+	day       bool          // Whether a time literal is a calendar day rather than an instant
+	instant   time.Time     // Instant of a time literal
+}
+
+// Condition compares a workout field, or a metric's name, date or value, with a value or an inclusive range
+type Condition struct {
+	Field  string // Canonical field name
+	Op     string // One of >, >=, <, <=, =, != or ~
+	Value  string // Value as written
+	field  conditionField
+	low    bound
+	high   bound // Upper end of a range, only used if ranged
+	ranged bool
+	zone   models.Zone
+	system quantity.System
+}
+
+// ParseCondition reads a condition such as distance>5km, duration=30m..1h or location!=Home
+// Numbers without a unit are in the system's display unit, dates are calendar days in the zone
+func ParseCondition(spec string, system quantity.System, zone models.Zone) (Condition, error) {
+	match := conditionPattern.FindStringSubmatch(spec)
+	if match == nil || match[3] == "" {
+		return Condition{}, fmt.Errorf("invalid filter %q (expected field, operator and value, e.g. distance>5km)", spec)
+	}
+
+	name, field, ok := lookupField(match[1])
+	if !ok {
+		return Condition{}, fields.Unknown("filter field", match[1], fields.Workouts, fields.Points)
+	}
+	c := Condition{Field: name, Op: match[2], Value: match[3], field: field, zone: zone, system: system}
+
+	// Ranges are inclusive and only make sense as equal or not equal
+	low, high, ranged := strings.Cut(c.Value, "..")
+	if ranged && c.Op != "=" && c.Op != "!=" {
+		return Condition{}, fmt.Errorf("invalid filter %q: ranges can only be used with = or !=", spec)
+	}
+	if c.Op == "~" && field.kind != fields.Text {
+		return Condition{}, fmt.Errorf("invalid filter %q: ~ only matches text fields", spec)
+	}
+	if field.kind == fields.Text && c.Op != "=" && c.Op != "!=" && c.Op != "~" {
+		return Condition{}, fmt.Errorf("invalid filter %q: %s can only be compared with =, != or ~", spec, name)
+	}
+	if ranged && field.kind == fields.Text {
+		return Condition{}, fmt.Errorf("invalid filter %q: %s cannot be compared with a range", spec, name)
+	}
+
+	var err error
+	if !ranged {
+		c.low, err = parseBound(field, c.Value, system)
+		if err != nil {
+			return Condition{}, fmt.Errorf("invalid filter %q: %v", spec, err)
+		}
+		// Only equality is loose, distance<6 must not match 5.8
+		if c.Op != "=" && c.Op != "!=" {
+			c.low.tolerance = 0
+		}
+		return c, nil
+	}
+
+	c.ranged = true
+	if c.high, err = parseBound(field, high, system); err != nil {
+		return Condition{}, fmt.Errorf("invalid filter %q: %v", spec, err)
+	}
+	// A lower end without a unit takes the unit of the upper end, as in 3..6km
+	inherited := ""
+	if field.kind == fields.Quantity {
+		lowParts, highParts := numberPattern.FindStringSubmatch(low), numberPattern.FindStringSubmatch(high)
+		if lowParts != nil && lowParts[2] == "" && highParts != nil && !strings.ContainsAny(highParts[2], "0123456789") {
+			low += highParts[2]
+			inherited = " (a lower end without a unit takes the unit of the upper end)"
+		}
+	}
+	if c.low, err = parseBound(field, low, system); err != nil {
+		return Condition{}, fmt.Errorf("invalid filter %q: %v", spec, err)
+	}
+	c.low.tolerance, c.high.tolerance = 0, 0
+
+	// A range that ends before it starts matches nothing, which is a mistake such as 30..1h (30h to 1h)
+	if field.kind != fields.Time && compareNumbers(c.high.number, convertBound(c.low, c.high.unit)) < 0 {
+		return Condition{}, fmt.Errorf("invalid filter %q: the range is empty, %s is above %s%s", spec, strings.TrimSpace(low), strings.TrimSpace(high), inherited)
+	}
+	return c, nil
+}
+
+// convertBound returns a quantity literal in another unit, literals that do not convert are left as they are
+func convertBound(b bound, unit quantity.Unit) bound {
+	if b.unit == unit || b.unit.Dimension != unit.Dimension {
+		return b
+	}
+	converted, err := quantity.Quantity{Value: b.number, Unit: b.unit}.In(unit)
+	if err != nil {
+		return b
+	}
+	b.number, b.unit = converted.Value, unit
+	return b
+}
+
+// parseBound reads a literal for a field
+func parseBound(field conditionField, value string, system quantity.System) (bound, error) {
+	value = strings.TrimSpace(value)
+	switch field.kind {
+	case fields.Text:
+		return bound{text: strings.ToLower(value)}, nil
+	case fields.Time:
+		if day, err := time.Parse(config.DateFormat, value); err == nil {
+			return bound{text: day.Format(config.DateFormat), day: true}, nil
+		}
+		ts, err := models.ParseTimestamp(value)
+		if err != nil {
+			return bound{}, fmt.Errorf("invalid date %q", value)
+		}
+		return bound{instant: ts.Time}, nil
+	}
+
+	// Durations may be written like Go durations, e.g. 45m or 1h30m
+	if field.dimension == quantity.Duration {
+		if d, err := time.ParseDuration(value); err == nil {
+			return bound{number: d.Seconds(), tolerance: 0.5, unit: quantity.Second}, nil
+		}
+	}
+
+	parts := numberPattern.FindStringSubmatch(value)
+	if parts == nil {
+		return bound{}, fmt.Errorf("invalid number %q", value)
+	}
+	number, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return bound{}, fmt.Errorf("invalid number %q", value)
+	}
+	b := bound{number: number, tolerance: 0.5}
+	// A literal equals the values that round to it, so 3.1 equals anything from 3.05 up to 3.15
+	if _, decimals, ok := strings.Cut(parts[1], "."); ok {
+		b.tolerance = 0.5 * math.Pow(10, -float64(len(decimals)))
+	}
+
+	symbol := strings.TrimSpace(parts[2])
+	switch {
+	case field.dynamic():
+		// Values without a unit are compared in the display units, others in any unit that converts
+		if symbol != "" {
+			if b.unit, err = quantity.ParseUnit(symbol); err != nil {
+				return bound{}, err
+			}
+		}
+	case field.kind == fields.Number:
+		if symbol != "" && symbol != "%" {
+			return bound{}, fmt.Errorf("unit %q cannot be used here, the field is compared as recorded", symbol)
+		}
+	case symbol == "":
+		b.unit = field.displayUnit(system)
+	default:
+		unit, err := quantity.ParseUnit(symbol)
+		if err != nil {
+			return bound{}, err
+		}
+		if unit.Dimension != field.dimension {
+			return bound{}, fmt.Errorf("unit %s measures %s, not %s", unit.Symbol, unit.Dimension, field.dimension)
+		}
+		b.unit = unit
+	}
+	return b, nil
+}
+
+// Match reports whether a workout satisfies the condition
+// Workouts without a value for the field never match, whatever the operator
+func (c Condition) Match(w models.Workout) bool {
+	if c.field.workout == nil {
+		return false
+	}
+	return c.matchValue(c.field.workout.Get(w, c.context()))
+}
+
+// MatchPoint reports whether a metric data point satisfies the condition
+// name compares the metric's name, start or date the point's date and value its quantity,
+// fields only workouts have never match
+func (c Condition) MatchPoint(m models.Metric, d models.MetricData) bool {
+	if c.field.point == nil {
+		return false
+	}
+	return c.matchValue(c.field.point.Get(fields.Point{Metric: m, Data: d}, c.context()))
+}
+
+// context is the settings field values are read with
+func (c Condition) context() fields.Context {
+	return fields.Context{Units: c.system, Zone: c.zone}
+}
+
+// matchValue compares a field's value with the condition, missing values never match
+func (c Condition) matchValue(v fields.Value) bool {
+	switch {
+	case v.Missing:
+		return false
+	case c.field.kind == fields.Text:
+		return c.matches(c.textComparer(v.Text))
+	case c.field.kind == fields.Time:
+		return c.matches(c.timeComparer(v.Time))
+	}
+	return c.matches(c.measureComparer(v.Measure))
+}
+
+// matches applies the operator to a comparer, values that cannot be compared never match
+func (c Condition) matches(compare func(bound) int, ok bool) bool {
+	if !ok {
+		return false
+	}
+
+	if c.ranged {
+		inside := compare(c.low) >= 0 && compare(c.high) <= 0
+		return inside == (c.Op == "=")
+	}
+	result := compare(c.low)
+	switch c.Op {
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case "!=":
+		return result != 0
+	default:
+		return result == 0
+	}
+}
+
+// textComparer compares text with a bound
+func (c Condition) textComparer(value string) (func(bound) int, bool) {
+	value = strings.ToLower(value)
+	if c.Op == "~" {
+		// Substring matches are reported as equal so ~ works like =
+		return func(b bound) int {
+			if strings.Contains(value, b.text) {
+				return 0
+			}
+			return 1
+		}, true
+	}
+	return func(b bound) int { return strings.Compare(value, b.text) }, true
+}
+
+// timeComparer compares a timestamp with a bound, by calendar day for date literals
+func (c Condition) timeComparer(ts models.Timestamp) (func(bound) int, bool) {
+	if !ts.Valid() {
+		return nil, false
+	}
+	return func(b bound) int {
+		if b.day {
+			return strings.Compare(c.zone.Day(ts).Format(config.DateFormat), b.text)
+		}
+		return ts.Time.Compare(b.instant)
+	}, true
+}
+
+// measureComparer compares a measurement with a bound, converting it to the bound's unit
+func (c Condition) measureComparer(m *models.Measurement) (func(bound) int, bool) {
+	if m == nil {
+		return nil, false
+	}
+	if c.field.kind == fields.Number {
+		return func(b bound) int { return compareNumbers(m.Qty, b) }, true
+	}
+	if c.field.dynamic() && c.low.unit.Symbol == "" {
+		// Numbers without a unit are compared in the display units the values are already in
+		return func(b bound) int { return compareNumbers(m.Qty, b) }, true
+	}
+	// Values in units that cannot be converted to the literal's unit never match
+	q, err := quantity.FromMeasurement(m)
+	if err == nil {
+		_, err = q.In(c.low.unit)
+	}
+	if err != nil {
+		return nil, false
+	}
+	return func(b bound) int {
+		converted, _ := q.In(b.unit)
+		return compareNumbers(converted.Value, b)
+	}, true
+}
+
+// compareNumbers compares a number with a quantity literal, within the literal's tolerance
+func compareNumbers(value float64, b bound) int {
+	switch {
+	case value < b.number-b.tolerance:
+		return -1
+	case value > b.number+b.tolerance:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CheckWorkoutFields rejects conditions on fields only metric data points have, such as value,
+// which no workout could ever satisfy
+func CheckWorkoutFields(conditions []Condition) error {
+	for _, c := range conditions {
+		if c.field.workout == nil {
+			return fields.Unknown("field for workouts", c.Field, fields.Workouts)
+		}
+	}
+	return nil
+}
+
+// MatchAll reports whether a workout satisfies every condition
+func MatchAll(conditions []Condition, w models.Workout) bool {
+	for _, c := range conditions {
+		if !c.Match(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchAllPoints reports whether a metric data point satisfies every condition
+func MatchAllPoints(conditions []Condition, m models.Metric, d models.MetricData) bool {
+	for _, c := range conditions {
+		if !c.MatchPoint(m, d) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchAllMetric reports whether a metric satisfies every condition
+// Name and units conditions hold for the metric itself, the others must all hold for one and the same data point
+func MatchAllMetric(conditions []Condition, m models.Metric) bool {
+	var pointConditions []Condition
+	for _, c := range conditions {
+		if c.Field != "name" && c.Field != "units" {
+			pointConditions = append(pointConditions, c)
+		} else if !c.MatchPoint(m, models.MetricData{}) {
+			return false
+		}
+	}
+	if len(pointConditions) == 0 {
+		return true
+	}
+	for _, d := range m.Data {
+		if MatchAllPoints(pointConditions, m, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShorthandCondition builds the condition of a separate field and value, as given to -f and -value
+// Text fields match a substring of the value, other fields equal it unless it starts with an operator
+func ShorthandCondition(field, value string, system quantity.System, zone models.Zone) (Condition, error) {
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value[:min(len(value), 1)], "<>=!~") {
+		return ParseCondition(field+value, system, zone)
+	}
+	if _, f, ok := lookupField(field); ok && f.kind == fields.Text {
+		return ParseCondition(field+"~"+value, system, zone)
+	}
+	return ParseCondition(field+"="+value, system, zone)
+}
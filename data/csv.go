@@ -0,0 +1,355 @@
+// data/csv.go
+// Importer for Health Auto Export CSV files
+
+package data
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fitness/models"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// csvColumn describes a CSV header such as "Heart Rate [Avg] (count/min)"
+type csvColumn struct {
+	Name  string // Normalized snake_case name, e.g. heart_rate
+	Stat  string // Optional statistic in brackets, e.g. avg
+	Units string // Units in parentheses, e.g. count/min
+}
+
+// csvTextColumns and csvTimeColumns name the metrics columns, or statistics in brackets, that hold text or times
+// rather than values, e.g. Source or Sleep Analysis [Sleep Start]
+var (
+	csvTextColumns = map[string]bool{"source": true}
+	csvTimeColumns = map[string]bool{"sleep_start": true, "sleep_end": true, "in_bed_start": true, "in_bed_end": true}
+)
+
+// csvHeaderPattern splits a header into its name, bracketed statistic and parenthesized units
+var csvHeaderPattern = regexp.MustCompile(`^(.*?)\s*(?:\[([^\]]*)\])?\s*(?:\(([^)]*)\))?$`)
+
+// ParseCSV reads a Health Auto Export workouts or metrics CSV file
+// The layout is detected from the header row
+func ParseCSV(r io.Reader) (*models.HealthData, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv: %v", err)
+	}
+	if len(rows) == 0 {
+		return &models.HealthData{}, nil
+	}
+
+	// Parse the header row into columns
+	columns := make([]csvColumn, len(rows[0]))
+	for i, header := range rows[0] {
+		columns[i] = parseCSVHeader(header)
+	}
+
+	// Workouts have start times, metrics are keyed by a date column
+	switch {
+	case hasCSVColumn(columns, "start"):
+		workouts, err := parseWorkoutsCSV(columns, rows[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &models.HealthData{Data: models.DataCollection{Workouts: workouts}}, nil
+	case columns[0].Name == "date_time" || columns[0].Name == "date":
+		metrics, err := parseMetricsCSV(columns, rows[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &models.HealthData{Data: models.DataCollection{Metrics: metrics}}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized csv layout starting with %q", rows[0][0])
+	}
+}
+
+// parseWorkoutsCSV maps each row of a workouts CSV onto a models.Workout
+func parseWorkoutsCSV(columns []csvColumn, rows [][]string) ([]models.Workout, error) {
+	var workouts []models.Workout
+	for line, row := range rows {
+		var workout models.Workout
+		for i, cell := range row {
+			if i >= len(columns) || strings.TrimSpace(cell) == "" {
+				continue
+			}
+			if err := setWorkoutColumn(&workout, columns[i], strings.TrimSpace(cell)); err != nil {
+				return nil, fmt.Errorf("row %d: %v", line+2, err)
+			}
+		}
+		if workout.Name == "" && workout.Start.IsZero() {
+			continue
+		}
+		workouts = append(workouts, workout)
+	}
+	return workouts, nil
+}
+
+// setWorkoutColumn sets the workout field that corresponds to a CSV column
+func setWorkoutColumn(workout *models.Workout, column csvColumn, cell string) error {
+	var err error
+	switch column.Name {
+	case "id":
+		workout.ID = cell
+	case "workout_type", "type", "name":
+		workout.Name = cell
+	case "start":
+		workout.Start, _ = models.ParseTimestamp(cell) // Unreadable times are reported as import warnings
+	case "end":
+		workout.End, _ = models.ParseTimestamp(cell)
+	case "duration":
+		workout.Duration, err = parseCSVDuration(cell, column.Units)
+	case "active_energy":
+		workout.ActiveEnergyBurned, err = parseCSVMeasurement(cell, column.Units)
+	case "distance":
+		workout.Distance, err = parseCSVMeasurement(cell, column.Units)
+	case "intensity":
+		workout.Intensity, err = parseCSVMeasurement(cell, column.Units)
+	case "temperature":
+		workout.Temperature, err = parseCSVMeasurement(cell, column.Units)
+	case "humidity":
+		workout.Humidity, err = parseCSVMeasurement(cell, column.Units)
+	case "location":
+		location := cell
+		workout.Location = &location
+	case "tags", "tag":
+		workout.Tags = parseCSVTags(cell)
+	}
+	if err != nil {
+		return fmt.Errorf("column %q: %v", column.Name, err)
+	}
+	return nil
+}
+
+// parseCSVTags splits a cell of comma or semicolon separated tags, e.g. "commute; easy"
+func parseCSVTags(cell string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(cell, func(r rune) bool { return r == ',' || r == ';' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseMetricsCSV turns each column of a metrics CSV into a metric series
+func parseMetricsCSV(columns []csvColumn, rows [][]string) ([]models.Metric, error) {
+	metrics := make(map[string]*models.Metric)
+	var order []string
+
+	for line, row := range rows {
+		if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		date, _ := models.ParseTimestamp(strings.TrimSpace(row[0]))
+
+		// Columns split into statistics, such as min/avg/max or sleep stages, share the row's point
+		points := make(map[string]*models.MetricData)
+		quantities := make(map[string]bool)
+		shared := make(map[string]string)
+		for i := 1; i < len(row) && i < len(columns); i++ {
+			column := columns[i]
+			cell := strings.TrimSpace(row[i])
+			if cell == "" {
+				continue
+			}
+			// Text and time columns of their own, such as Source, describe every point of the row
+			if column.holdsText() && column.Stat == "" {
+				shared[column.Name] = cell
+				continue
+			}
+
+			metric, ok := metrics[column.Name]
+			if !ok {
+				metric = &models.Metric{Name: column.Name, Units: column.Units}
+				metrics[column.Name] = metric
+				order = append(order, column.Name)
+			}
+			point, ok := points[column.Name]
+			if !ok {
+				metric.Data = append(metric.Data, models.MetricData{Date: date})
+				point = &metric.Data[len(metric.Data)-1]
+				points[column.Name] = point
+			}
+			if column.holdsText() {
+				setMetricText(point, column.field(), cell)
+				continue
+			}
+
+			qty, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d column %q: %v", line+2, column.Name, err)
+			}
+			if setMetricStat(point, column.Stat, qty) {
+				quantities[column.Name] = true
+			}
+		}
+
+		// Points without a plain value take it from their statistics
+		for name, point := range points {
+			if !quantities[name] {
+				point.Qty, _ = point.Derived()
+			}
+			for field, cell := range shared {
+				setMetricText(point, field, cell)
+			}
+		}
+	}
+
+	result := make([]models.Metric, 0, len(order))
+	for _, name := range order {
+		result = append(result, *metrics[name])
+	}
+	return result, nil
+}
+
+// setMetricStat stores the value of a column on a data point according to the column's statistic
+// Statistics the model has no field for are kept as extra fields, it reports whether the value is the quantity
+func setMetricStat(point *models.MetricData, stat string, qty float64) bool {
+	switch strings.ReplaceAll(stat, " ", "") {
+	case "", "qty":
+		point.Qty = qty
+		return true
+	case "min":
+		point.Min = &qty
+	case "avg", "average":
+		point.Avg = &qty
+	case "max":
+		point.Max = &qty
+	case "asleep":
+		point.Asleep = &qty
+	case "inbed":
+		point.InBed = &qty
+	case "core":
+		point.Core = &qty
+	case "deep":
+		point.Deep = &qty
+	case "rem":
+		point.REM = &qty
+	default:
+		if point.Extra == nil {
+			point.Extra = make(map[string]json.RawMessage)
+		}
+		point.Extra[snakeCase(stat)] = json.RawMessage(strconv.FormatFloat(qty, 'f', -1, 64))
+	}
+	return false
+}
+
+// setMetricText stores a text or time column on a data point: its source, the start or end of sleep,
+// or an extra field for the others
+func setMetricText(point *models.MetricData, field, cell string) {
+	if field == "source" {
+		point.Source = cell
+		return
+	}
+	// Times that do not parse keep their text, like the dates of the rows
+	ts, _ := models.ParseTimestamp(cell)
+	switch field {
+	case "sleep_start":
+		point.SleepStart = &ts
+	case "sleep_end":
+		point.SleepEnd = &ts
+	default:
+		if point.Extra == nil {
+			point.Extra = make(map[string]json.RawMessage)
+		}
+		text, _ := json.Marshal(cell)
+		point.Extra[field] = text
+	}
+}
+
+// parseCSVHeader normalizes a header into its name, statistic and units
+func parseCSVHeader(header string) csvColumn {
+	matches := csvHeaderPattern.FindStringSubmatch(strings.TrimSpace(header))
+	if matches == nil {
+		return csvColumn{Name: snakeCase(header)}
+	}
+	return csvColumn{
+		Name:  snakeCase(matches[1]),
+		Stat:  strings.ToLower(strings.TrimSpace(matches[2])),
+		Units: strings.TrimSpace(matches[3]),
+	}
+}
+
+// field is the statistic in brackets of a metrics column, or its name for a column without one
+func (c csvColumn) field() string {
+	if c.Stat != "" {
+		return snakeCase(c.Stat)
+	}
+	return c.Name
+}
+
+// holdsText reports whether a metrics column holds text or times, by its name or the statistic in brackets
+func (c csvColumn) holdsText() bool {
+	return csvTextColumns[c.field()] || csvTimeColumns[c.field()]
+}
+
+// hasCSVColumn reports whether a column with the given name exists
+func hasCSVColumn(columns []csvColumn, name string) bool {
+	for _, column := range columns {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// snakeCase converts a display name such as "Weight & Body Mass" into weight_body_mass
+func snakeCase(s string) string {
+	var b strings.Builder
+	lastUnderscore := true
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			lastUnderscore = false
+		} else if !lastUnderscore {
+			b.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// parseCSVDuration parses a duration given as h:mm:ss, mm:ss or a number in the header units
+func parseCSVDuration(value, units string) (float64, error) {
+	if strings.Contains(value, ":") {
+		seconds := 0.0
+		for _, part := range strings.Split(value, ":") {
+			n, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			seconds = seconds*60 + n
+		}
+		return seconds, nil
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	switch strings.ToLower(units) {
+	case "min":
+		return n * 60, nil
+	case "hr", "h":
+		return n * 3600, nil
+	default:
+		return n, nil
+	}
+}
+
+// parseCSVMeasurement parses a numeric cell into a measurement with the header units
+func parseCSVMeasurement(value, units string) (*models.Measurement, error) {
+	qty, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", value)
+	}
+	return &models.Measurement{Units: units, Qty: qty}, nil
+}
@@ -6,7 +6,9 @@ package data
 import (
 	"fitness/config"
 	"fitness/models"
+	"fitness/quantity"
 	"fmt"
+	"path"
 	"strings"
 	"time"
 )
@@ -38,6 +40,38 @@ func FilterWorkout(workouts []models.Workout, workoutType string) ([]models.Work
 	return filteredWorkouts, len(filteredWorkouts) > 0
 }
 
+// ParseMetricPatterns reads a comma-separated list of metric names or globs such as heart_rate*
+func ParseMetricPatterns(spec string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(spec, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid metric pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// MatchMetricName reports whether a metric name matches any of the patterns, ignoring case
+// No patterns match every metric
+func MatchMetricName(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterCalories keeps the workouts that burned at least the threshold in kcal
 func FilterCalories(workouts []models.Workout, calorieThreshold float64) ([]models.Workout, bool) {
 	// If calorie threshold is zero, return all workouts
 	if calorieThreshold == 0 {
@@ -47,7 +81,15 @@ func FilterCalories(workouts []models.Workout, calorieThreshold float64) ([]mode
 	// Filter the workout data based on the calorie threshold
 	var filteredWorkouts []models.Workout
 	for _, workout := range workouts {
-		if workout.ActiveEnergyBurned != nil && workout.ActiveEnergyBurned.Qty >= calorieThreshold {
+		if workout.ActiveEnergyBurned == nil {
+			continue
+		}
+		// Energy without units, or in units that are not an energy, is taken to be in kcal as exported
+		energy, err := quantity.Imperial.Value(workout.ActiveEnergyBurned, quantity.Energy)
+		if err != nil {
+			energy = workout.ActiveEnergyBurned.Qty
+		}
+		if energy >= calorieThreshold {
 			filteredWorkouts = append(filteredWorkouts, workout)
 		}
 	}
@@ -56,7 +98,9 @@ func FilterCalories(workouts []models.Workout, calorieThreshold float64) ([]mode
 	return filteredWorkouts, len(filteredWorkouts) > 0
 }
 
-func FilterDate(workouts []models.Workout, queryDate string, isStartDate bool) ([]models.Workout, bool) {
+// FilterDate keeps the workouts on or after (isStartDate) or on or before the query date
+// Workouts are compared by the calendar day they fall on in the zone policy
+func FilterDate(workouts []models.Workout, queryDate string, isStartDate bool, zone models.Zone) ([]models.Workout, bool) {
 	var filteredWorkouts []models.Workout
 	// If queryDate is empty, return all workouts
 	if queryDate == "" {
@@ -69,19 +113,20 @@ func FilterDate(workouts []models.Workout, queryDate string, isStartDate bool) (
 		fmt.Println("Error parsing query queryDate:", err)
 		return nil, false
 	}
+	providedDay := providedDate.Format(config.DateFormat)
 
 	for _, workout := range workouts {
-		//  Parse the start queryDate out of the workout
-		workoutDate, err := time.Parse(config.TimeFormat, workout.Start)
-		if err != nil {
+		//  Find the calendar day the workout started on
+		if !workout.Start.Valid() {
 			continue
 		}
+		workoutDay := zone.Day(workout.Start).Format(config.DateFormat)
 
 		// Filter the workout data based on the queryDate
-		if isStartDate && !workoutDate.Before(providedDate) {
+		if isStartDate && workoutDay >= providedDay {
 			// Filter out everything before the queryDate
 			filteredWorkouts = append(filteredWorkouts, workout)
-		} else if !isStartDate && !workoutDate.After(providedDate) {
+		} else if !isStartDate && workoutDay <= providedDay {
 			// Filter out everything after the queryDate
 			filteredWorkouts = append(filteredWorkouts, workout)
 		}
@@ -0,0 +1,316 @@
+// data/ingest.go
+// Concurrent reading of export files with a deterministic merge order
+
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fitness/models"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// parsedFile is the result of reading a single export file
+type parsedFile struct {
+	file    FileStatus         // File that was read
+	data    *models.HealthData // Parsed contents, nil if reading failed
+	hash    string             // SHA-256 hash of the contents
+	err     error              // Error encountered while reading the file
+	elapsed time.Duration      // Time taken to read and decode the file
+}
+
+// IngestFiles loads the scanned files that need ingesting into the store and records them in the manifest
+// Files are read with one worker per CPU, the returned flag reports whether the store or its manifest changed
+func IngestFiles(store Store, files []FileStatus) bool {
+	return IngestFilesConcurrently(store, files, runtime.NumCPU())
+}
+
+// IngestFilesConcurrently is IngestFiles with at most workers files being read at once
+// Files are merged in the order given whatever order they finish in, so the result is deterministic
+func IngestFilesConcurrently(store Store, files []FileStatus, workers int) bool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Route files are attached to workouts, so they go after the data files
+	files = append([]FileStatus(nil), files...)
+	sort.SliceStable(files, func(i, j int) bool {
+		return !IsRouteFile(files[i].Path) && IsRouteFile(files[j].Path)
+	})
+
+	manifestWasUpdated := false
+	var exports, routes []FileStatus
+	for _, file := range files {
+		switch {
+		case file.NeedsIngest() && IsRouteFile(file.Path):
+			routes = append(routes, file)
+		case file.NeedsIngest():
+			exports = append(exports, file)
+		case file.Entry != nil && !file.Entry.ModTime.Equal(file.ModTime):
+			// Refresh the recorded mtime of files that were touched but not modified
+			entry := *file.Entry
+			entry.Size, entry.ModTime = file.Size, file.ModTime
+			store.RecordFile(entry)
+			manifestWasUpdated = true
+		}
+	}
+
+	// Read the export files in the background, a file's slot is freed once it has been collected
+	// This bounds the number of files being read at once and keeps them from piling up out of order
+	results := make([]chan parsedFile, len(exports))
+	for i := range results {
+		results[i] = make(chan parsedFile, 1)
+	}
+	slots := make(chan struct{}, workers)
+	go func() {
+		for i, file := range exports {
+			slots <- struct{}{}
+			go func(i int, file FileStatus) {
+				results[i] <- parseExportFile(file)
+			}(i, file)
+		}
+	}()
+
+	// Collect the records in file order, newer files are processed last
+	var workouts []models.Workout
+	var metrics []models.Metric
+	for i := range exports {
+		result := <-results[i]
+		<-slots
+		if recordParsedFile(store, result) {
+			workouts = append(workouts, result.data.Data.Workouts...)
+			metrics = append(metrics, result.data.Data.Metrics...)
+		}
+		manifestWasUpdated = true
+	}
+
+	// Merge all files at once, which resolves conflicts exactly as merging them one by one would
+	// Each merge rebuilds the stored data, so merging file by file would cost files times records
+	if len(workouts) > 0 {
+		store.UpsertWorkouts(workouts)
+	}
+	if len(metrics) > 0 {
+		store.UpsertMetrics(metrics)
+	}
+
+	// Attach the tracks of route files to the loaded workouts
+	for _, file := range routes {
+		fmt.Printf("Processing %s data from: %s\n", file.Status, filepath.Base(file.Path))
+		entry := newManifestEntry(file)
+		tracks, hash, err := ImportRouteFile(store, file.Path)
+		entry.Hash = hash
+		entry.Tracks = tracks
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", filepath.Base(file.Path), err)
+			entry.Status = models.FileFailed
+			entry.Error = err.Error()
+		}
+		store.RecordFile(entry)
+		manifestWasUpdated = true
+	}
+
+	return manifestWasUpdated
+}
+
+// parseExportFile reads a single export file and times it
+func parseExportFile(file FileStatus) parsedFile {
+	start := time.Now()
+	data, hash, err := readExportFile(file.Path)
+	return parsedFile{file: file, data: data, hash: hash, err: err, elapsed: time.Since(start)}
+}
+
+// recordParsedFile records a parsed file in the manifest, reporting whether it was read successfully
+func recordParsedFile(store Store, result parsedFile) bool {
+	fmt.Printf("Processing %s data from: %s (%s)\n", result.file.Status, filepath.Base(result.file.Path), result.elapsed.Round(time.Microsecond))
+
+	entry := newManifestEntry(result.file)
+	entry.Hash = result.hash
+	if result.err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", filepath.Base(result.file.Path), result.err)
+		entry.Status = models.FileFailed
+		entry.Error = result.err.Error()
+		store.RecordFile(entry)
+		return false
+	}
+
+	printWarnings(filepath.Base(result.file.Path), TimestampWarnings(result.data))
+	entry.Workouts = len(result.data.Data.Workouts)
+	entry.Metrics = countDataPoints(result.data.Data.Metrics)
+	store.RecordFile(entry)
+	return true
+}
+
+// maxPrintedWarnings is the number of warnings printed per file before they are summarized
+const maxPrintedWarnings = 5
+
+// TimestampWarnings lists the records of an export whose timestamps could not be parsed
+// Such records are still imported, but cannot be placed in time by filters and aggregations
+func TimestampWarnings(health *models.HealthData) []string {
+	var warnings []string
+	for _, w := range health.Data.Workouts {
+		if err := w.Start.Err(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("workout %q start: %v", w.Name, err))
+		}
+		if err := w.End.Err(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("workout %q end: %v", w.Name, err))
+		}
+	}
+	for _, m := range health.Data.Metrics {
+		for _, d := range m.Data {
+			if err := d.Date.Err(); err != nil {
+				warnings = append(warnings, fmt.Sprintf("metric %q date: %v", m.Name, err))
+			}
+		}
+	}
+	return warnings
+}
+
+// printWarnings prints the import warnings of a file to standard error, summarizing long lists
+func printWarnings(name string, warnings []string) {
+	for i, warning := range warnings {
+		if i == maxPrintedWarnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %d more records with unreadable timestamps\n", name, len(warnings)-i)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", name, warning)
+	}
+}
+
+// newManifestEntry starts the manifest entry for a file that is being ingested
+func newManifestEntry(file FileStatus) models.ManifestEntry {
+	return models.ManifestEntry{
+		Path:       file.Path,
+		Size:       file.Size,
+		ModTime:    file.ModTime,
+		Status:     models.FileIngested,
+		IngestedAt: time.Now(),
+	}
+}
+
+// readExportFile streams and parses a single export file, hashing its contents on the way
+func readExportFile(path string) (*models.HealthData, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	reader := io.TeeReader(file, hash)
+	fileData, err := decodeExportFile(path, reader)
+
+	// Hash whatever the decoder did not read, so the hash covers the whole file
+	if _, copyErr := io.Copy(io.Discard, reader); copyErr != nil && err == nil {
+		err = copyErr
+	}
+	return fileData, hex.EncodeToString(hash.Sum(nil)), err
+}
+
+// decodeExportFile parses an export from a reader, by layout for CSV files and as JSON otherwise
+func decodeExportFile(path string, r io.Reader) (*models.HealthData, error) {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ParseCSV(r)
+	}
+
+	// Stream the JSON document one workout or data point series at a time
+	decoder := json.NewDecoder(r)
+	var fileData models.HealthData
+	err := decodeObject(decoder, func(key string) error {
+		if key != "data" {
+			return skipValue(decoder)
+		}
+		return decodeObject(decoder, func(key string) error {
+			switch key {
+			case "workouts":
+				return decodeArray(decoder, func() error {
+					var workout models.Workout
+					if err := decoder.Decode(&workout); err != nil {
+						return err
+					}
+					fileData.Data.Workouts = append(fileData.Data.Workouts, workout)
+					return nil
+				})
+			case "metrics":
+				return decodeArray(decoder, func() error {
+					var metric models.Metric
+					if err := decoder.Decode(&metric); err != nil {
+						return err
+					}
+					fileData.Data.Metrics = append(fileData.Data.Metrics, metric)
+					return nil
+				})
+			default:
+				return skipValue(decoder)
+			}
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling file: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("error unmarshaling file: unexpected data after the top-level value")
+	}
+	return &fileData, nil
+}
+
+// decodeObject reads a JSON object from the decoder, calling field to decode the value of each key
+// A null stands for an empty object
+func decodeObject(decoder *json.Decoder, field func(key string) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected an object, found %v", token)
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err := field(token.(string)); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+// decodeArray reads a JSON array from the decoder, calling element to decode each element
+// A null stands for an empty array
+func decodeArray(decoder *json.Decoder, element func() error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected an array, found %v", token)
+	}
+	for decoder.More() {
+		if err := element(); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+// skipValue reads past the next JSON value without keeping it
+func skipValue(decoder *json.Decoder) error {
+	var value json.RawMessage
+	return decoder.Decode(&value)
+}
@@ -0,0 +1,139 @@
+// data/manifest.go
+// Tracks which export files have been ingested into the cache
+
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fitness/models"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileStatus describes an export file and how it relates to the manifest
+type FileStatus struct {
+	Path    string                // Full path of the file
+	Size    int64                 // Current size of the file in bytes
+	ModTime time.Time             // Current modification time of the file
+	Hash    string                // Content hash, only computed when size or mtime changed
+	Status  string                // One of the models.File* statuses
+	Entry   *models.ManifestEntry // Manifest entry for the file, nil if never ingested
+}
+
+// supportedExtensions lists the file types that can be ingested from the export directory
+var supportedExtensions = map[string]bool{
+	".json": true,
+	".csv":  true,
+	".gpx":  true,
+	".tcx":  true,
+}
+
+// ScanDirectory compares the export files in a directory with the manifest
+// Files are returned sorted by name so they are ingested in a stable order
+func ScanDirectory(directoryPath string, manifest models.Manifest) ([]FileStatus, error) {
+	files, err := os.ReadDir(directoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []FileStatus
+	for _, file := range files {
+		if file.IsDir() || !supportedExtensions[strings.ToLower(filepath.Ext(file.Name()))] {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		status, err := checkFile(filepath.Join(directoryPath, file.Name()), info, manifest)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Path < statuses[j].Path
+	})
+	return statuses, nil
+}
+
+// checkFile determines the status of a single file against its manifest entry
+// A file that failed to ingest is pending again, as the failure may have passed, e.g. a file that was still syncing
+func checkFile(path string, info os.FileInfo, manifest models.Manifest) (FileStatus, error) {
+	status := FileStatus{Path: path, Size: info.Size(), ModTime: info.ModTime(), Status: models.FilePending}
+
+	entry, ok := manifest[path]
+	if !ok {
+		return status, nil
+	}
+	status.Entry = &entry
+
+	// Unchanged size and mtime means the file is unchanged
+	if entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		status.Status = retried(entry.Status)
+		return status, nil
+	}
+
+	// Otherwise fall back to comparing the content hash
+	hash, err := hashFile(path)
+	if err != nil {
+		return status, fmt.Errorf("error hashing %s: %v", path, err)
+	}
+	status.Hash = hash
+	if hash == entry.Hash {
+		status.Status = retried(entry.Status)
+	} else {
+		status.Status = models.FileChanged
+	}
+	return status, nil
+}
+
+// retried is the status of an unchanged file with the recorded status, failed files are tried again
+func retried(status string) string {
+	if status == models.FileFailed {
+		return models.FilePending
+	}
+	return status
+}
+
+// Failed reports whether the last attempt to ingest the file failed, the error is in its manifest entry
+func (f FileStatus) Failed() bool {
+	return f.Entry != nil && f.Entry.Status == models.FileFailed
+}
+
+// NeedsIngest reports whether the file should be read into the cache
+func (f FileStatus) NeedsIngest() bool {
+	return f.Status == models.FilePending || f.Status == models.FileChanged
+}
+
+// hashFile computes the SHA-256 hash of a file's contents
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// countDataPoints returns the total number of data points across metrics
+func countDataPoints(metrics []models.Metric) int {
+	count := 0
+	for _, m := range metrics {
+		count += len(m.Data)
+	}
+	return count
+}
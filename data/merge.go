@@ -0,0 +1,283 @@
+// data/merge.go
+// Deduplicating merge of workouts and metrics
+
+package data
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MergePolicy decides which record wins when the same workout or data point is seen twice
+type MergePolicy string
+
+const (
+	MergeNewest MergePolicy = "newest" // The record from the newest source replaces the existing one
+	MergeMax    MergePolicy = "max"    // The record with the larger value is kept
+	MergeFirst  MergePolicy = "first"  // The record that was seen first is kept
+)
+
+// ParseMergePolicy converts a policy name into a MergePolicy
+func ParseMergePolicy(name string) (MergePolicy, error) {
+	switch policy := MergePolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case MergeNewest, MergeMax, MergeFirst:
+		return policy, nil
+	case "":
+		return MergeNewest, nil
+	default:
+		return "", fmt.Errorf("unknown merge policy %q (expected newest, max or first)", name)
+	}
+}
+
+// WorkoutKey returns the identity used to deduplicate a workout
+// The workout ID is preferred, falling back to the name and start time
+func WorkoutKey(w models.Workout) string {
+	if w.ID != "" {
+		return w.ID
+	}
+	return strings.ToLower(w.Name) + "|" + w.Start.Key()
+}
+
+// sessionKey identifies a workout across sources by its name and its start to the second
+// Apple Health exports carry no workout IDs, so this is how they meet the Health Auto Export copy
+func sessionKey(w models.Workout) string {
+	start := w.Start.Raw
+	if w.Start.Valid() {
+		start = w.Start.Time.UTC().Truncate(time.Second).Format(time.RFC3339)
+	}
+	return strings.ToLower(w.Name) + "|" + start
+}
+
+// MergeWorkouts merges incoming workouts into existing ones, one entry per workout key
+// A workout without an ID is also the same as one with the same name and start time
+// Standalone route workouts give their route to a workout they overlap once it is imported
+// The result is sorted by start time
+func MergeWorkouts(existing, incoming []models.Workout, policy MergePolicy) []models.Workout {
+	index := make(map[string]int, len(existing)+len(incoming))
+	sessions := make(map[string]int, len(existing)+len(incoming))
+	merged := make([]models.Workout, 0, len(existing)+len(incoming))
+
+	// Add each workout, resolving conflicts with the policy
+	add := func(w models.Workout) {
+		i, ok := index[WorkoutKey(w)]
+		if j, found := sessions[sessionKey(w)]; !ok && found && (w.ID == "" || merged[j].ID == "") {
+			i, ok = j, true
+		}
+		if ok {
+			// The ID of either version identifies the merged workout
+			id := merged[i].ID
+			if id == "" {
+				id = w.ID
+			}
+			merged[i] = resolveWorkout(merged[i], w, policy)
+			if merged[i].ID == "" {
+				merged[i].ID = id
+			}
+			index[WorkoutKey(merged[i])] = i
+			return
+		}
+		index[WorkoutKey(w)] = len(merged)
+		sessions[sessionKey(w)] = len(merged)
+		merged = append(merged, w)
+	}
+	for _, w := range existing {
+		add(w)
+	}
+	for _, w := range incoming {
+		add(w)
+	}
+	merged = reattachRoutes(merged)
+
+	// Keep the merged workouts in chronological order
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Start.Before(merged[j].Start)
+	})
+	return merged
+}
+
+// MergeMetrics merges incoming metrics into existing ones, one entry per metric name
+// Data points are deduplicated by date and sorted chronologically
+// A metric keeps the units it was first seen in, points in other units are converted to them
+// or, if they do not convert, left out with a warning
+func MergeMetrics(existing, incoming []models.Metric, policy MergePolicy) []models.Metric {
+	index := make(map[string]int)
+	var merged []models.Metric
+
+	// Group all data points under a single entry per metric name
+	add := func(m models.Metric) {
+		i, ok := index[m.Name]
+		if !ok {
+			index[m.Name] = len(merged)
+			merged = append(merged, models.Metric{Name: m.Name, Units: m.Units})
+			i = len(merged) - 1
+		}
+		if merged[i].Units == "" {
+			merged[i].Units = m.Units
+		}
+		data := m.Data
+		if m.Units != "" && m.Units != merged[i].Units {
+			converted, err := convertMetricData(m.Data, m.Units, merged[i].Units)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %d data points in %s left out, the metric is stored in %s: %v\n", m.Name, len(m.Data), m.Units, merged[i].Units, err)
+				return
+			}
+			data = converted
+		}
+		merged[i].Data = append(merged[i].Data, data...)
+	}
+	for _, m := range existing {
+		add(m)
+	}
+	for _, m := range incoming {
+		add(m)
+	}
+
+	// Deduplicate the data points of each metric
+	for i := range merged {
+		merged[i].Data = mergeMetricData(merged[i].Data, policy)
+	}
+
+	// Sort metrics by name so the cache is canonical
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name < merged[j].Name
+	})
+	return merged
+}
+
+// mergeMetricData removes duplicate dates from a series of data points in arrival order
+func mergeMetricData(points []models.MetricData, policy MergePolicy) []models.MetricData {
+	index := make(map[string]int, len(points))
+	merged := make([]models.MetricData, 0, len(points))
+	for _, p := range points {
+		if i, ok := index[p.Date.Key()]; ok {
+			merged[i] = resolveMetricData(merged[i], p, policy)
+			continue
+		}
+		index[p.Date.Key()] = len(merged)
+		merged = append(merged, p)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Date.Before(merged[j].Date)
+	})
+	return merged
+}
+
+// convertMetricData converts the quantities and statistics of data points from one unit into another
+func convertMetricData(points []models.MetricData, from, to string) ([]models.MetricData, error) {
+	source, err := quantity.ParseUnit(from)
+	if err != nil {
+		return nil, err
+	}
+	target, err := quantity.ParseUnit(to)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]models.MetricData, len(points))
+	for i, p := range points {
+		q, err := quantity.Quantity{Value: p.Qty, Unit: source}.In(target)
+		if err != nil {
+			return nil, err
+		}
+		p.Qty = q.Value
+
+		// Statistics and sleep stages are recorded in the units of the metric as well
+		for _, stat := range []**float64{&p.Min, &p.Avg, &p.Max, &p.Asleep, &p.InBed, &p.Core, &p.Deep, &p.REM} {
+			if *stat == nil {
+				continue
+			}
+			q, err := quantity.Quantity{Value: **stat, Unit: source}.In(target)
+			if err != nil {
+				return nil, err
+			}
+			*stat = &q.Value
+		}
+		converted[i] = p
+	}
+	return converted, nil
+}
+
+// resolveWorkout picks between two versions of the same workout
+// A route, sample series or tags recorded on either version are kept even if the other version wins
+func resolveWorkout(current, candidate models.Workout, policy MergePolicy) models.Workout {
+	winner, loser := pickWorkout(current, candidate, policy)
+	winner.SetSeries(winner.Series().Fill(loser.Series()))
+	if len(winner.Tags) == 0 {
+		winner.Tags = loser.Tags
+	}
+	return winner
+}
+
+// pickWorkout returns the winning and losing version of a workout under the policy
+func pickWorkout(current, candidate models.Workout, policy MergePolicy) (models.Workout, models.Workout) {
+	switch policy {
+	case MergeFirst:
+		return current, candidate
+	case MergeMax:
+		// Prefer the longer workout, then the one with more energy burned
+		if candidate.Duration != current.Duration {
+			if candidate.Duration > current.Duration {
+				return candidate, current
+			}
+			return current, candidate
+		}
+		if energyOf(candidate) > energyOf(current) {
+			return candidate, current
+		}
+		return current, candidate
+	default:
+		return candidate, current
+	}
+}
+
+// resolveMetricData picks between two data points recorded for the same date
+func resolveMetricData(current, candidate models.MetricData, policy MergePolicy) models.MetricData {
+	switch policy {
+	case MergeFirst:
+		return current
+	case MergeMax:
+		if candidate.Qty > current.Qty {
+			return candidate
+		}
+		return current
+	default:
+		return candidate
+	}
+}
+
+// energyOf returns the active energy of a workout, or zero if it was not recorded
+func energyOf(w models.Workout) float64 {
+	if w.ActiveEnergyBurned == nil {
+		return 0
+	}
+	return w.ActiveEnergyBurned.Qty
+}
+
+// FindWorkout looks up a workout by ID or workout key, or by the start of a single ID, ignoring case
+func FindWorkout(workouts []models.Workout, id string) (models.Workout, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return models.Workout{}, fmt.Errorf("no workout id given")
+	}
+	var matches []models.Workout
+	for _, w := range workouts {
+		if strings.EqualFold(WorkoutKey(w), id) {
+			return w, nil
+		}
+		if w.ID != "" && strings.HasPrefix(strings.ToLower(w.ID), strings.ToLower(id)) {
+			matches = append(matches, w)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return models.Workout{}, fmt.Errorf("no workout with id %q", id)
+	case 1:
+		return matches[0], nil
+	}
+	return models.Workout{}, fmt.Errorf("id %q matches %d workouts, give more of it", id, len(matches))
+}
@@ -0,0 +1,272 @@
+// data/route.go
+// Importer for GPX and TCX route files
+
+package data
+
+import (
+	"encoding/xml"
+	"fitness/models"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// earthRadiusMiles is used to compute distances between route points
+const earthRadiusMiles = 3958.8
+
+// Track is a named series of route points read from a GPX or TCX file
+type Track struct {
+	Name   string              // Track name or sport, used for standalone workouts
+	Points []models.RoutePoint // Points sorted by time
+	Start  time.Time           // Time of the first point
+	End    time.Time           // Time of the last point
+}
+
+// gpxFile mirrors the parts of a GPX document that hold track points
+type gpxFile struct {
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Segments []struct {
+			Points []struct {
+				Lat       float64 `xml:"lat,attr"`
+				Lon       float64 `xml:"lon,attr"`
+				Elevation float64 `xml:"ele"`
+				Time      string  `xml:"time"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// tcxFile mirrors the parts of a TCX document that hold track points
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Laps  []struct {
+			Points []struct {
+				Time     string `xml:"Time"`
+				Position *struct {
+					Lat float64 `xml:"LatitudeDegrees"`
+					Lon float64 `xml:"LongitudeDegrees"`
+				} `xml:"Position"`
+				Altitude float64 `xml:"AltitudeMeters"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// IsRouteFile reports whether a file is a GPX or TCX route file
+func IsRouteFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".gpx" || ext == ".tcx"
+}
+
+// ParseRouteFile reads the tracks of a GPX or TCX file
+func ParseRouteFile(path string) ([]Track, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseRoute(path, file)
+}
+
+// ParseRoute reads the tracks of a route file by the extension of its name, TCX or otherwise GPX
+func ParseRoute(name string, r io.Reader) ([]Track, error) {
+	if strings.EqualFold(filepath.Ext(name), ".tcx") {
+		return ParseTCX(r)
+	}
+	return ParseGPX(r)
+}
+
+// ParseGPX reads every track in a GPX document, joining its segments
+func ParseGPX(r io.Reader) ([]Track, error) {
+	var doc gpxFile
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing gpx: %v", err)
+	}
+
+	var tracks []Track
+	for _, trk := range doc.Tracks {
+		track := Track{Name: strings.TrimSpace(trk.Name)}
+		for _, segment := range trk.Segments {
+			for _, point := range segment.Points {
+				addTrackPoint(&track, point.Time, point.Lat, point.Lon, point.Elevation)
+			}
+		}
+		if finishTrack(&track) {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, nil
+}
+
+// ParseTCX reads every activity in a TCX document as a track, joining its laps
+func ParseTCX(r io.Reader) ([]Track, error) {
+	var doc tcxFile
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing tcx: %v", err)
+	}
+
+	var tracks []Track
+	for _, activity := range doc.Activities {
+		track := Track{Name: activity.Sport}
+		for _, lap := range activity.Laps {
+			for _, point := range lap.Points {
+				// Trackpoints without a position only carry sensor data
+				if point.Position == nil {
+					continue
+				}
+				addTrackPoint(&track, point.Time, point.Position.Lat, point.Position.Lon, point.Altitude)
+			}
+		}
+		if finishTrack(&track) {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, nil
+}
+
+// addTrackPoint appends a point to a track, skipping points without a valid time
+func addTrackPoint(track *Track, timestamp string, lat, lon, altitude float64) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(timestamp))
+	if err != nil {
+		return
+	}
+	track.Points = append(track.Points, models.RoutePoint{
+		Timestamp: models.NewTimestamp(t),
+		Latitude:  lat,
+		Longitude: lon,
+		Altitude:  altitude,
+	})
+}
+
+// finishTrack sorts the points of a track and records its time span
+// It returns false for tracks without any points
+func finishTrack(track *Track) bool {
+	if len(track.Points) == 0 {
+		return false
+	}
+	sort.SliceStable(track.Points, func(i, j int) bool {
+		return track.Points[i].Timestamp.Before(track.Points[j].Timestamp)
+	})
+	track.Start = track.Points[0].Timestamp.Time
+	track.End = track.Points[len(track.Points)-1].Timestamp.Time
+	return true
+}
+
+// AttachRoutes attaches each track to the workout it overlaps the most in time
+// Tracks that overlap no workout are returned as standalone workouts
+func AttachRoutes(workouts []models.Workout, tracks []Track) (attached []models.Workout, standalone []models.Workout) {
+	attached = make([]models.Workout, len(workouts))
+	copy(attached, workouts)
+
+	for _, track := range tracks {
+		if best := bestOverlap(attached, track.Start, track.End); best >= 0 {
+			attached[best].Route = track.Points
+			continue
+		}
+		standalone = append(standalone, trackWorkout(track))
+	}
+	return attached, standalone
+}
+
+// reattachRoutes moves the routes of standalone route workouts onto the imported workouts they overlap
+// A route file read before its workout would otherwise leave a duplicate workout behind
+func reattachRoutes(workouts []models.Workout) []models.Workout {
+	reattached := make(map[int]bool)
+	for i, w := range workouts {
+		if !w.RouteOnly || !w.Start.Valid() || !w.End.Valid() {
+			continue
+		}
+		best := bestOverlap(workouts, w.Start.Time, w.End.Time)
+		if best < 0 {
+			continue
+		}
+		// A workout with a route of its own keeps it, the standalone copy is dropped either way
+		if len(workouts[best].Route) == 0 {
+			workouts[best].Route = w.Route
+		}
+		reattached[i] = true
+	}
+	if len(reattached) == 0 {
+		return workouts
+	}
+
+	kept := make([]models.Workout, 0, len(workouts)-len(reattached))
+	for i, w := range workouts {
+		if !reattached[i] {
+			kept = append(kept, w)
+		}
+	}
+	return kept
+}
+
+// bestOverlap returns the index of the workout that overlaps a time span the most, or -1 if none does
+// Standalone route workouts are passed over, they only stand in for a workout yet to be imported
+func bestOverlap(workouts []models.Workout, start, end time.Time) int {
+	best, bestOverlap := -1, time.Duration(0)
+	for i, w := range workouts {
+		if w.RouteOnly || !w.Start.Valid() || !w.End.Valid() {
+			continue
+		}
+		if overlap := timeOverlap(w.Start.Time, w.End.Time, start, end); overlap > bestOverlap {
+			best, bestOverlap = i, overlap
+		}
+	}
+	return best
+}
+
+// trackWorkout builds a workout from a track that matched no existing workout
+func trackWorkout(track Track) models.Workout {
+	name := track.Name
+	if name == "" {
+		name = "Outdoor Route"
+	}
+	return models.Workout{
+		Name:      name,
+		Start:     track.Points[0].Timestamp,
+		End:       track.Points[len(track.Points)-1].Timestamp,
+		Duration:  track.End.Sub(track.Start).Seconds(),
+		Distance:  &models.Measurement{Units: "mi", Qty: RouteDistance(track.Points)},
+		Route:     track.Points,
+		RouteOnly: true,
+	}
+}
+
+// RouteDistance returns the length of a route in miles
+func RouteDistance(points []models.RoutePoint) float64 {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += haversine(points[i-1], points[i])
+	}
+	return total
+}
+
+// haversine returns the great-circle distance between two points in miles
+func haversine(a, b models.RoutePoint) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(h))
+}
+
+// timeOverlap returns how long two time intervals overlap
+func timeOverlap(start1, end1, start2, end2 time.Time) time.Duration {
+	start, end := start1, end1
+	if start2.After(start) {
+		start = start2
+	}
+	if end2.Before(end) {
+		end = end2
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -0,0 +1,124 @@
+// data/series.go
+// Side file keeping the routes and sample series of workouts apart from the cache
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"fitness/models"
+)
+
+// CorruptSeriesPath returns the n-th location a series file that cannot be read is moved to, counting from 1
+func CorruptSeriesPath(path string, n int) string {
+	if n == 1 {
+		return path + ".corrupt"
+	}
+	return fmt.Sprintf("%s.corrupt.%d", path, n)
+}
+
+// ReadSeriesFile reads the series of workouts from a series file, keyed by workout key
+// A missing file holds no series. A corrupted one is moved aside so the next save does not overwrite
+// it, and the series are recovered from the most recent readable backup, if there is one
+func ReadSeriesFile(path string) (map[string]models.WorkoutSeries, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := decodeSeries(content)
+	if err == nil {
+		return file.Workouts, nil
+	}
+
+	corrupt, renameErr := moveAside(path)
+	if renameErr != nil {
+		return nil, fmt.Errorf("series file %s is corrupted: %v", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: series file %s is corrupted (%v), it was moved to %s\n", path, err, corrupt)
+
+	// Fall back to the most recent backup that can still be read
+	for n := 1; n <= cacheBackups; n++ {
+		backup := BackupPath(path, n)
+		backupContent, readErr := os.ReadFile(backup)
+		if readErr != nil {
+			continue
+		}
+		if recovered, decodeErr := decodeSeries(backupContent); decodeErr == nil {
+			fmt.Fprintf(os.Stderr, "Recovered series from backup %s\n", backup)
+			return recovered.Workouts, nil
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Loading workouts without their series, no readable backup was found\n")
+	return nil, nil
+}
+
+// decodeSeries parses a series file
+func decodeSeries(content []byte) (models.SeriesFile, error) {
+	var file models.SeriesFile
+	err := json.Unmarshal(content, &file)
+	return file, err
+}
+
+// moveAside moves a corrupted series file to the first free corrupt location, keeping earlier ones
+func moveAside(path string) (string, error) {
+	for n := 1; ; n++ {
+		corrupt := CorruptSeriesPath(path, n)
+		_, err := os.Lstat(corrupt)
+		if os.IsNotExist(err) {
+			return corrupt, os.Rename(path, corrupt)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// WriteSeriesFile atomically replaces the series file with the given series, keeping the previous one as a backup
+func WriteSeriesFile(path string, series map[string]models.WorkoutSeries) error {
+	data, err := json.Marshal(models.SeriesFile{Workouts: series})
+	if err != nil {
+		return fmt.Errorf("error marshaling series: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating series directory: %v", err)
+	}
+	if err := rotateBackups(path, func(content []byte) error {
+		_, err := decodeSeries(content)
+		return err
+	}); err != nil {
+		return fmt.Errorf("error backing up series file: %v", err)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing series file: %v", err)
+	}
+	return nil
+}
+
+// SplitSeries takes the series off the workouts, returning the workouts without them and the series by workout key
+func SplitSeries(workouts []models.Workout) ([]models.Workout, map[string]models.WorkoutSeries) {
+	stripped := make([]models.Workout, len(workouts))
+	series := make(map[string]models.WorkoutSeries)
+	for i, w := range workouts {
+		if s := w.Series(); !s.IsEmpty() {
+			series[WorkoutKey(w)] = s
+			w.SetSeries(models.WorkoutSeries{})
+		}
+		stripped[i] = w
+	}
+	return stripped, series
+}
+
+// AttachSeries puts the series back on the workouts they belong to, in place
+// Workouts that already carry series in the cache keep them
+func AttachSeries(workouts []models.Workout, series map[string]models.WorkoutSeries) {
+	for i := range workouts {
+		if s, ok := series[WorkoutKey(workouts[i])]; ok && workouts[i].Series().IsEmpty() {
+			workouts[i].SetSeries(s)
+		}
+	}
+}
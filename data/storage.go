@@ -5,160 +5,260 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"regexp"
-	"sort"
-	"strings"
+	"path/filepath"
 	"time"
 
 	"fitness/config"
 	"fitness/models"
 )
 
-// AllWorkouts and AllMetrics are global variables that store all workout and metric data
-var (
-	AllWorkouts []models.Workout
-	AllMetrics  []models.Metric
-)
+// FileStore is a Store backed by a JSON cache file
+// The routes and sample series of workouts can be kept in a side file to keep the cache small
+type FileStore struct {
+	*MemoryStore
+	Path       string // Location of the cache file
+	SeriesPath string // Location of the series file, empty to keep the series in the cache
 
-// LoadCache reads the cache file and loads the data into the program
-func LoadCache(filename string) (*models.HealthData, error) {
-	// Read the cache file
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-	// Unmarshal the JSON data into a HealthData struct
-	var cache models.HealthData
-	if err := json.Unmarshal(data, &cache); err != nil {
-		return nil, err
-	}
-	AllWorkouts = append(AllWorkouts, cache.Data.Workouts...)
-	AllMetrics = append(AllMetrics, cache.Data.Metrics...)
+	recordedSeries string // Series file the loaded cache was saved with, kept while SeriesPath is empty
+}
 
-	return &cache, nil // Return a pointer to the HealthData struct
+// NewFileStore creates a store for the cache file at path, call Load to read it
+func NewFileStore(path string, policy MergePolicy) *FileStore {
+	return &FileStore{MemoryStore: NewMemoryStore(policy), Path: path}
 }
 
-// Load the new directory files into the program data
-func LoadDirectory(directoryPath string, cacheLastUpdated string) (bool, string, error) {
-	// Read the directory
-	files, err := os.ReadDir(directoryPath)
+// Load reads the cache file into the store, a missing file leaves the store empty
+// Older caches are migrated and a corrupted cache is recovered from its backups
+func (s *FileStore) Load() error {
+	// Read, migrate and if necessary recover the cache file
+	cache, rewrite, err := ReadCacheFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
-		return false, cacheLastUpdated, err
+		return err
 	}
 
-	// Sort files by name
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name() < files[j].Name()
-	})
-
-	// Prepare variables to track data updates
-	dataWasUpdated := false
-	latestFileDate := cacheLastUpdated
-	cacheDate, err := time.Parse(config.DateFormat, cacheLastUpdated)
-	if err != nil {
-		return false, cacheLastUpdated, err
+	// Warn when the series were saved to a side file that is not the one in use, they load without them
+	s.recordedSeries = cache.SeriesFile
+	if cache.SeriesFile != "" && cache.SeriesFile != s.SeriesPath {
+		fmt.Fprintf(os.Stderr, "Warning: workout series were saved to %s, set seriesFile or -series-file to it to load them\n", cache.SeriesFile)
 	}
 
-	// Iterate over files in the directory
-	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".json") {
-			continue
+	// Put back the series kept in the side file
+	if s.SeriesPath != "" {
+		series, err := ReadSeriesFile(s.SeriesPath)
+		if err != nil {
+			return err
 		}
+		AttachSeries(cache.Data.Workouts, series)
+	}
 
-		// Extract date from file name
-		re := regexp.MustCompile(config.DateRegexPattern)
-		matches := re.FindAllString(file.Name(), -1)
-		if len(matches) == 0 {
-			continue
-		}
-		fileDate := matches[len(matches)-1]
+	// A cache that held duplicates, was migrated or was recovered is rewritten
+	s.replace(*cache, rewrite)
+	return nil
+}
 
-		// Parse and compare dates
-		currentFileDate, err := time.Parse(config.DateFormat, fileDate)
-		if err != nil {
-			fmt.Printf("Error parsing date for file %s: %v\n", file.Name(), err)
-			continue
+// Save writes the store back to the cache file, and then the series to the series file if there is one
+// The cache records the series file, and keeps recording the one it was loaded with while none is set
+func (s *FileStore) Save() error {
+	snapshot := s.Snapshot()
+	latestUpdate := time.Now().Format(config.DateFormat)
+	cache := models.HealthData{
+		Data:        snapshot.Data,
+		LastUpdated: &latestUpdate,
+		Manifest:    snapshot.Manifest,
+		SeriesFile:  s.recordedSeries,
+	}
+	var series map[string]models.WorkoutSeries
+	if s.SeriesPath != "" {
+		cache.Data.Workouts, series = SplitSeries(cache.Data.Workouts)
+		cache.SeriesFile = s.SeriesPath
+	}
+
+	if err := writeCache(s.Path, cache); err != nil {
+		return err
+	}
+	if s.SeriesPath != "" {
+		if err := WriteSeriesFile(s.SeriesPath, series); err != nil {
+			return err
 		}
+	}
+	s.recordedSeries = cache.SeriesFile
+	s.markSaved(latestUpdate)
+	fmt.Printf("Cache updated on: %s\n", latestUpdate)
+	return nil
+}
 
-		// Only process files newer than our cache
-		if currentFileDate.After(cacheDate) {
-			fmt.Printf("Processing new data from: %s\n", fileDate)
+// Load the new and changed directory files into the store
+// The returned flag reports whether the store or its manifest changed
+func LoadDirectory(store Store, directoryPath string) (bool, error) {
+	// Compare the directory contents with the manifest
+	files, err := ScanDirectory(directoryPath, store.Manifest())
+	if err != nil {
+		return false, err
+	}
+	return IngestFiles(store, files), nil
+}
 
-			// Read and parse file
-			filePath := directoryPath + "/" + file.Name()
-			content, err := os.ReadFile(filePath)
-			if err != nil {
-				continue
-			}
+// ImportRouteFile attaches the tracks of a GPX or TCX file to the stored workouts
+// Tracks that match no workout are added as standalone workouts
+func ImportRouteFile(store Store, path string) (int, string, error) {
+	hash, err := hashFile(path)
+	if err != nil {
+		return 0, "", err
+	}
+	tracks, err := ParseRouteFile(path)
+	if err != nil {
+		return 0, hash, err
+	}
 
-			// Unmarshal JSON data into HealthData struct
-			var fileData models.HealthData
-			if err := json.Unmarshal(content, &fileData); err != nil {
-				fmt.Printf("Error unmarshaling file %s: %v\n", file.Name(), err)
-				continue
-			}
+	attached, standalone := AttachRoutes(store.Workouts(AllTime), tracks)
+	store.UpsertWorkouts(append(attached, standalone...))
+	return len(tracks), hash, nil
+}
 
-			// Sort data before adding to collections
-			sort.Slice(fileData.Data.Workouts, func(i, j int) bool {
-				return fileData.Data.Workouts[i].Start < fileData.Data.Workouts[j].Start
-			})
+// Reads the cache file, loads the directory data, writes to the cache if new data is found
+func ImportData(cfg *config.Config) (*FileStore, error) {
+	store, err := OpenStore(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-			// Update our data collections
-			AllWorkouts = append(AllWorkouts, fileData.Data.Workouts...)
-			AllMetrics = append(AllMetrics, fileData.Data.Metrics...)
-			dataWasUpdated = true
+	// Process directory into the store
+	if _, err := LoadDirectory(store, cfg.ICloudDirPath); err != nil {
+		return nil, fmt.Errorf("failed to load directory: %v", err)
+	}
 
-			// Keep track of the latest file date
-			if currentFileDate.After(cacheDate) {
-				latestFileDate = fileDate
-			}
+	// Only write to cache if we found new data or the cache needs rewriting
+	if store.Changed() {
+		if err := store.Save(); err != nil {
+			return nil, fmt.Errorf("failed to write cache: %v", err)
 		}
+	} else {
+		// fmt.Println("No new data found, cache remains current")
 	}
-
-	return dataWasUpdated, latestFileDate, nil
+	fmt.Println()
+	return store, nil
 }
 
-// Reads the cache file, loads the directory data, writes to the cache if new data is found
-func ImportData() {
-	// Get environment variables
-	// config.ICloudDirPath = os.Getenv("ICLOUD_DIR_PATH")
-	// config.CacheFilePath = os.Getenv("CACHE_FILE_PATH")
+// ImportAppleExport merges an Apple Health export archive into the store
+// An archive that is unchanged since it was last ingested is skipped
+func ImportAppleExport(store Store, exportPath string) error {
+	// Skip the archive if the manifest shows it was already ingested
+	info, err := os.Stat(exportPath)
+	if err != nil {
+		return err
+	}
+	file, err := checkFile(exportPath, info, store.Manifest())
+	if err != nil {
+		return err
+	}
+	if !file.NeedsIngest() {
+		fmt.Printf("%s is already %s\n", filepath.Base(exportPath), file.Status)
+		return nil
+	}
 
-	// Load cache file
-	cache, err := LoadCache(config.CacheFilePath)
+	// Stream the export and merge it into the stored data
+	fmt.Printf("Processing Apple Health export: %s\n", filepath.Base(exportPath))
+	export, err := ParseAppleExport(exportPath)
 	if err != nil {
+		return err
+	}
+	exportData := export.Health
+	printWarnings(filepath.Base(exportPath), TimestampWarnings(exportData))
+	for _, warning := range export.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", filepath.Base(exportPath), warning)
+	}
+	store.UpsertWorkouts(exportData.Data.Workouts)
+	store.UpsertMetrics(exportData.Data.Metrics)
 
-		panic(fmt.Sprintf("Failed to load cache: %v", err))
+	// Record the archive in the manifest
+	hash := file.Hash
+	if hash == "" {
+		if hash, err = hashFile(exportPath); err != nil {
+			return err
+		}
 	}
+	store.RecordFile(models.ManifestEntry{
+		Path:       exportPath,
+		Size:       info.Size(),
+		ModTime:    info.ModTime(),
+		Hash:       hash,
+		Workouts:   len(exportData.Data.Workouts),
+		Metrics:    countDataPoints(exportData.Data.Metrics),
+		Status:     models.FileIngested,
+		IngestedAt: time.Now(),
+	})
+	fmt.Printf("Read %d workouts and %d metric data points\n", len(exportData.Data.Workouts), countDataPoints(exportData.Data.Metrics))
+	return nil
+}
 
-	// Process directory and get update status
-	wasUpdated, latestUpdate, err := LoadDirectory(config.ICloudDirPath, *cache.LastUpdated)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to load directory: %v", err))
+// ImportRoutes attaches the GPX and TCX files at a path, either a file or a directory, to the stored workouts
+func ImportRoutes(store Store, routePath string) error {
+	// Collect the route files to import
+	paths := []string{routePath}
+	if info, err := os.Stat(routePath); err != nil {
+		return err
+	} else if info.IsDir() {
+		entries, err := os.ReadDir(routePath)
+		if err != nil {
+			return err
+		}
+		paths = nil
+		for _, entry := range entries {
+			if !entry.IsDir() && IsRouteFile(entry.Name()) {
+				paths = append(paths, filepath.Join(routePath, entry.Name()))
+			}
+		}
 	}
 
-	// Only write to cache if we found new data
-	if wasUpdated {
-		if err := WriteToCache(AllWorkouts, AllMetrics, &latestUpdate); err != nil {
-			panic(fmt.Sprintf("Failed to write cache: %v", err))
+	before := len(store.Workouts(AllTime))
+	total := 0
+	for _, path := range paths {
+		tracks, _, err := ImportRouteFile(store, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", filepath.Base(path), err)
+			continue
 		}
-		fmt.Printf("Cache updated with data through: %s\n", latestUpdate)
-	} else {
-		// fmt.Println("No new data found, cache remains current")
+		total += tracks
 	}
-	fmt.Println()
+	fmt.Printf("Read %d tracks from %d files, %d added as new workouts\n", total, len(paths), len(store.Workouts(AllTime))-before)
+	return nil
+}
+
+// OpenStore creates the file store configured by cfg and loads the cache into it
+// An invalid merge policy is reported as such, before the cache is read
+func OpenStore(cfg *config.Config) (*FileStore, error) {
+	policy, err := ParseMergePolicy(cfg.MergePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge policy: %v", err)
+	}
+
+	store := NewFileStore(cfg.CacheFilePath, policy)
+	store.SeriesPath = cfg.SeriesFilePath
+	if err := store.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load cache: %v", err)
+	}
+	return store, nil
 }
 
 // WriteToCache writes the data to the cache file
-func WriteToCache(AllWorkouts []models.Workout, AllMetrics []models.Metric, lastUpdated *string) error {
+func WriteToCache(cachePath string, AllWorkouts []models.Workout, AllMetrics []models.Metric, manifest models.Manifest, lastUpdated *string) error {
 	// Create the HealthData structure to match the original format
-	healthData := models.HealthData{
+	return writeCache(cachePath, models.HealthData{
 		Data: models.DataCollection{
 			Workouts: AllWorkouts,
 			Metrics:  AllMetrics,
 		},
 		LastUpdated: lastUpdated,
-	}
+		Manifest:    manifest,
+	})
+}
+
+// writeCache writes a cache document in the current schema version to the cache file
+func writeCache(cachePath string, healthData models.HealthData) error {
+	healthData.SchemaVersion = CacheSchemaVersion
 
 	// Marshal the HealthData structure into JSON
 	data, err := json.MarshalIndent(healthData, "", "  ")
@@ -166,11 +266,18 @@ func WriteToCache(AllWorkouts []models.Workout, AllMetrics []models.Metric, last
 		return fmt.Errorf("error marshaling data: %v", err)
 	}
 
-	// Write the JSON data to cache.json
-	err = os.WriteFile(config.CacheFilePath, data, 0644)
-	if err != nil {
+	// Make sure the cache directory exists before writing
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return fmt.Errorf("error creating cache directory: %v", err)
+	}
+
+	// Keep the previous cache as a backup, then atomically replace it
+	if err := rotateBackups(cachePath, decodesAsCache); err != nil {
+		return fmt.Errorf("error backing up cache: %v", err)
+	}
+	if err := writeFileAtomic(cachePath, data, 0644); err != nil {
 		return fmt.Errorf("error writing to file: %v", err)
 	}
-	fmt.Printf("Data written to %s\n", config.CacheFilePath)
+	fmt.Printf("Data written to %s\n", cachePath)
 	return nil
 }
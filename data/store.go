@@ -0,0 +1,238 @@
+// data/store.go
+// Storage backends for workout and metric data
+
+package data
+
+import (
+	"fitness/models"
+	"sync"
+	"time"
+)
+
+// Store holds workout and metric data behind a storage backend
+type Store interface {
+	Load() error                              // Load data from the backing storage
+	Save() error                              // Persist data to the backing storage
+	Workouts(r TimeRange) []models.Workout    // Workouts that start within the range, by start time
+	Metrics(r TimeRange) []models.Metric      // Metrics with their data points within the range
+	UpsertWorkouts(workouts []models.Workout) // Insert workouts, merging duplicates by workout key
+	UpsertMetrics(metrics []models.Metric)    // Insert metric data points, merging duplicates by name and date
+	DeleteWorkout(key string) bool            // Remove a workout by its workout key
+	Manifest() models.Manifest                // Copy of the files that have been ingested
+	RecordFile(entry models.ManifestEntry)    // Add or replace a file in the manifest
+	Snapshot() models.HealthData              // Copy of all stored data
+}
+
+// TimeRange selects records by time, a zero bound leaves that side open
+// A bound at midnight starts a calendar day, and records are placed on days by the zone policy
+type TimeRange struct {
+	From time.Time   // Inclusive lower bound
+	To   time.Time   // Exclusive upper bound
+	Zone models.Zone // Time zone policy deciding which day a record falls on
+}
+
+// AllTime is the range that selects every record
+var AllTime = TimeRange{}
+
+// IsAllTime reports whether neither bound of the range is set
+func (r TimeRange) IsAllTime() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+// Contains reports whether a time falls within the range
+func (r TimeRange) Contains(t time.Time) bool {
+	if !r.From.IsZero() && t.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && !t.Before(r.To) {
+		return false
+	}
+	return true
+}
+
+// containsTimestamp checks a record's timestamp against the range
+// Against a day bound the record's wall-clock time in the zone policy counts, so a range
+// selects the same days as the date filters and the calendar buckets
+// Unparseable timestamps are only selected when the range is unbounded
+func (r TimeRange) containsTimestamp(ts models.Timestamp) bool {
+	if r.IsAllTime() {
+		return true
+	}
+	if !ts.Valid() {
+		return false
+	}
+	t := r.Zone.Time(ts)
+	if !r.From.IsZero() && wallClock(t, r.From).Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && !wallClock(t, r.To).Before(r.To) {
+		return false
+	}
+	return true
+}
+
+// wallClock places t on the calendar of a day bound, keeping its date and time of day
+// Bounds that are not at midnight are instants, and t is compared as it is
+func wallClock(t, bound time.Time) time.Time {
+	if !bound.Equal(time.Date(bound.Year(), bound.Month(), bound.Day(), 0, 0, 0, 0, bound.Location())) {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), bound.Location())
+}
+
+// MemoryStore keeps all data in memory, it is safe for concurrent use
+type MemoryStore struct {
+	mu          sync.RWMutex
+	policy      MergePolicy
+	workouts    []models.Workout
+	metrics     []models.Metric
+	manifest    models.Manifest
+	lastUpdated *string
+	changed     bool
+}
+
+// NewMemoryStore creates an empty store that resolves duplicates with the given policy
+func NewMemoryStore(policy MergePolicy) *MemoryStore {
+	return &MemoryStore{policy: policy, manifest: make(models.Manifest)}
+}
+
+// Load does nothing, a memory store has no backing storage
+func (s *MemoryStore) Load() error {
+	return nil
+}
+
+// Save does nothing, a memory store has no backing storage
+func (s *MemoryStore) Save() error {
+	return nil
+}
+
+// Workouts returns the workouts that start within the range
+func (s *MemoryStore) Workouts(r TimeRange) []models.Workout {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Workout, 0, len(s.workouts))
+	for _, w := range s.workouts {
+		if r.containsTimestamp(w.Start) {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// Metrics returns every metric with its data points limited to the range
+// Metrics without any data in a bounded range are left out
+func (s *MemoryStore) Metrics(r TimeRange) []models.Metric {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Metric, 0, len(s.metrics))
+	for _, m := range s.metrics {
+		metric := models.Metric{Name: m.Name, Units: m.Units}
+		for _, d := range m.Data {
+			if r.containsTimestamp(d.Date) {
+				metric.Data = append(metric.Data, d)
+			}
+		}
+		if len(metric.Data) > 0 || r.IsAllTime() {
+			result = append(result, metric)
+		}
+	}
+	return result
+}
+
+// UpsertWorkouts merges workouts into the store using the store's merge policy
+func (s *MemoryStore) UpsertWorkouts(workouts []models.Workout) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workouts = MergeWorkouts(s.workouts, workouts, s.policy)
+	s.changed = true
+}
+
+// UpsertMetrics merges metric data points into the store using the store's merge policy
+func (s *MemoryStore) UpsertMetrics(metrics []models.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = MergeMetrics(s.metrics, metrics, s.policy)
+	s.changed = true
+}
+
+// DeleteWorkout removes the workout with the given key, reporting whether it existed
+func (s *MemoryStore) DeleteWorkout(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, w := range s.workouts {
+		if WorkoutKey(w) == key {
+			s.workouts = append(s.workouts[:i], s.workouts[i+1:]...)
+			s.changed = true
+			return true
+		}
+	}
+	return false
+}
+
+// Manifest returns a copy of the ingested files
+func (s *MemoryStore) Manifest() models.Manifest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	manifest := make(models.Manifest, len(s.manifest))
+	for path, entry := range s.manifest {
+		manifest[path] = entry
+	}
+	return manifest
+}
+
+// RecordFile adds or replaces a file in the manifest
+func (s *MemoryStore) RecordFile(entry models.ManifestEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.manifest[entry.Path] = entry
+	s.changed = true
+}
+
+// Snapshot returns a copy of all data in the store
+func (s *MemoryStore) Snapshot() models.HealthData {
+	manifest := s.Manifest()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return models.HealthData{
+		Data: models.DataCollection{
+			Workouts: append([]models.Workout(nil), s.workouts...),
+			Metrics:  append([]models.Metric(nil), s.metrics...),
+		},
+		LastUpdated: s.lastUpdated,
+		Manifest:    manifest,
+	}
+}
+
+// Changed reports whether the store was modified since it was loaded or saved
+func (s *MemoryStore) Changed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.changed
+}
+
+// replace swaps in a complete data set, canonicalizing it with the merge policy
+// The store is marked changed if rewrite is set or canonicalizing removed any duplicates
+func (s *MemoryStore) replace(health models.HealthData, rewrite bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.workouts = MergeWorkouts(nil, health.Data.Workouts, s.policy)
+	s.metrics = MergeMetrics(nil, health.Data.Metrics, s.policy)
+	s.lastUpdated = health.LastUpdated
+	s.manifest = health.Manifest
+	if s.manifest == nil {
+		s.manifest = make(models.Manifest)
+	}
+	s.changed = rewrite || len(s.workouts) != len(health.Data.Workouts) || len(s.metrics) != len(health.Data.Metrics)
+}
+
+// markSaved records a successful save at the given date
+func (s *MemoryStore) markSaved(lastUpdated string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUpdated = &lastUpdated
+	s.changed = false
+}
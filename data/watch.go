@@ -0,0 +1,146 @@
+// data/watch.go
+// Continuous ingest of export files as they appear in the export directory
+
+package data
+
+import (
+	"context"
+	"fitness/models"
+	"fmt"
+	"time"
+)
+
+// Default timings of a Watcher
+const (
+	DefaultWatchInterval = 30 * time.Second // Time between directory scans
+	DefaultWatchSettle   = 10 * time.Second // Time a file must be left alone before it is read
+)
+
+// Watcher polls a directory and ingests new and changed export files into a store
+type Watcher struct {
+	Store    Store         // Store the files are ingested into
+	Save     func() error  // Persists the store after new data was ingested, may be nil
+	Dir      string        // Directory to watch
+	Interval time.Duration // Time between directory scans
+	Settle   time.Duration // Time a file's size and mtime must stay unchanged before it is ingested
+
+	seen    map[string]fileState // Size and mtime of each pending file at the previous scan
+	unsaved bool                 // Whether ingested data could not be saved yet
+}
+
+// fileState is the size and mtime of a file at the time it was scanned
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// WatchSummary describes what a single poll of the directory added to the store
+type WatchSummary struct {
+	Files    int // Number of files ingested
+	Failed   int // Number of files that could not be ingested
+	Waiting  int // Number of files still being written or synced
+	Workouts int // Number of workouts added to the store
+	Metrics  int // Number of metric data points added to the store
+}
+
+// String formats the summary as a single line
+func (s WatchSummary) String() string {
+	line := fmt.Sprintf("ingested %d files: +%d workouts, +%d metric data points", s.Files, s.Workouts, s.Metrics)
+	if s.Failed > 0 {
+		line += fmt.Sprintf(", %d failed", s.Failed)
+	}
+	if s.Waiting > 0 {
+		line += fmt.Sprintf(", %d still syncing", s.Waiting)
+	}
+	return line
+}
+
+// NewWatcher creates a watcher for dir with the default interval and settle time
+func NewWatcher(store Store, dir string) *Watcher {
+	return &Watcher{Store: store, Dir: dir, Interval: DefaultWatchInterval, Settle: DefaultWatchSettle}
+}
+
+// Run polls the directory until the context is cancelled, reporting each poll that ingested files
+// A failed poll, e.g. while iCloud is still syncing the directory, is reported and retried at the next interval
+// A poll in progress is always finished, so the store is saved before Run returns
+func (w *Watcher) Run(ctx context.Context, report func(WatchSummary), fail func(error)) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		summary, err := w.Poll()
+		switch {
+		case err != nil && fail != nil:
+			fail(err)
+		case err == nil && summary.Files > 0 && report != nil:
+			report(summary)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll scans the directory once and ingests every file that has finished syncing
+// Data that a previous poll could not save is saved again
+func (w *Watcher) Poll() (WatchSummary, error) {
+	var summary WatchSummary
+	files, err := ScanDirectory(w.Dir, w.Store.Manifest())
+	if err != nil {
+		return summary, err
+	}
+
+	// Hold back files that are still being written, they are picked up by a later poll
+	seen := make(map[string]fileState)
+	var ready []FileStatus
+	for _, file := range files {
+		if !file.NeedsIngest() {
+			ready = append(ready, file) // Lets the manifest refresh touched files
+			continue
+		}
+		state := fileState{size: file.Size, modTime: file.ModTime}
+		if !w.settled(file.Path, state) {
+			seen[file.Path] = state
+			summary.Waiting++
+			continue
+		}
+		ready = append(ready, file)
+		summary.Files++
+	}
+	w.seen = seen
+
+	// Ingest through the same path as LoadDirectory, measuring what was added
+	workouts, metrics := len(w.Store.Workouts(AllTime)), countDataPoints(w.Store.Metrics(AllTime))
+	if IngestFiles(w.Store, ready) {
+		summary.Workouts = len(w.Store.Workouts(AllTime)) - workouts
+		summary.Metrics = countDataPoints(w.Store.Metrics(AllTime)) - metrics
+
+		manifest := w.Store.Manifest()
+		for _, file := range ready {
+			if file.NeedsIngest() && manifest[file.Path].Status != models.FileIngested {
+				summary.Failed++
+			}
+		}
+		w.unsaved = true
+	}
+
+	if w.unsaved && w.Save != nil {
+		if err := w.Save(); err != nil {
+			return summary, fmt.Errorf("failed to write cache: %v", err)
+		}
+	}
+	w.unsaved = false
+	return summary, nil
+}
+
+// settled reports whether a file has been left alone long enough to be read
+// A file is settled once it is unchanged since the previous scan or older than the settle time
+func (w *Watcher) settled(path string, state fileState) bool {
+	if previous, ok := w.seen[path]; ok && previous.size == state.size && previous.modTime.Equal(state.modTime) {
+		return true
+	}
+	return time.Since(state.modTime) >= w.Settle
+}
@@ -0,0 +1,377 @@
+// fields/fields.go
+// Registry of the fields of workouts and metrics, shared by printers, filters and sorting
+
+package fields
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/semantics"
+	"fitness/utils"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kind is the kind of a field's values
+type Kind int
+
+const (
+	Text     Kind = iota // Text compared case-insensitively
+	Number               // Number compared as recorded, e.g. intensity or an hour of the day
+	Quantity             // Number with a unit, converted to the display units
+	Time                 // Timestamp
+)
+
+// Value is the value of a field of one record
+type Value struct {
+	Text    string              // Value of a text field
+	Values  []string            // Every value of a text field that has several, such as tags
+	Time    models.Timestamp    // Value of a time field
+	Measure *models.Measurement // Value of a number or quantity field
+	Missing bool                // Whether the record has no value for the field
+}
+
+// TextValue is the value of a text field
+func TextValue(s string) Value { return Value{Text: s} }
+
+// ListValue is the value of a text field with several values, missing if there are none
+// Its text is the values separated by commas
+func ListValue(values []string) Value {
+	return Value{Text: strings.Join(values, ", "), Values: values, Missing: len(values) == 0}
+}
+
+// TimeValue is the value of a time field, missing if the timestamp is zero
+func TimeValue(ts models.Timestamp) Value { return Value{Time: ts, Missing: ts.IsZero()} }
+
+// MeasureValue is the value of a number or quantity field, missing if there is no measurement
+func MeasureValue(m *models.Measurement) Value { return Value{Measure: m, Missing: m == nil} }
+
+// NumberValue is the value of a number field without a unit
+func NumberValue(n float64) Value { return MeasureValue(&models.Measurement{Qty: n}) }
+
+// SecondsValue is the value of a duration field
+func SecondsValue(seconds float64) Value {
+	return MeasureValue(&models.Measurement{Qty: seconds, Units: quantity.Second.Symbol})
+}
+
+// missing is the value of a field the record does not have
+var missing = Value{Missing: true}
+
+// Context holds the settings that field values are read and formatted with
+type Context struct {
+	Units      quantity.System     // Display units
+	Zone       models.Zone         // Time zone of times and calendar parts
+	TimeFormat string              // Layout of times, for fields without a layout of their own
+	Metrics    *semantics.Registry // Descriptions of metrics, the built-in ones if nil
+}
+
+// Field describes a field of a record of type T
+type Field[T any] struct {
+	Name      string                              // Canonical lower-case name
+	Aliases   []string                            // Alternative names, e.g. calories for energy
+	Kind      Kind                                // Kind of the field's values
+	Dimension quantity.Dimension                  // Dimension of a quantity field, empty if it depends on each value's unit
+	Unit      func(quantity.System) quantity.Unit // Display unit of a quantity field, the system's unit for its dimension if nil
+	Title     string                              // Heading of the field's column in tables
+	Label     string                              // Label of the field in detailed listings, the title if empty
+	Width     int                                 // Default column width
+	Precision int                                 // Decimals shown for numbers and quantities
+	Layout    string                              // Layout of a time field, the context's if empty
+	Detail    bool                                // Whether detailed listings show the field by default
+	Table     bool                                // Whether tables show the field by default
+	Reading   bool                                // Whether detailed listings show the field labelled after the value, e.g. min or a sleep stage
+	Get       func(T, Context) Value              // Reads the field from a record
+	Format    func(Value, Context) string         // Formats a present value, replacing the default format
+	Rollup    func([]T, Context) Value            // Combines the values of several records the way the field's values add up, if they do
+}
+
+// Heading is the label of the field in detailed listings
+func (f *Field[T]) Heading() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Title
+}
+
+// ColumnWidth is the width of the field's column, wide enough for its title
+func (f *Field[T]) ColumnWidth() int {
+	return max(f.Width, len(f.Title))
+}
+
+// DisplayUnit is the unit a quantity field is shown in, and numbers without a unit are read in
+func (f *Field[T]) DisplayUnit(system quantity.System) quantity.Unit {
+	if f.Unit != nil {
+		return f.Unit(system)
+	}
+	return system.Unit(f.Dimension)
+}
+
+// Number returns a number or quantity in the display units, false if it is missing
+// Quantities whose unit depends on the record are already in display units, and quantities in
+// units that do not convert are returned as recorded
+func (f *Field[T]) Number(v Value, ctx Context) (float64, bool) {
+	if v.Missing || v.Measure == nil {
+		return 0, false
+	}
+	switch {
+	case f.Kind == Number, f.Dimension == "":
+		return v.Measure.Qty, true
+	}
+	if q, err := quantity.FromMeasurement(v.Measure); err == nil {
+		if converted, err := q.In(f.DisplayUnit(ctx.Units)); err == nil {
+			return converted.Value, true
+		}
+	}
+	return v.Measure.Qty, true
+}
+
+// String formats a value for display, "-" if it is missing
+// Quantities whose unit depends on the record are shown without it, next to a units column or heading
+func (f *Field[T]) String(v Value, ctx Context) string {
+	if v.Missing {
+		return "-"
+	}
+	if f.Format != nil {
+		return f.Format(v, ctx)
+	}
+
+	switch f.Kind {
+	case Text:
+		return v.Text
+	case Time:
+		layout := f.Layout
+		if layout == "" {
+			layout = ctx.TimeFormat
+		}
+		return ctx.Zone.Format(v.Time, layout)
+	}
+
+	number, _ := f.Number(v, ctx)
+	units := v.Measure.Units
+	switch {
+	case f.Dimension == quantity.Duration:
+		return utils.FormatTime(number)
+	case f.Kind == Quantity && f.Dimension == "":
+		units = ""
+	case f.Kind == Quantity:
+		units = ctx.Units.Measurement(*v.Measure).Units
+	}
+	switch units {
+	case "":
+		return fmt.Sprintf("%.*f", f.Precision, number)
+	case "%":
+		return fmt.Sprintf("%.*f%%", f.Precision, number)
+	}
+	return fmt.Sprintf("%.*f %s", f.Precision, number, units)
+}
+
+// Set is a set of fields that can be looked up by name
+type Set interface {
+	Has(name string) bool // Whether a field has the name or alias
+	Names() []string      // Canonical names of the fields, in order
+	Spellings() []string  // Every name and alias of the fields
+}
+
+// List is a set of fields that only have names, such as the columns of a table of totals
+type List []string
+
+// Has reports whether the list contains the name, ignoring case
+func (l List) Has(name string) bool {
+	for _, n := range l {
+		if strings.EqualFold(n, strings.TrimSpace(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the names in the list
+func (l List) Names() []string {
+	return l
+}
+
+// Spellings returns the names in the list, which have no aliases
+func (l List) Spellings() []string {
+	return l
+}
+
+// Registry lists the fields of a kind of record, in their default order
+type Registry[T any] struct {
+	fields []*Field[T]
+	byName map[string]*Field[T]
+}
+
+// NewRegistry builds a registry of fields, indexing them by name and alias
+func NewRegistry[T any](fields ...*Field[T]) *Registry[T] {
+	r := &Registry[T]{fields: fields, byName: make(map[string]*Field[T])}
+	for _, f := range fields {
+		r.byName[f.Name] = f
+		for _, alias := range f.Aliases {
+			r.byName[alias] = f
+		}
+	}
+	return r
+}
+
+// All returns every field in order
+func (r *Registry[T]) All() []*Field[T] {
+	return r.fields
+}
+
+// Lookup finds a field by name or alias, ignoring case
+func (r *Registry[T]) Lookup(name string) (*Field[T], bool) {
+	f, ok := r.byName[strings.ToLower(strings.TrimSpace(name))]
+	return f, ok
+}
+
+// Has reports whether a field has the name or alias
+func (r *Registry[T]) Has(name string) bool {
+	_, ok := r.Lookup(name)
+	return ok
+}
+
+// Names returns the canonical names of the fields in order
+func (r *Registry[T]) Names() []string {
+	var names []string
+	for _, f := range r.fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Spellings returns every name and alias of the fields
+func (r *Registry[T]) Spellings() []string {
+	var spellings []string
+	for _, f := range r.fields {
+		spellings = append(spellings, f.Name)
+		spellings = append(spellings, f.Aliases...)
+	}
+	return spellings
+}
+
+// Select picks the fields to show: the included ones in the order given, or the defaults
+// in registry order, less the excluded ones. Names the registry does not know are an error.
+func (r *Registry[T]) Select(include, exclude []string, defaults func(*Field[T]) bool) ([]*Field[T], error) {
+	excluded := make(map[*Field[T]]bool)
+	for _, name := range exclude {
+		f, ok := r.Lookup(name)
+		if !ok {
+			return nil, Unknown("field", name, r)
+		}
+		excluded[f] = true
+	}
+
+	var selected []*Field[T]
+	seen := make(map[*Field[T]]bool)
+	add := func(f *Field[T]) {
+		if !excluded[f] && !seen[f] {
+			selected = append(selected, f)
+			seen[f] = true
+		}
+	}
+	if len(include) == 0 {
+		for _, f := range r.fields {
+			if defaults(f) {
+				add(f)
+			}
+		}
+		return selected, nil
+	}
+	for _, name := range include {
+		f, ok := r.Lookup(name)
+		if !ok {
+			return nil, Unknown("field", name, r)
+		}
+		add(f)
+	}
+	return selected, nil
+}
+
+// Check reports an error for the first name that none of the sets know
+func Check(what string, names []string, sets ...Set) error {
+	for _, name := range names {
+		known := false
+		for _, s := range sets {
+			known = known || s.Has(name)
+		}
+		if !known {
+			return Unknown(what, name, sets...)
+		}
+	}
+	return nil
+}
+
+// Unknown is the error for an unknown field name, suggesting the closest known names
+// or listing them all if none is close
+func Unknown(what, name string, sets ...Set) error {
+	var names, spellings []string
+	seen := make(map[string]bool)
+	for _, s := range sets {
+		for _, n := range s.Names() {
+			if !seen[n] {
+				names = append(names, n)
+				seen[n] = true
+			}
+		}
+		spellings = append(spellings, s.Spellings()...)
+	}
+	if suggestions := Suggest(name, spellings); len(suggestions) > 0 {
+		return fmt.Errorf("unknown %s %q (did you mean %s?)", what, name, strings.Join(suggestions, " or "))
+	}
+	return fmt.Errorf("unknown %s %q (expected one of %s)", what, name, strings.Join(names, ", "))
+}
+
+// Suggest returns up to three names close to a misspelled one, closest first
+// A name is close if it starts with the misspelling or is one edit away from it, two for longer names
+func Suggest(name string, names []string) []string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	limit := 1
+	if len(name) > 4 {
+		limit = 2
+	}
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	seen := make(map[string]bool)
+	for _, n := range names {
+		distance := editDistance(name, n)
+		if name != "" && strings.HasPrefix(n, name) {
+			distance = min(distance, 1)
+		}
+		if distance <= limit && !seen[n] {
+			candidates = append(candidates, candidate{n, distance})
+			seen[n] = true
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < 3; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// editDistance is the number of single letter insertions, deletions and substitutions between two words
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,209 @@
+// fields/metric.go
+// Fields of metrics and of their data points
+
+package fields
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/semantics"
+	"strings"
+	"time"
+)
+
+// Point is a data point together with the metric it belongs to
+type Point struct {
+	Metric models.Metric
+	Data   models.MetricData
+}
+
+// PointsOf lists the data points of the metrics in order, each with its metric
+func PointsOf(metrics []models.Metric) []Point {
+	var points []Point
+	for _, m := range metrics {
+		for _, d := range m.Data {
+			points = append(points, Point{Metric: m, Data: d})
+		}
+	}
+	return points
+}
+
+// info describes a metric with the context's metric descriptions
+func info(m models.Metric, ctx Context) semantics.Info {
+	return ctx.Metrics.Info(m.Name)
+}
+
+// measure is a value of a metric in its display units, the canonical units in the context's unit system
+func measure(m models.Metric, qty float64, ctx Context) *models.Measurement {
+	display := info(m, ctx).InSystem(models.Measurement{Qty: qty, Units: m.Units}, ctx.Units)
+	return &display
+}
+
+// displayUnits is the unit a metric's values are shown in
+func displayUnits(m models.Metric, ctx Context) Value {
+	return TextValue(measure(m, 0, ctx).Units)
+}
+
+// reading is an optional reading of a metric, such as the smallest heart rate of a point
+func reading(m models.Metric, qty *float64, ctx Context) Value {
+	if qty == nil {
+		return missing
+	}
+	return MeasureValue(measure(m, *qty, ctx))
+}
+
+// hours is an optional number of hours, such as the time spent in a sleep stage, shown as a number like the value
+func hours(qty *float64) Value {
+	if qty == nil {
+		return missing
+	}
+	return MeasureValue(&models.Measurement{Qty: *qty, Units: quantity.Hour.Symbol})
+}
+
+// optionalTime is the value of an optional time field
+func optionalTime(ts *models.Timestamp) Value {
+	if ts == nil {
+		return missing
+	}
+	return TimeValue(*ts)
+}
+
+// Valid reports whether a data point's value lies in its metric's valid range
+func Valid(p Point, ctx Context) bool {
+	return info(p.Metric, ctx).Valid(models.Measurement{Qty: p.Data.Qty, Units: p.Metric.Units})
+}
+
+// rollup combines the values of data points the way their metric's values add up over a period
+// Points of different metrics do not combine, and values outside the metric's valid range are left out
+func rollup(points []Point, ctx Context) Value {
+	if len(points) == 0 {
+		return missing
+	}
+	metric := points[0].Metric
+	var values []Point
+	for _, p := range points {
+		if !strings.EqualFold(p.Metric.Name, metric.Name) {
+			return missing
+		}
+		if Valid(p, ctx) {
+			values = append(values, p)
+		}
+	}
+	if len(values) == 0 {
+		return missing
+	}
+
+	total := 0.0
+	for _, p := range values {
+		total += p.Data.Qty
+	}
+	var qty float64
+	switch info(metric, ctx).Aggregation {
+	case semantics.Sum:
+		qty = total
+	case semantics.Latest:
+		latest := values[0]
+		for _, p := range values[1:] {
+			if !p.Data.Date.Time.Before(latest.Data.Date.Time) {
+				latest = p
+			}
+		}
+		qty = latest.Data.Qty
+	case semantics.Sleep:
+		// Nights are the days the sleep ended on
+		nights := make(map[time.Time]bool)
+		for _, p := range values {
+			nights[ctx.Zone.Day(p.Data.Date)] = true
+		}
+		qty = total / float64(len(nights))
+	default:
+		qty = total / float64(len(values))
+	}
+	return MeasureValue(measure(metric, qty, ctx))
+}
+
+// pointAt is the date of the metric's first or last data point
+func pointAt(m models.Metric, last bool) Value {
+	if len(m.Data) == 0 {
+		return missing
+	}
+	if last {
+		return TimeValue(m.Data[len(m.Data)-1].Date)
+	}
+	return TimeValue(m.Data[0].Date)
+}
+
+// Metrics lists the fields of whole metrics, as shown in metric tables
+var Metrics = NewRegistry(
+	&Field[models.Metric]{Name: "name", Kind: Text, Title: "Name", Width: 30, Table: true,
+		Get: func(m models.Metric, _ Context) Value { return TextValue(m.Name) }},
+	&Field[models.Metric]{Name: "units", Kind: Text, Title: "Units", Width: 10, Table: true,
+		Get: displayUnits},
+	&Field[models.Metric]{Name: "count", Aliases: []string{"points"}, Kind: Number, Title: "Points", Width: 6, Table: true,
+		Get: func(m models.Metric, _ Context) Value { return NumberValue(float64(len(m.Data))) }},
+	&Field[models.Metric]{Name: "first", Aliases: []string{"start"}, Kind: Time, Title: "First", Width: 10, Layout: "2006-01-02", Table: true,
+		Get: func(m models.Metric, _ Context) Value { return pointAt(m, false) }},
+	&Field[models.Metric]{Name: "last", Aliases: []string{"date", "time"}, Kind: Time, Title: "Last", Width: 10, Layout: "2006-01-02", Table: true,
+		Get: func(m models.Metric, _ Context) Value { return pointAt(m, true) }},
+	&Field[models.Metric]{Name: "latest", Aliases: []string{"value"}, Kind: Quantity, Title: "Latest", Width: 10, Precision: 2, Table: true,
+		Get: func(m models.Metric, ctx Context) Value {
+			if len(m.Data) == 0 {
+				return missing
+			}
+			return MeasureValue(measure(m, m.Data[len(m.Data)-1].Qty, ctx))
+		}},
+	&Field[models.Metric]{Name: "display", Aliases: []string{"title"}, Kind: Text, Title: "Metric", Width: 26,
+		Get: func(m models.Metric, ctx Context) Value { return TextValue(info(m, ctx).Display) }},
+	&Field[models.Metric]{Name: "aggregation", Kind: Text, Title: "Aggregation", Width: 11,
+		Get: func(m models.Metric, ctx Context) Value { return TextValue(string(info(m, ctx).Aggregation)) }},
+)
+
+// Points lists the fields of metric data points, as shown under each metric in detailed listings
+var Points = NewRegistry(
+	&Field[Point]{Name: "name", Kind: Text, Title: "Name", Width: 30,
+		Get: func(p Point, _ Context) Value { return TextValue(p.Metric.Name) }},
+	&Field[Point]{Name: "units", Kind: Text, Title: "Units", Width: 10,
+		Get: func(p Point, ctx Context) Value { return displayUnits(p.Metric, ctx) }},
+	&Field[Point]{Name: "start", Aliases: []string{"date", "time"}, Kind: Time, Title: "Date", Width: 19, Detail: true,
+		Get: func(p Point, _ Context) Value { return TimeValue(p.Data.Date) }},
+	&Field[Point]{Name: "value", Aliases: []string{"qty"}, Kind: Quantity, Title: "Value", Width: 10, Precision: 2, Detail: true,
+		Get:    func(p Point, ctx Context) Value { return MeasureValue(measure(p.Metric, p.Data.Qty, ctx)) },
+		Rollup: rollup},
+	&Field[Point]{Name: "display", Aliases: []string{"title"}, Kind: Text, Title: "Metric", Width: 26,
+		Get: func(p Point, ctx Context) Value { return TextValue(info(p.Metric, ctx).Display) }},
+	&Field[Point]{Name: "min", Kind: Quantity, Title: "Min", Label: "min", Width: 10, Precision: 2, Detail: true, Reading: true,
+		Get: func(p Point, ctx Context) Value { return reading(p.Metric, p.Data.Min, ctx) }},
+	&Field[Point]{Name: "avg", Kind: Quantity, Title: "Avg", Label: "avg", Width: 10, Precision: 2, Reading: true,
+		Get: func(p Point, ctx Context) Value { return reading(p.Metric, p.Data.Avg, ctx) }},
+	&Field[Point]{Name: "max", Kind: Quantity, Title: "Max", Label: "max", Width: 10, Precision: 2, Detail: true, Reading: true,
+		Get: func(p Point, ctx Context) Value { return reading(p.Metric, p.Data.Max, ctx) }},
+	&Field[Point]{Name: "asleep", Kind: Quantity, Title: "Asleep", Label: "asleep", Width: 8, Precision: 2, Reading: true,
+		Get: func(p Point, _ Context) Value { return hours(p.Data.Asleep) }},
+	&Field[Point]{Name: "inbed", Aliases: []string{"in_bed"}, Kind: Quantity, Title: "In Bed", Label: "in bed", Width: 8, Precision: 2, Detail: true, Reading: true,
+		Get: func(p Point, _ Context) Value { return hours(p.Data.InBed) }},
+	&Field[Point]{Name: "core", Kind: Quantity, Title: "Core", Label: "core", Width: 8, Precision: 2, Detail: true, Reading: true,
+		Get: func(p Point, _ Context) Value { return hours(p.Data.Core) }},
+	&Field[Point]{Name: "deep", Kind: Quantity, Title: "Deep", Label: "deep", Width: 8, Precision: 2, Detail: true, Reading: true,
+		Get: func(p Point, _ Context) Value { return hours(p.Data.Deep) }},
+	&Field[Point]{Name: "rem", Kind: Quantity, Title: "REM", Label: "rem", Width: 8, Precision: 2, Detail: true, Reading: true,
+		Get: func(p Point, _ Context) Value { return hours(p.Data.REM) }},
+	&Field[Point]{Name: "sleepstart", Aliases: []string{"sleep_start"}, Kind: Time, Title: "Sleep Start", Label: "from", Width: 16, Layout: "2006-01-02 15:04", Detail: true, Reading: true,
+		Get: func(p Point, _ Context) Value { return optionalTime(p.Data.SleepStart) }},
+	&Field[Point]{Name: "sleepend", Aliases: []string{"sleep_end"}, Kind: Time, Title: "Sleep End", Label: "to", Width: 16, Layout: "2006-01-02 15:04", Detail: true, Reading: true,
+		Get: func(p Point, _ Context) Value { return optionalTime(p.Data.SleepEnd) }},
+	&Field[Point]{Name: "source", Kind: Text, Title: "Source", Label: "source", Width: 16, Detail: true, Reading: true,
+		Get: func(p Point, _ Context) Value {
+			if p.Data.Source == "" {
+				return missing
+			}
+			return TextValue(p.Data.Source)
+		}},
+	&Field[Point]{Name: "weekday", Kind: Text, Title: "Weekday", Width: 9,
+		Get: func(p Point, ctx Context) Value { return calendar(p.Data.Date, ctx, weekday) }},
+	&Field[Point]{Name: "hour", Kind: Number, Title: "Hour", Width: 4,
+		Get: func(p Point, ctx Context) Value { return calendar(p.Data.Date, ctx, hour) }},
+	&Field[Point]{Name: "month", Kind: Number, Title: "Month", Width: 5,
+		Get: func(p Point, ctx Context) Value { return calendar(p.Data.Date, ctx, month) }},
+	&Field[Point]{Name: "year", Kind: Number, Title: "Year", Width: 4,
+		Get: func(p Point, ctx Context) Value { return calendar(p.Data.Date, ctx, year) }},
+)
@@ -0,0 +1,129 @@
+// fields/series.go
+// Fields summarizing the sample series of workouts
+
+package fields
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+	"fmt"
+)
+
+// beatsPerMinute is the unit heart rates and their drops are shown in
+const beatsPerMinute = "bpm"
+
+// peak is the highest value of a sample, which summarizes several readings if it has a maximum
+func peak(s models.MetricData) float64 {
+	if s.Max != nil {
+		return *s.Max
+	}
+	return s.Qty
+}
+
+// AverageHeartRate is the mean of the heart rate readings of a workout, false if there are none
+func AverageHeartRate(w models.Workout) (float64, bool) {
+	if len(w.HeartRateData) == 0 {
+		return 0, false
+	}
+	var total float64
+	for _, s := range w.HeartRateData {
+		total += s.Qty
+	}
+	return total / float64(len(w.HeartRateData)), true
+}
+
+// MaxHeartRate is the highest heart rate reading of a workout, false if there are none
+func MaxHeartRate(w models.Workout) (float64, bool) {
+	if len(w.HeartRateData) == 0 {
+		return 0, false
+	}
+	highest := peak(w.HeartRateData[0])
+	for _, s := range w.HeartRateData[1:] {
+		highest = max(highest, peak(s))
+	}
+	return highest, true
+}
+
+// HeartRateDrop is how far the heart rate fell from the first to the last reading after a workout
+// It takes two readings to tell, false otherwise
+func HeartRateDrop(w models.Workout) (float64, bool) {
+	readings := w.HeartRateRecovery
+	if len(readings) < 2 {
+		return 0, false
+	}
+	return readings[0].Qty - readings[len(readings)-1].Qty, true
+}
+
+// ElevationGain is the elevation climbed during a workout, in meters
+// Samples in units that are not distances are left out, false if none is left
+func ElevationGain(w models.Workout) (float64, bool) {
+	gain, found := 0.0, false
+	for _, s := range w.ElevationUp {
+		units := s.Units
+		if units == "" {
+			units = quantity.Meter.Symbol
+		}
+		q, err := quantity.New(s.Qty, units)
+		if err != nil {
+			continue
+		}
+		if meters, err := q.In(quantity.Meter); err == nil {
+			gain += meters.Value
+			found = true
+		}
+	}
+	return gain, found
+}
+
+// Steps is the number of steps counted during a workout, false if none were
+func Steps(w models.Workout) (float64, bool) {
+	if len(w.StepCount) == 0 {
+		return 0, false
+	}
+	var total float64
+	for _, s := range w.StepCount {
+		total += s.Qty
+	}
+	return total, true
+}
+
+// Cadence is the number of steps per minute of a workout, false without steps or a duration
+func Cadence(w models.Workout) (float64, bool) {
+	steps, ok := Steps(w)
+	if !ok || w.Duration <= 0 {
+		return 0, false
+	}
+	return steps / (w.Duration / 60), true
+}
+
+// summary turns a summary of a workout's series into a field value in the given units
+func summary(read func(models.Workout) (float64, bool), units string) func(models.Workout, Context) Value {
+	return func(w models.Workout, _ Context) Value {
+		value, ok := read(w)
+		if !ok {
+			return missing
+		}
+		return MeasureValue(&models.Measurement{Qty: value, Units: units})
+	}
+}
+
+// elevationUnit is the unit elevations are shown in, meters or feet, which suit heights better than the units of distances
+func elevationUnit(system quantity.System) quantity.Unit {
+	if system == quantity.Metric {
+		return quantity.Meter
+	}
+	return quantity.Foot
+}
+
+// elevation shows an elevation in its display unit
+func elevation(v Value, ctx Context) string {
+	unit := elevationUnit(ctx.Units)
+	q, err := quantity.FromMeasurement(v.Measure)
+	if err == nil {
+		q, err = q.In(unit)
+	}
+	if err != nil {
+		return fmt.Sprintf("%.0f %s", v.Measure.Qty, v.Measure.Units)
+	}
+	return fmt.Sprintf("%.0f %s", q.Value, unit.Symbol)
+}
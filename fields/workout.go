@@ -0,0 +1,98 @@
+// fields/workout.go
+// Fields of workouts
+
+package fields
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/utils"
+	"strings"
+	"time"
+)
+
+// optionalText is the value of an optional text field
+func optionalText(s *string) Value {
+	if s == nil {
+		return missing
+	}
+	return TextValue(*s)
+}
+
+// calendar reads a calendar part of a timestamp in the context's zone
+func calendar(ts models.Timestamp, ctx Context, part func(time.Time) Value) Value {
+	if !ts.Valid() {
+		return missing
+	}
+	return part(ctx.Zone.Time(ts))
+}
+
+func weekday(t time.Time) Value { return TextValue(strings.ToLower(t.Weekday().String())) }
+func hour(t time.Time) Value    { return NumberValue(float64(t.Hour())) }
+func month(t time.Time) Value   { return NumberValue(float64(t.Month())) }
+func year(t time.Time) Value    { return NumberValue(float64(t.Year())) }
+
+// pace is the time a workout took per display unit of distance
+func pace(w models.Workout, ctx Context) Value {
+	if w.Distance == nil || w.Duration <= 0 {
+		return missing
+	}
+	distance, err := ctx.Units.Value(w.Distance, quantity.Distance)
+	if err != nil || distance <= 0 {
+		return missing
+	}
+	return SecondsValue(w.Duration / distance)
+}
+
+// Workouts lists the fields of workouts
+var Workouts = NewRegistry(
+	&Field[models.Workout]{Name: "name", Kind: Text, Title: "Name", Label: "Workout", Width: 20, Detail: true, Table: true,
+		Get: func(w models.Workout, _ Context) Value { return TextValue(w.Name) }},
+	&Field[models.Workout]{Name: "id", Kind: Text, Title: "ID", Width: 36, Detail: true,
+		Get: func(w models.Workout, _ Context) Value { return TextValue(w.ID) }},
+	&Field[models.Workout]{Name: "start", Aliases: []string{"date", "time"}, Kind: Time, Title: "Start", Width: 19, Detail: true, Table: true,
+		Get: func(w models.Workout, _ Context) Value { return TimeValue(w.Start) }},
+	&Field[models.Workout]{Name: "end", Kind: Time, Title: "End", Width: 19, Detail: true,
+		Get: func(w models.Workout, _ Context) Value { return TimeValue(w.End) }},
+	&Field[models.Workout]{Name: "duration", Kind: Quantity, Dimension: quantity.Duration, Title: "Duration", Width: 8, Detail: true, Table: true,
+		Get: func(w models.Workout, _ Context) Value { return SecondsValue(w.Duration) }},
+	&Field[models.Workout]{Name: "distance", Kind: Quantity, Dimension: quantity.Distance, Title: "Distance", Width: 10, Precision: 2, Detail: true, Table: true,
+		Get: func(w models.Workout, _ Context) Value { return MeasureValue(w.Distance) }},
+	&Field[models.Workout]{Name: "pace", Kind: Quantity, Dimension: quantity.Duration, Title: "Pace", Width: 10,
+		Get: pace,
+		Format: func(v Value, ctx Context) string {
+			return utils.FormatTime(v.Measure.Qty) + " /" + ctx.Units.Unit(quantity.Distance).Symbol
+		}},
+	&Field[models.Workout]{Name: "energy", Aliases: []string{"calories"}, Kind: Quantity, Dimension: quantity.Energy, Title: "Energy", Label: "Energy Burned", Width: 10, Detail: true, Table: true,
+		Get: func(w models.Workout, _ Context) Value { return MeasureValue(w.ActiveEnergyBurned) }},
+	&Field[models.Workout]{Name: "intensity", Kind: Number, Title: "Intensity", Width: 16, Precision: 2, Detail: true,
+		Get: func(w models.Workout, _ Context) Value { return MeasureValue(w.Intensity) }},
+	&Field[models.Workout]{Name: "location", Kind: Text, Title: "Location", Width: 12, Detail: true,
+		Get: func(w models.Workout, _ Context) Value { return optionalText(w.Location) }},
+	&Field[models.Workout]{Name: "tags", Aliases: []string{"tag"}, Kind: Text, Title: "Tags", Width: 16,
+		Get: func(w models.Workout, _ Context) Value { return ListValue(w.Tags) }},
+	&Field[models.Workout]{Name: "temperature", Aliases: []string{"temp"}, Kind: Quantity, Dimension: quantity.Temperature, Title: "Temperature", Width: 11, Precision: 1, Detail: true,
+		Get: func(w models.Workout, _ Context) Value { return MeasureValue(w.Temperature) }},
+	&Field[models.Workout]{Name: "humidity", Kind: Number, Title: "Humidity", Width: 8,
+		Get: func(w models.Workout, _ Context) Value { return MeasureValue(w.Humidity) }},
+	&Field[models.Workout]{Name: "heartrate", Aliases: []string{"hr"}, Kind: Number, Title: "Avg HR", Label: "Average Heart Rate", Width: 7, Detail: true,
+		Get: summary(AverageHeartRate, beatsPerMinute)},
+	&Field[models.Workout]{Name: "maxheartrate", Aliases: []string{"maxhr"}, Kind: Number, Title: "Max HR", Label: "Max Heart Rate", Width: 7, Detail: true,
+		Get: summary(MaxHeartRate, beatsPerMinute)},
+	&Field[models.Workout]{Name: "recovery", Kind: Number, Title: "Recovery", Label: "Heart Rate Recovery", Width: 8, Detail: true,
+		Get: summary(HeartRateDrop, beatsPerMinute)},
+	&Field[models.Workout]{Name: "elevation", Aliases: []string{"climb"}, Kind: Quantity, Dimension: quantity.Distance, Unit: elevationUnit, Title: "Elevation", Label: "Elevation Gain", Width: 9, Detail: true,
+		Get: summary(ElevationGain, quantity.Meter.Symbol), Format: elevation},
+	&Field[models.Workout]{Name: "steps", Kind: Number, Title: "Steps", Width: 6, Detail: true,
+		Get: summary(Steps, "")},
+	&Field[models.Workout]{Name: "cadence", Kind: Number, Title: "Cadence", Width: 7, Detail: true,
+		Get: summary(Cadence, "spm")},
+	&Field[models.Workout]{Name: "weekday", Kind: Text, Title: "Weekday", Width: 9,
+		Get: func(w models.Workout, ctx Context) Value { return calendar(w.Start, ctx, weekday) }},
+	&Field[models.Workout]{Name: "hour", Kind: Number, Title: "Hour", Width: 4,
+		Get: func(w models.Workout, ctx Context) Value { return calendar(w.Start, ctx, hour) }},
+	&Field[models.Workout]{Name: "month", Kind: Number, Title: "Month", Width: 5,
+		Get: func(w models.Workout, ctx Context) Value { return calendar(w.Start, ctx, month) }},
+	&Field[models.Workout]{Name: "year", Kind: Number, Title: "Year", Width: 4,
+		Get: func(w models.Workout, ctx Context) Value { return calendar(w.Start, ctx, year) }},
+)
@@ -3,14 +3,10 @@ package main
 
 import (
 	"fitness/cli"
-	"fitness/data"
 )
 
 func main() {
-	// Import data from cache and cloud drive
-	data.ImportData()
-
-	// Run the CLI Program
+	// Run the CLI Program, which loads the config and imports data
 	cli.StartCLI()
 
 }
@@ -0,0 +1,104 @@
+// models/metricdata.go
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// metricDataFields maps the lower-cased JSON names of the fields of MetricData to their index,
+// every other key of a point is kept in Extra
+var metricDataFields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(MetricData{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "-" {
+			fields[strings.ToLower(name)] = i
+		}
+	}
+	return fields
+}()
+
+// Derived returns the value of a point recorded without a quantity: the average of a point summarizing
+// several readings, or the hours asleep of a sleep point
+func (d MetricData) Derived() (float64, bool) {
+	switch {
+	case d.Avg != nil:
+		return *d.Avg, true
+	case d.Asleep != nil:
+		return *d.Asleep, true
+	}
+	return 0, false
+}
+
+// UnmarshalJSON reads a data point, keeping the fields it does not know in Extra
+// The point is decoded once into its keys, and each known field from its own value
+// A point without a qty takes its value from Derived
+func (d *MetricData) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var point MetricData
+	value := reflect.ValueOf(&point).Elem()
+	hasQty := false
+	for key, raw := range fields {
+		// Known fields are matched ignoring case, as json.Unmarshal does
+		i, known := metricDataFields[strings.ToLower(key)]
+		if !known {
+			continue
+		}
+		if err := json.Unmarshal(raw, value.Field(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("metric data field %s: %v", key, err)
+		}
+		hasQty = hasQty || i == metricDataFields["qty"]
+		delete(fields, key)
+	}
+	if len(fields) > 0 {
+		point.Extra = fields
+	}
+
+	*d = point
+	if !hasQty {
+		d.Qty, _ = d.Derived()
+	}
+	return nil
+}
+
+// MarshalJSON writes a data point with its extra fields after the known ones, in key order
+func (d MetricData) MarshalJSON() ([]byte, error) {
+	type plain MetricData
+	known, err := json.Marshal(plain(d))
+	if err != nil || len(d.Extra) == 0 {
+		return known, err
+	}
+
+	keys := make([]string, 0, len(d.Extra))
+	for key := range d.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(known[:len(known)-1])
+	for _, key := range keys {
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(d.Extra[key]) {
+			return nil, fmt.Errorf("extra field %q of metric data is not valid JSON", key)
+		}
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(d.Extra[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
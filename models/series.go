@@ -0,0 +1,90 @@
+// models/series.go
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Samples is a series of readings taken during a workout, such as its heart rate
+// Each reading is a data point with its own units
+type Samples []MetricData
+
+// UnmarshalJSON reads a series, a single reading such as {"qty": 120, "units": "m"} is a series of one
+func (s *Samples) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var reading MetricData
+		if err := json.Unmarshal(trimmed, &reading); err != nil {
+			return err
+		}
+		*s = Samples{reading}
+		return nil
+	}
+	var readings []MetricData
+	if err := json.Unmarshal(data, &readings); err != nil {
+		return err
+	}
+	*s = readings
+	return nil
+}
+
+// WorkoutSeries holds the route and sample series of a workout
+// It is what a series file stores for each workout, apart from the cache
+type WorkoutSeries struct {
+	Route             []RoutePoint `json:"route,omitempty"`             // Track of the route taken during the workout
+	HeartRateData     Samples      `json:"heartRateData,omitempty"`     // Heart rate readings during the workout
+	HeartRateRecovery Samples      `json:"heartRateRecovery,omitempty"` // Heart rate readings after the workout
+	StepCount         Samples      `json:"stepCount,omitempty"`         // Steps counted during the workout
+	ElevationUp       Samples      `json:"elevationUp,omitempty"`       // Elevation climbed during the workout
+}
+
+// IsEmpty reports whether there is no series at all
+func (s WorkoutSeries) IsEmpty() bool {
+	return len(s.Route) == 0 && len(s.HeartRateData) == 0 && len(s.HeartRateRecovery) == 0 &&
+		len(s.StepCount) == 0 && len(s.ElevationUp) == 0
+}
+
+// Fill returns the series with the ones it lacks taken from other
+func (s WorkoutSeries) Fill(other WorkoutSeries) WorkoutSeries {
+	if len(s.Route) == 0 {
+		s.Route = other.Route
+	}
+	if len(s.HeartRateData) == 0 {
+		s.HeartRateData = other.HeartRateData
+	}
+	if len(s.HeartRateRecovery) == 0 {
+		s.HeartRateRecovery = other.HeartRateRecovery
+	}
+	if len(s.StepCount) == 0 {
+		s.StepCount = other.StepCount
+	}
+	if len(s.ElevationUp) == 0 {
+		s.ElevationUp = other.ElevationUp
+	}
+	return s
+}
+
+// Series returns the route and sample series of the workout
+func (w Workout) Series() WorkoutSeries {
+	return WorkoutSeries{
+		Route:             w.Route,
+		HeartRateData:     w.HeartRateData,
+		HeartRateRecovery: w.HeartRateRecovery,
+		StepCount:         w.StepCount,
+		ElevationUp:       w.ElevationUp,
+	}
+}
+
+// SetSeries replaces the route and sample series of the workout
+func (w *Workout) SetSeries(s WorkoutSeries) {
+	w.Route = s.Route
+	w.HeartRateData = s.HeartRateData
+	w.HeartRateRecovery = s.HeartRateRecovery
+	w.StepCount = s.StepCount
+	w.ElevationUp = s.ElevationUp
+}
+
+// SeriesFile is the side file keeping the series of workouts apart from the cache, keyed by workout key
+type SeriesFile struct {
+	Workouts map[string]WorkoutSeries `json:"workouts"` // Series of each workout that has any
+}
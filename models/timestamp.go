@@ -0,0 +1,124 @@
+// models/timestamp.go
+package models
+
+import (
+	"encoding/json"
+	"fitness/config"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timestampLayouts lists the accepted timestamp layouts, tried in order
+// Layouts without an offset are read as local time
+var timestampLayouts = []string{
+	config.TimeFormat,     // Health Auto Export, e.g. 2024-01-02 07:30:00 -0500
+	time.RFC3339Nano,      // RFC3339 with or without fractional seconds
+	"2006-01-02 15:04:05", // Health Auto Export CSV without an offset
+	"2006-01-02 15:04",
+	config.DateFormat, // Date only, read as local midnight
+}
+
+// Timestamp is a point in time read from an export
+// The original text is kept so it is written back to the cache unchanged
+type Timestamp struct {
+	Time time.Time // Parsed time, zero if Raw could not be parsed
+	Raw  string    // Text the timestamp was read from
+}
+
+// NewTimestamp creates a timestamp for t in the Health Auto Export format
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t, Raw: t.Format(config.TimeFormat)}
+}
+
+// ParseTimestamp parses a timestamp in any of the accepted layouts
+// The raw text is kept even when parsing fails, an empty value is the zero timestamp
+func ParseTimestamp(value string) (Timestamp, error) {
+	ts := Timestamp{Raw: value}
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return ts, nil
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, trimmed, time.Local); err == nil {
+			ts.Time = t
+			return ts, nil
+		}
+	}
+	return ts, fmt.Errorf("unrecognized timestamp %q", value)
+}
+
+// Valid reports whether the timestamp holds a parsed time
+func (t Timestamp) Valid() bool {
+	return !t.Time.IsZero()
+}
+
+// IsZero reports whether the timestamp is empty
+func (t Timestamp) IsZero() bool {
+	return t.Time.IsZero() && strings.TrimSpace(t.Raw) == ""
+}
+
+// Err returns the parse error of a timestamp that has text but no time
+func (t Timestamp) Err() error {
+	if t.Valid() || t.IsZero() {
+		return nil
+	}
+	_, err := ParseTimestamp(t.Raw)
+	return err
+}
+
+// Before reports whether t is earlier than u
+// Timestamps that could not be parsed sort after valid ones, by their text
+func (t Timestamp) Before(u Timestamp) bool {
+	if t.Valid() && u.Valid() {
+		return t.Time.Before(u.Time)
+	}
+	if t.Valid() != u.Valid() {
+		return t.Valid()
+	}
+	return t.Raw < u.Raw
+}
+
+// Key identifies the instant so the same time written in different layouts compares equal
+func (t Timestamp) Key() string {
+	if t.Valid() {
+		return t.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return t.Raw
+}
+
+// Format formats the time with layout, or returns the raw text if it could not be parsed
+func (t Timestamp) Format(layout string) string {
+	if t.Valid() {
+		return t.Time.Format(layout)
+	}
+	return t.Raw
+}
+
+// String returns the original text of the timestamp
+func (t Timestamp) String() string {
+	if t.Raw == "" && t.Valid() {
+		return t.Time.Format(config.TimeFormat)
+	}
+	return t.Raw
+}
+
+// MarshalJSON writes the timestamp as its original text
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON reads a timestamp string, keeping values that cannot be parsed
+// Unparseable values are reported through Err so a single bad record does not fail a whole file
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = Timestamp{}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("timestamp must be a string: %v", err)
+	}
+	*t, _ = ParseTimestamp(value)
+	return nil
+}
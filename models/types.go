@@ -1,10 +1,18 @@
 // models/types.go
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // HealthData is the top-level struct that contains all health data
 type HealthData struct {
-	Data        DataCollection `json:"data"`        // Collection of workout and metric data
-	LastUpdated *string        `json:"lastUpdated"` // Timestamp of the last update
+	SchemaVersion int            `json:"schemaVersion"`        // Version of the cache file format
+	Data          DataCollection `json:"data"`                 // Collection of workout and metric data
+	LastUpdated   *string        `json:"lastUpdated"`          // Timestamp of the last update
+	Manifest      Manifest       `json:"manifest,omitempty"`   // Files that have been ingested into the cache
+	SeriesFile    string         `json:"seriesFile,omitempty"` // Series file holding the workout series, empty if they are in the cache
 }
 
 // DataCollection contains all workout and metric data
@@ -23,25 +31,52 @@ type Measurement struct {
 type Workout struct {
 	ID                 string       `json:"id"`                           // Unique identifier for the workout
 	Name               string       `json:"name"`                         // Name of the workout
-	Start              string       `json:"start"`                        // Start time of the workout
-	End                string       `json:"end"`                          // End time of the workout
+	Start              Timestamp    `json:"start"`                        // Start time of the workout
+	End                Timestamp    `json:"end"`                          // End time of the workout
 	Duration           float64      `json:"duration"`                     // Duration of the workout in seconds
 	Distance           *Measurement `json:"distance,omitempty"`           // Distance covered during the workout
 	ActiveEnergyBurned *Measurement `json:"activeEnergyBurned,omitempty"` // Energy burned during the workout
 	Intensity          *Measurement `json:"intensity,omitempty"`          // Intensity level of the workout
 	Location           *string      `json:"location,omitempty"`           // Location of the workout
-	Humidity           *struct {    // Humidity data for the workout
-		Units string  `json:"units"`
-		Qty   float64 `json:"qty"`
-	} `json:"humidity,omitempty"`
-	Temperature *Measurement `json:"temperature,omitempty"` // Temperature during the workout
-	LapLength   *Measurement `json:"lapLength,omitempty"`   // Length of each lap during the workout
+	Tags               []string     `json:"tags,omitempty"`               // Labels given to the workout, e.g. commute
+	Humidity           *Measurement `json:"humidity,omitempty"`           // Humidity data for the workout
+	Temperature        *Measurement `json:"temperature,omitempty"`        // Temperature during the workout
+	LapLength          *Measurement `json:"lapLength,omitempty"`          // Length of each lap during the workout
+	Route              []RoutePoint `json:"route,omitempty"`              // Track of the route taken during the workout
+	RouteOnly          bool         `json:"routeOnly,omitempty"`          // Built from a route file that matched no workout, until the workout is imported
+	HeartRateData      Samples      `json:"heartRateData,omitempty"`      // Heart rate readings during the workout
+	HeartRateRecovery  Samples      `json:"heartRateRecovery,omitempty"`  // Heart rate readings in the minutes after the workout
+	StepCount          Samples      `json:"stepCount,omitempty"`          // Steps counted during the workout
+	ElevationUp        Samples      `json:"elevationUp,omitempty"`        // Elevation climbed during the workout
+}
+
+// RoutePoint is a single timestamped location along a workout route
+type RoutePoint struct {
+	Timestamp Timestamp `json:"timestamp"` // Time the location was recorded
+	Latitude  float64   `json:"latitude"`  // Latitude in degrees
+	Longitude float64   `json:"longitude"` // Longitude in degrees
+	Altitude  float64   `json:"altitude"`  // Elevation in meters
 }
 
 // MetricData represents a single data point for a metric
+// Points summarizing several readings, such as heart rate, carry the smallest, average and largest one,
+// and sleep points the hours spent in each stage; fields not listed here are kept in Extra
 type MetricData struct {
-	Date string  `json:"date"` // Date of the data point
-	Qty  float64 `json:"qty"`  // Quantity of the data point
+	Date       Timestamp                  `json:"date"`                 // Date of the data point
+	Qty        float64                    `json:"qty"`                  // Quantity of the data point
+	Min        *float64                   `json:"Min,omitempty"`        // Smallest reading the point summarizes
+	Avg        *float64                   `json:"Avg,omitempty"`        // Average reading the point summarizes
+	Max        *float64                   `json:"Max,omitempty"`        // Largest reading the point summarizes
+	Asleep     *float64                   `json:"asleep,omitempty"`     // Hours asleep
+	InBed      *float64                   `json:"inBed,omitempty"`      // Hours in bed
+	Core       *float64                   `json:"core,omitempty"`       // Hours of core sleep
+	Deep       *float64                   `json:"deep,omitempty"`       // Hours of deep sleep
+	REM        *float64                   `json:"rem,omitempty"`        // Hours of REM sleep
+	SleepStart *Timestamp                 `json:"sleepStart,omitempty"` // When sleep began
+	SleepEnd   *Timestamp                 `json:"sleepEnd,omitempty"`   // When sleep ended
+	Source     string                     `json:"source,omitempty"`     // Device or app that recorded the point
+	Units      string                     `json:"units,omitempty"`      // Units of a workout sample, metric points take the metric's units
+	Extra      map[string]json.RawMessage `json:"-"`                    // Fields of the export this program does not know, written back unchanged
 }
 
 // Metric represents a single metric entry
@@ -50,3 +85,28 @@ type Metric struct {
 	Data  []MetricData `json:"data"`  // Collection of data points for the metric
 	Units string       `json:"units"` // Units of the metric
 }
+
+// Statuses of an export file relative to the cache manifest
+const (
+	FileIngested = "ingested" // File has been ingested and is unchanged
+	FilePending  = "pending"  // File has never been ingested
+	FileChanged  = "changed"  // File was ingested but has changed since
+	FileFailed   = "failed"   // File could not be ingested
+)
+
+// Manifest records every ingested file keyed by its path
+type Manifest map[string]ManifestEntry
+
+// ManifestEntry describes a single file that has been ingested into the cache
+type ManifestEntry struct {
+	Path       string    `json:"path"`             // Path of the ingested file
+	Size       int64     `json:"size"`             // Size of the file in bytes
+	ModTime    time.Time `json:"modTime"`          // Modification time of the file
+	Hash       string    `json:"hash"`             // SHA-256 hash of the file contents
+	Workouts   int       `json:"workouts"`         // Number of workouts read from the file
+	Metrics    int       `json:"metrics"`          // Number of metric data points read from the file
+	Tracks     int       `json:"tracks,omitempty"` // Number of route tracks read from a GPX or TCX file
+	Status     string    `json:"status"`           // Ingestion status (ingested or failed)
+	Error      string    `json:"error,omitempty"`  // Error encountered while ingesting the file
+	IngestedAt time.Time `json:"ingestedAt"`       // When the file was last ingested
+}
@@ -0,0 +1,85 @@
+// models/zone.go
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Names of the time zone policies that are not a zone name
+const (
+	ZoneOriginal = "original" // Keep the offset each timestamp was recorded with
+	ZoneUTC      = "utc"      // Convert every timestamp to UTC
+	ZoneLocal    = "local"    // Convert every timestamp to the system time zone
+)
+
+// Zone is the time zone policy used to place timestamps on calendar days and to display them
+// The zero Zone keeps each timestamp in the local time it was recorded in, so a workout
+// logged while travelling stays on the day it happened there
+type Zone struct {
+	name     string
+	location *time.Location // Zone to convert to, nil keeps the original offset
+}
+
+// ParseZone reads a time zone policy: original, utc, local or an IANA zone name such as Europe/Berlin
+func ParseZone(name string) (Zone, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ZoneOriginal:
+		return Zone{}, nil
+	case ZoneUTC:
+		return Zone{name: ZoneUTC, location: time.UTC}, nil
+	case ZoneLocal:
+		return Zone{name: ZoneLocal, location: time.Local}, nil
+	}
+	location, err := time.LoadLocation(strings.TrimSpace(name))
+	if err != nil {
+		return Zone{}, fmt.Errorf("unknown time zone %q (expected original, utc, local or a zone name such as Europe/Berlin)", name)
+	}
+	return Zone{name: location.String(), location: location}, nil
+}
+
+// FixedZone creates a policy that converts every timestamp to location
+func FixedZone(location *time.Location) Zone {
+	return Zone{name: location.String(), location: location}
+}
+
+// String returns the name of the policy
+func (z Zone) String() string {
+	if z.name == "" {
+		return ZoneOriginal
+	}
+	return z.name
+}
+
+// Location returns the zone timestamps are converted to, nil if they keep their original offset
+func (z Zone) Location() *time.Location {
+	return z.location
+}
+
+// Time returns the time of a timestamp in the policy's zone
+func (z Zone) Time(ts Timestamp) time.Time {
+	return z.In(ts.Time)
+}
+
+// In converts a time to the policy's zone
+func (z Zone) In(t time.Time) time.Time {
+	if z.location == nil {
+		return t
+	}
+	return t.In(z.location)
+}
+
+// Format formats a timestamp in the policy's zone, or returns the raw text if it could not be parsed
+func (z Zone) Format(ts Timestamp, layout string) string {
+	if !ts.Valid() {
+		return ts.Raw
+	}
+	return z.Time(ts).Format(layout)
+}
+
+// Day returns midnight of the calendar day the timestamp falls on in the policy's zone
+func (z Zone) Day(ts Timestamp) time.Time {
+	t := z.Time(ts)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
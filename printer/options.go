@@ -1,30 +1,55 @@
 // printer/options.go
 package printer
 
+import (
+	"fitness/fields"
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/semantics"
+	"fitness/utils"
+	"time"
+)
+
 // PrintOptions contains options for printing data
 type PrintOptions struct {
-	TimeFormat         string     // Format for time.Time values
-	Filter             FilterFunc // Filter function to apply to data
-	MaxItems           int        // Maximum number of items to display
-	Compact            bool       // Whether to use compact display mode
-	SortBy             string     // Field to sort results by
-	SortDesc           bool       // Whether to sort in descending order
-	IncludeFields      []string   // Fields to include in output
-	ExcludeFields      []string   // Fields to exclude from output
-	WorkoutsPerMonth   bool       // Whether to show total workouts per month
-	DistancePerWorkout bool       // Whether to show distance per workout
-	DistancePerWeek    bool       // Whether to show total distance per week
-	EnergyPerWeek      bool       // Whether to show total energy per week
+	TimeFormat         string              // Format for time.Time values
+	Filter             FilterFunc          // Filter function to apply to data
+	PointFilter        PointFilterFunc     // Filter for the data points of metrics, metrics left without any are not shown
+	MaxItems           int                 // Maximum number of items to display
+	Compact            bool                // Whether to use compact display mode
+	SortBy             string              // Field to sort results by
+	SortDesc           bool                // Whether to sort in descending order
+	IncludeFields      []string            // Fields to show, in order, instead of the default ones
+	ExcludeFields      []string            // Fields to leave out of the output
+	WorkoutsPerMonth   bool                // Whether to show total workouts per month
+	DistancePerWorkout bool                // Whether to show distance per workout
+	DistancePerWeek    bool                // Whether to show total distance per week
+	EnergyPerWeek      bool                // Whether to show total energy per week
+	ReportsOnly        bool                // Whether to show only the reports, without listing the records
+	Units              quantity.System     // Unit system measurements are displayed in
+	Zone               models.Zone         // Time zone policy for displaying and bucketing timestamps
+	Week               utils.Bucketer      // Buckets used by the per-week totals
+	Metrics            *semantics.Registry // Descriptions of metrics, the built-in ones if nil
 }
 
 // FilterFunc is a function type that filters data
 type FilterFunc func(interface{}) bool
 
+// PointFilterFunc is a function type that filters the data points of a metric
+type PointFilterFunc func(models.Metric, models.MetricData) bool
+
 // DefaultPrintOptions returns a set of default PrintOptions
 func DefaultPrintOptions() PrintOptions {
 	return PrintOptions{
 		TimeFormat: "2006-01-02 15:04:05",
 		MaxItems:   0,
 		Compact:    false,
+		Units:      quantity.Imperial,
+		Week:       utils.Bucketer{Period: utils.Week, WeekStart: time.Monday},
 	}
 }
+
+// fieldContext returns the settings fields are read and formatted with, showing times in a layout
+func (opts PrintOptions) fieldContext(timeFormat string) fields.Context {
+	return fields.Context{Units: opts.Units, Zone: opts.Zone, TimeFormat: timeFormat, Metrics: opts.Metrics}
+}
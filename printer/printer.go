@@ -2,13 +2,12 @@ package printer
 
 import (
 	"fmt"
-	"sort"
+	"os"
 	"strings"
-	"time"
 
-	"fitness/config"
-	"fitness/data"
+	"fitness/fields"
 	"fitness/models"
+	"fitness/stats"
 	"fitness/utils"
 )
 
@@ -25,41 +24,41 @@ func PrintHealthData(data interface{}, opts PrintOptions) error {
 	}
 }
 
-// PrintWorkouts function with exclude field support
+// PrintWorkouts lists the workouts with the fields selected by -i and -x, followed by the reports
+// Listing and reports cover the same workouts, the ones the filter selects
 func PrintWorkouts(workouts []models.Workout, opts PrintOptions) error {
-	// Create a map of excluded fields for faster lookup
-	excludedFields := make(map[string]bool)
-	for _, field := range opts.ExcludeFields {
-		excludedFields[strings.ToLower(field)] = true
+	workouts = SelectWorkouts(workouts, opts)
+
+	// Only print the reports if no listing is wanted
+	if opts.ReportsOnly {
+		PrintCustom(workouts, opts)
+		return nil
 	}
 
-	// Reverse data if descending flag is set
-	if opts.SortDesc {
-		// Reverse the workouts array
-		for i, j := 0, len(workouts)-1; i < j; i, j = i+1, j-1 {
-			workouts[i], workouts[j] = workouts[j], workouts[i]
-		}
+	// Pick the fields to show before anything is printed
+	columns, err := fields.Workouts.Select(opts.IncludeFields, opts.ExcludeFields, func(f *fields.Field[models.Workout]) bool { return f.Detail })
+	if err != nil {
+		return err
 	}
 
-	// Filter the workouts if a filter function is provided
-	if opts.Filter != nil {
-		var filtered []models.Workout
-		for _, w := range workouts {
-			if opts.Filter(w) {
-				filtered = append(filtered, w)
-			}
-		}
-		workouts = filtered
+	// Sort the workouts by the requested fields
+	listed, err := SortWorkouts(workouts, opts)
+	if err != nil {
+		return err
 	}
 
 	// Limit the number of items displayed if specified
-	if opts.MaxItems > 0 && len(workouts) > opts.MaxItems {
-		workouts = workouts[:opts.MaxItems]
+	if opts.MaxItems > 0 && len(listed) > opts.MaxItems {
+		listed = listed[:opts.MaxItems]
 	}
 
 	// Use compact mode if specified
 	if opts.Compact {
-		return PrintWorkoutsCompact(workouts, opts)
+		if err := PrintWorkoutsCompact(listed, opts); err != nil {
+			return err
+		}
+		PrintCustom(workouts, opts)
+		return nil
 	}
 
 	// Detailed display mode
@@ -68,176 +67,175 @@ func PrintWorkouts(workouts []models.Workout, opts PrintOptions) error {
 	fmt.Println(strings.Repeat("-", 80))
 
 	// Print each workout data with formatted fields
-	for i, w := range workouts {
+	ctx := opts.fieldContext(opts.TimeFormat)
+	for i, w := range listed {
 		if i > 0 {
 			fmt.Println(strings.Repeat("-", 80))
 		}
 
-		// Only print fields that aren't excluded
-		if !excludedFields["name"] {
-			fmt.Printf("Workout: %s\n", w.Name)
-		}
-		if !excludedFields["id"] {
-			fmt.Printf("ID: %s\n", w.ID)
-		}
-
-		// Handle start/end times
-		if !excludedFields["start"] && !excludedFields["time"] {
-			start, err := time.Parse(config.TimeFormat, w.Start)
-			if err == nil {
-				fmt.Printf("Start: %s\n", start.Format(opts.TimeFormat))
-			}
-		}
-		if !excludedFields["end"] && !excludedFields["time"] {
-			end, err := time.Parse(config.TimeFormat, w.End)
-			if err == nil {
-				fmt.Printf("End: %s\n", end.Format(opts.TimeFormat))
+		// Print the selected fields the workout has a value for
+		for _, f := range columns {
+			if v := f.Get(w, ctx); !v.Missing {
+				fmt.Printf("%s: %s\n", f.Heading(), f.String(v, ctx))
 			}
 		}
-		// Handle duration, distance, energy, intensity, location, and temperature
-		if !excludedFields["duration"] {
-			fmt.Printf("Duration: %s\n", utils.FormatTime(w.Duration))
-		}
-		if !excludedFields["distance"] && w.Distance != nil {
-			fmt.Printf("Distance: %.2f %s\n", w.Distance.Qty, w.Distance.Units)
-		}
-		if !excludedFields["energy"] && w.ActiveEnergyBurned != nil {
-			fmt.Printf("Energy Burned: %.2f %s\n", w.ActiveEnergyBurned.Qty, w.ActiveEnergyBurned.Units)
-		}
-		if !excludedFields["intensity"] && w.Intensity != nil {
-			fmt.Printf("Intensity: %.2f %s\n", w.Intensity.Qty, w.Intensity.Units)
-		}
-		if !excludedFields["location"] && w.Location != nil {
-			fmt.Printf("Location: %s\n", *w.Location)
-		}
-		if !excludedFields["temperature"] && w.Temperature != nil {
-			fmt.Printf("Temperature: %.1f %s\n", w.Temperature.Qty, w.Temperature.Units)
-		}
 		fmt.Println()
 	}
 
 	// Print any custom data requested
-	PrintCustom(data.AllWorkouts, opts)
+	PrintCustom(workouts, opts)
 
 	return nil
 }
 
-// PrintWorkoutsCompact with exclude field support
+// PrintWorkoutsCompact prints a table of workouts with one column per selected field
 func PrintWorkoutsCompact(workouts []models.Workout, opts PrintOptions) error {
-	// Create excluded fields map
-	excludedFields := make(map[string]bool)
-	for _, field := range opts.ExcludeFields {
-		excludedFields[strings.ToLower(field)] = true
+	columns, err := fields.Workouts.Select(opts.IncludeFields, opts.ExcludeFields, func(f *fields.Field[models.Workout]) bool { return f.Table })
+	if err != nil {
+		return err
 	}
+	printTable(workouts, columns, opts.fieldContext("2006-01-02 15:04"))
+	return nil
+}
 
-	// Determine which columns to show
-	showName := !excludedFields["name"]
-	showStart := !excludedFields["start"] && !excludedFields["time"]
-	showDuration := !excludedFields["duration"]
-	showDistance := !excludedFields["distance"]
-	showEnergy := !excludedFields["energy"]
-
-	// Print headers incl. name, start, duration, distance, and energy
+// printTable prints padded headers, a separator and one row per record, truncating values to the column widths
+func printTable[T any](records []T, columns []*fields.Field[T], ctx fields.Context) {
 	var headers []string
-	if showName {
-		headers = append(headers, fmt.Sprintf("%-20s", "Name"))
-	}
-	if showStart {
-		headers = append(headers, fmt.Sprintf("%-19s", "Start"))
-	}
-	if showDuration {
-		headers = append(headers, fmt.Sprintf("%-8s", "Duration"))
-	}
-	if showDistance {
-		headers = append(headers, fmt.Sprintf("%-10s", "Distance"))
-	}
-	if showEnergy {
-		headers = append(headers, fmt.Sprintf("%-10s", "Energy"))
+	for _, f := range columns {
+		headers = append(headers, fmt.Sprintf("%-*s", f.ColumnWidth(), f.Title))
 	}
-	// Print padded headers and separator
 	fmt.Println(strings.Join(headers, " "))
 	fmt.Println(strings.Repeat("-", len(strings.Join(headers, " "))))
 
-	// Print each workout
-	for _, w := range workouts {
-		// Only print fields that aren't excluded
-		var fields []string
-
-		// Handle name, start, duration, distance, and energy
-		if showName {
-			fields = append(fields, fmt.Sprintf("%-20s", utils.Truncate(w.Name, 20)))
-		}
-		if showStart {
-			start, err := time.Parse(config.TimeFormat, w.Start)
-			startStr := "-"
-			if err == nil {
-				startStr = start.Format("2006-01-02 15:04")
-			}
-			fields = append(fields, fmt.Sprintf("%-19s", startStr))
-		}
-		if showDuration {
-			fields = append(fields, fmt.Sprintf("%-8s", utils.FormatTime(w.Duration)))
-		}
-		if showDistance {
-			distance := "-"
-			if w.Distance != nil {
-				distance = fmt.Sprintf("%.1f%s", w.Distance.Qty, w.Distance.Units)
-			}
-			fields = append(fields, fmt.Sprintf("%-10s", distance))
+	for _, record := range records {
+		var cells []string
+		for _, f := range columns {
+			value := f.String(f.Get(record, ctx), ctx)
+			cells = append(cells, fmt.Sprintf("%-*s", f.ColumnWidth(), utils.Truncate(value, f.ColumnWidth())))
 		}
-		if showEnergy {
-			energy := "-"
-			if w.ActiveEnergyBurned != nil {
-				energy = fmt.Sprintf("%.0f%s", w.ActiveEnergyBurned.Qty, w.ActiveEnergyBurned.Units)
-			}
-			fields = append(fields, fmt.Sprintf("%-10s", energy))
-		}
-		// Print padded fields
-		fmt.Println(strings.Join(fields, " "))
+		fmt.Println(strings.Join(cells, " "))
 	}
-
-	// Print any custom data requested
-	PrintCustom(data.AllWorkouts, opts)
-
-	return nil
 }
 
 // printMetrics handles the display of metric data
 // It follows similar patterns to printWorkouts for filtering and limiting
 func PrintMetrics(metrics []models.Metric, opts PrintOptions) error {
-	// Apply filtering if specified
-	if opts.Filter != nil {
-		var filtered []models.Metric
-		for _, m := range metrics {
-			if opts.Filter(m) {
-				filtered = append(filtered, m)
-			}
-		}
-		metrics = filtered
+	// Keep the selected metrics and data points, only print the reports if no listing is wanted
+	metrics = SelectMetrics(metrics, opts)
+	if opts.ReportsOnly {
+		PrintMetricReports(metrics, opts)
+		return nil
+	}
+
+	// Reject field names that neither metrics nor their data points have
+	if err := fields.Check("field", append(append([]string(nil), opts.IncludeFields...), opts.ExcludeFields...), fields.Metrics, fields.Points); err != nil {
+		return err
+	}
+
+	// Sort the metrics by the requested fields
+	listed, err := SortMetrics(metrics, opts)
+	if err != nil {
+		return err
 	}
 
 	// Apply maximum items limit if specified
-	if opts.MaxItems > 0 && len(metrics) > opts.MaxItems {
-		metrics = metrics[:opts.MaxItems]
+	if opts.MaxItems > 0 && len(listed) > opts.MaxItems {
+		listed = listed[:opts.MaxItems]
 	}
 
+	// Use compact mode if specified, one line per metric
+	if opts.Compact {
+		if err := PrintMetricsCompact(listed, opts); err != nil {
+			return err
+		}
+		PrintMetricReports(metrics, opts)
+		return nil
+	}
+
+	// The header names the metric and its units, the selected point fields follow on one line per point
+	include, exclude := only(opts.IncludeFields, fields.Points), only(opts.ExcludeFields, fields.Points)
+	columns, err := fields.Points.Select(include, exclude, func(f *fields.Field[fields.Point]) bool { return f.Detail })
+	if err != nil {
+		return err
+	}
+	units, _ := fields.Points.Lookup("units")
+	showUnits := !fields.List(opts.ExcludeFields).Has("units")
+
 	// Print each metric and its data points
-	for i, m := range metrics {
+	ctx := opts.fieldContext(opts.TimeFormat)
+	for i, m := range listed {
 		if i > 0 {
 			fmt.Println()
 		}
-		fmt.Printf("Metric: %s (%s)\n", m.Name, m.Units)
+		// Known metrics are headed by their display name, followed by the name to select them with
+		heading := m.Name
+		if info, ok := opts.Metrics.Lookup(m.Name); ok {
+			heading = fmt.Sprintf("%s [%s]", info.Display, m.Name)
+		}
+		if showUnits {
+			fmt.Printf("Metric: %s (%s)\n", heading, units.String(units.Get(fields.Point{Metric: m}, ctx), ctx))
+		} else {
+			fmt.Printf("Metric: %s\n", heading)
+		}
 		fmt.Println(strings.Repeat("-", 40))
 
-		// Print each data point with formatted date
+		// Print each data point with formatted date, followed by the labelled readings it has such as
+		// min and max or sleep stages, marking values outside the metric's valid range
 		for _, d := range m.Data {
-			date, _ := time.Parse(time.RFC3339, d.Date)
-			fmt.Printf("%s: %.2f\n", date.Format(opts.TimeFormat), d.Qty)
+			point := fields.Point{Metric: m, Data: d}
+			var cells, details []string
+			for _, f := range columns {
+				v := f.Get(point, ctx)
+				switch {
+				case !f.Reading:
+					cells = append(cells, f.String(v, ctx))
+				case !v.Missing:
+					details = append(details, f.Heading()+" "+f.String(v, ctx))
+				}
+			}
+			if len(cells)+len(details) == 0 {
+				continue
+			}
+			line := strings.Join(cells, ": ")
+			if len(details) > 0 {
+				line = strings.TrimSpace(line + " (" + strings.Join(details, ", ") + ")")
+			}
+			if !fields.Valid(point, ctx) {
+				line += fmt.Sprintf(" (outside %s)", opts.Metrics.Info(m.Name).Range())
+			}
+			fmt.Println(line)
 		}
 	}
+
+	// Print any reports requested
+	PrintMetricReports(metrics, opts)
+	return nil
+}
+
+// PrintMetricsCompact lists one line per metric with its number of data points,
+// the dates of the first and last one and the latest value
+func PrintMetricsCompact(metrics []models.Metric, opts PrintOptions) error {
+	include, exclude := only(opts.IncludeFields, fields.Metrics), only(opts.ExcludeFields, fields.Metrics)
+	columns, err := fields.Metrics.Select(include, exclude, func(f *fields.Field[models.Metric]) bool { return f.Table })
+	if err != nil {
+		return err
+	}
+	printTable(metrics, columns, opts.fieldContext(opts.TimeFormat))
 	return nil
 }
 
+// only keeps the field names a set knows, since the fields of metrics and of their data points
+// are selected with the same -i and -x lists
+func only(names []string, set fields.Set) []string {
+	var known []string
+	for _, name := range names {
+		if set.Has(name) {
+			known = append(known, name)
+		}
+	}
+	return known
+}
+
 // PrintCustom flags incl. workoutsPerMonth
 func PrintCustom(workouts []models.Workout, opts PrintOptions) {
 
@@ -262,66 +260,114 @@ func PrintCustom(workouts []models.Workout, opts PrintOptions) {
 	}
 }
 
+// PrintWorkoutsPerMonth prints the number of workouts per month
 func PrintWorkoutsPerMonth(workouts []models.Workout, opts PrintOptions) {
-	printAggregatedData(
-		utils.CalculateWorkoutsPerMonth(workouts),
-		"Workouts Per Month",
-		opts,
-		func(k string, v int) string { return fmt.Sprintf("%s: %d", k, v) },
-	)
+	printPreset(workouts, stats.WorkoutsPerMonth, opts)
 }
 
+// PrintDistancePerWorkout prints the total distance of each workout name
 func PrintDistancePerWorkout(workouts []models.Workout, opts PrintOptions) {
-	printAggregatedData(
-		utils.CalculateDistancePerWorkout(workouts),
-		"Distance Per Workout",
-		opts,
-		func(k string, v float64) string {
-			if len(k) > 20 {
-				k = k[:25] + "..."
-			}
-			return fmt.Sprintf("%-30s %-7.2f miles", k, v)
-		},
-	)
+	printPreset(workouts, stats.DistancePerWorkout, opts)
 }
 
+// PrintDistancePerWeek prints the total distance per week
 func PrintDistancePerWeek(workouts []models.Workout, opts PrintOptions) {
-	printAggregatedData(
-		utils.CalculateDistancePerWeek(workouts),
-		"Distance Per Week",
-		opts,
-		func(k string, v float64) string { return fmt.Sprintf("%s: %.2f miles", k, v) },
-	)
+	printPreset(workouts, stats.DistancePerWeek, opts)
 }
 
+// PrintEnergyPerWeek prints the total active energy per week
 func PrintEnergyPerWeek(workouts []models.Workout, opts PrintOptions) {
-	printAggregatedData(
-		utils.CalculateEnergyPerWeek(workouts),
-		"Energy Burned Per Week",
-		opts,
-		func(k string, v float64) string { return fmt.Sprintf("%-13s%.2f kcal", k+":", v) },
-	)
+	printPreset(workouts, stats.EnergyPerWeek, opts)
 }
 
-func printAggregatedData[T any](data map[string]T, title string, opts PrintOptions, formatFunc func(string, T) string) {
-	var keys []string
-	for key := range data {
-		keys = append(keys, key)
+// printPreset prints the report of a preset, reporting errors in place of the table
+// -n and -desc apply to the rows as they do to the listing, -sort orders them when it names key or value
+func printPreset(workouts []models.Workout, preset stats.Preset, opts PrintOptions) {
+	if err := PrintWorkoutStats(workouts, preset.Title, preset.By, preset.Measure, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error computing %s: %v\n", strings.ToLower(preset.Title), err)
 	}
-	if opts.SortDesc {
-		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
-	} else {
-		sort.Strings(keys)
+}
+
+// PrintMetricReports prints the reports of the preset flags over the selected metric data points
+func PrintMetricReports(metrics []models.Metric, opts PrintOptions) {
+	if opts.WorkoutsPerMonth {
+		printMetricPreset(metrics, stats.PointsPerMonth, opts)
 	}
-	if opts.MaxItems > 0 && len(keys) > opts.MaxItems {
-		keys = keys[:opts.MaxItems]
+	if opts.DistancePerWorkout {
+		printMetricPreset(metrics, stats.DistancePerMetric, opts)
+	}
+	if opts.DistancePerWeek {
+		printMetricPreset(metrics, stats.MetricDistancePerWeek, opts)
+	}
+	if opts.EnergyPerWeek {
+		printMetricPreset(metrics, stats.MetricEnergyPerWeek, opts)
+	}
+}
+
+// printMetricPreset prints the report of a preset over the data points of the metrics it covers
+func printMetricPreset(metrics []models.Metric, preset stats.Preset, opts PrintOptions) {
+	var covered []models.Metric
+	for _, m := range metrics {
+		if preset.Covers(m) {
+			covered = append(covered, m)
+		}
+	}
+	report, err := stats.ParseReport(preset.By, preset.Measure, fields.Points, opts.Week)
+	if err == nil {
+		err = PrintStats(report.Group(fields.PointsOf(covered), opts.fieldContext(opts.TimeFormat)), preset.Title, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error computing %s: %v\n", strings.ToLower(preset.Title), err)
+	}
+}
+
+// PrintWorkoutStats groups the workouts by the dimensions, summarizes each group with the measures
+// and prints the table under a title
+func PrintWorkoutStats(workouts []models.Workout, title, by, measure string, opts PrintOptions) error {
+	report, err := stats.ParseReport(by, measure, fields.Workouts, opts.Week)
+	if err != nil {
+		return err
+	}
+	return PrintStats(report.Group(workouts, opts.fieldContext(opts.TimeFormat)), title, opts)
+}
+
+// PrintStats prints a table of measures with a column per dimension and measure,
+// sorted by -sort key or value and limited to -n rows
+func PrintStats(table stats.Table, title string, opts PrintOptions) error {
+	table, err := SortStats(table, opts)
+	if err != nil {
+		return err
+	}
+	rows := table.Rows
+	if opts.MaxItems > 0 && len(rows) > opts.MaxItems {
+		rows = rows[:opts.MaxItems]
+	}
+
+	// Lay out the header and the rows, then pad every column to its widest cell
+	lines := [][]string{append(append([]string(nil), table.Dimensions...), table.Measures...)}
+	for _, row := range rows {
+		line := append([]string(nil), row.Keys...)
+		for i := range table.Measures {
+			line = append(line, table.Format(row, i))
+		}
+		lines = append(lines, line)
+	}
+	widths := make([]int, len(lines[0]))
+	for _, line := range lines {
+		for i, cell := range line {
+			widths[i] = max(widths[i], len(cell))
+		}
 	}
 
 	fmt.Println()
 	fmt.Println(title)
 	fmt.Println(strings.Repeat("-", 50))
-	for _, key := range keys {
-		fmt.Println(formatFunc(key, data[key]))
+	for _, line := range lines {
+		for i := range line {
+			line[i] = fmt.Sprintf("%-*s", widths[i], line[i])
+		}
+		fmt.Println(strings.TrimRight(strings.Join(line, "  "), " "))
 	}
 	fmt.Println()
+	return nil
 }
@@ -0,0 +1,53 @@
+// printer/select.go
+// Selecting the records that listings and reports are computed over
+
+package printer
+
+import (
+	"fitness/models"
+)
+
+// SelectWorkouts keeps the workouts that pass the filter, in order
+func SelectWorkouts(workouts []models.Workout, opts PrintOptions) []models.Workout {
+	if opts.Filter == nil {
+		return workouts
+	}
+	var selected []models.Workout
+	for _, w := range workouts {
+		if opts.Filter(w) {
+			selected = append(selected, w)
+		}
+	}
+	return selected
+}
+
+// SelectMetrics keeps the data points that pass the point filter and then the metrics that pass the filter
+// Metrics left without data points are dropped when there is a point filter
+func SelectMetrics(metrics []models.Metric, opts PrintOptions) []models.Metric {
+	if opts.PointFilter != nil {
+		var selected []models.Metric
+		for _, m := range metrics {
+			metric := models.Metric{Name: m.Name, Units: m.Units}
+			for _, d := range m.Data {
+				if opts.PointFilter(m, d) {
+					metric.Data = append(metric.Data, d)
+				}
+			}
+			if len(metric.Data) > 0 {
+				selected = append(selected, metric)
+			}
+		}
+		metrics = selected
+	}
+
+	if opts.Filter == nil {
+		return metrics
+	}
+	var selected []models.Metric
+	for _, m := range metrics {
+		if opts.Filter(m) {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
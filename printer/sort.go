@@ -0,0 +1,200 @@
+// printer/sort.go
+package printer
+
+import (
+	"fitness/fields"
+	"fitness/models"
+	"fitness/stats"
+	"sort"
+	"strings"
+)
+
+// SortKey is one field of a sort order
+type SortKey struct {
+	Field string // Field name or alias, looked up in the fields of each table
+	Desc  bool   // Whether the field sorts in descending order
+}
+
+// sortValue is the value of a field that records are compared by
+// Missing values always sort after present ones, whatever the direction
+type sortValue struct {
+	text    string
+	number  float64
+	isText  bool
+	missing bool
+}
+
+func textValue(s string) sortValue    { return sortValue{text: strings.ToLower(s), isText: true} }
+func numberValue(n float64) sortValue { return sortValue{number: n} }
+func missingValue() sortValue         { return sortValue{missing: true} }
+
+// fieldValue reads the value a record is sorted by from the field registry
+// Quantities compare in the display units, or as recorded if their unit does not convert
+func fieldValue[T any](f *fields.Field[T], record T, ctx fields.Context) sortValue {
+	v := f.Get(record, ctx)
+	switch {
+	case v.Missing:
+		return missingValue()
+	case f.Kind == fields.Text:
+		return textValue(v.Text)
+	case f.Kind == fields.Time:
+		if !v.Time.Valid() {
+			return missingValue()
+		}
+		return numberValue(float64(v.Time.Time.UnixNano()))
+	}
+	if n, ok := f.Number(v, ctx); ok {
+		return numberValue(n)
+	}
+	return missingValue()
+}
+
+// aggregateSortFields lists the fields of aggregated tables
+var aggregateSortFields = fields.List{"key", "value"}
+
+// ParseSortKeys reads a comma-separated sort order such as "name,-distance"
+// A leading - sorts that field in descending order, desc reverses every field
+func ParseSortKeys(spec string, desc bool) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		key := SortKey{Field: strings.TrimLeft(part, "+-"), Desc: strings.HasPrefix(part, "-")}
+		if !fields.Workouts.Has(key.Field) && !fields.Metrics.Has(key.Field) && !aggregateSortFields.Has(key.Field) {
+			return nil, fields.Unknown("sort field", key.Field, fields.Workouts, fields.Metrics, aggregateSortFields)
+		}
+		key.Desc = key.Desc != desc
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// sortKeys returns the sort keys that apply to a table, falling back to the default field
+// With no sort fields at all, -desc still reverses the default order
+func sortKeys(opts PrintOptions, known func(string) bool, defaultField string) ([]SortKey, error) {
+	keys, err := ParseSortKeys(opts.SortBy, opts.SortDesc)
+	if err != nil {
+		return nil, err
+	}
+	var applicable []SortKey
+	for _, key := range keys {
+		if known(key.Field) {
+			applicable = append(applicable, key)
+		}
+	}
+	if len(applicable) == 0 && opts.SortDesc {
+		applicable = []SortKey{{Field: defaultField, Desc: true}}
+	}
+	return applicable, nil
+}
+
+// compareValues orders two values, placing missing values last
+func compareValues(a, b sortValue, desc bool) int {
+	switch {
+	case a.missing && b.missing:
+		return 0
+	case a.missing:
+		return 1
+	case b.missing:
+		return -1
+	}
+
+	result := 0
+	if a.isText || b.isText {
+		result = strings.Compare(a.text, b.text)
+	} else if a.number < b.number {
+		result = -1
+	} else if a.number > b.number {
+		result = 1
+	}
+	if desc {
+		result = -result
+	}
+	return result
+}
+
+// SortWorkouts returns the workouts stably sorted by the print options' sort order
+func SortWorkouts(workouts []models.Workout, opts PrintOptions) ([]models.Workout, error) {
+	keys, err := sortKeys(opts, fields.Workouts.Has, "start")
+	if err != nil || len(keys) == 0 {
+		return workouts, err
+	}
+
+	ctx := opts.fieldContext(opts.TimeFormat)
+	sorted := append([]models.Workout(nil), workouts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for _, key := range keys {
+			f, _ := fields.Workouts.Lookup(key.Field)
+			if c := compareValues(fieldValue(f, sorted[i], ctx), fieldValue(f, sorted[j], ctx), key.Desc); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return sorted, nil
+}
+
+// SortMetrics returns the metrics stably sorted by the print options' sort order
+func SortMetrics(metrics []models.Metric, opts PrintOptions) ([]models.Metric, error) {
+	keys, err := sortKeys(opts, fields.Metrics.Has, "name")
+	if err != nil || len(keys) == 0 {
+		return metrics, err
+	}
+
+	ctx := opts.fieldContext(opts.TimeFormat)
+	sorted := append([]models.Metric(nil), metrics...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for _, key := range keys {
+			f, _ := fields.Metrics.Lookup(key.Field)
+			if c := compareValues(fieldValue(f, sorted[i], ctx), fieldValue(f, sorted[j], ctx), key.Desc); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return sorted, nil
+}
+
+// SortStats orders the rows of a grouped table by key, in the order of the dimensions' keys,
+// or by value, the first measure
+func SortStats(table stats.Table, opts PrintOptions) (stats.Table, error) {
+	order, err := sortKeys(opts, aggregateSortFields.Has, "key")
+	if err != nil || len(order) == 0 {
+		return table, err
+	}
+
+	// Rows come in the order of their keys, so their position is the key order
+	positions := make([]int, len(table.Rows))
+	for i := range positions {
+		positions[i] = i
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		for _, key := range order {
+			a, b := numberValue(float64(positions[i])), numberValue(float64(positions[j]))
+			if key.Field == "value" {
+				a, b = measureValue(table.Rows[positions[i]]), measureValue(table.Rows[positions[j]])
+			}
+			if c := compareValues(a, b, key.Desc); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	sorted := table
+	sorted.Rows = make([]stats.Row, len(positions))
+	for i, position := range positions {
+		sorted.Rows[i] = table.Rows[position]
+	}
+	return sorted, nil
+}
+
+// measureValue is the first measure of a row, missing if it has no value
+func measureValue(row stats.Row) sortValue {
+	if len(row.Values) == 0 || !row.Valid[0] {
+		return missingValue()
+	}
+	return numberValue(row.Values[0])
+}
@@ -0,0 +1,69 @@
+// printer/workout.go
+package printer
+
+import (
+	"fmt"
+	"strings"
+
+	"fitness/fields"
+	"fitness/models"
+)
+
+// PrintWorkoutDetail prints the fields a workout has a value for, followed by what its series hold
+func PrintWorkoutDetail(w models.Workout, opts PrintOptions) error {
+	columns, err := fields.Workouts.Select(opts.IncludeFields, opts.ExcludeFields, func(f *fields.Field[models.Workout]) bool { return f.Detail })
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Workout Detail:")
+	fmt.Println(strings.Repeat("-", 80))
+	ctx := opts.fieldContext(opts.TimeFormat)
+	for _, f := range columns {
+		if v := f.Get(w, ctx); !v.Missing {
+			fmt.Printf("%s: %s\n", f.Heading(), f.String(v, ctx))
+		}
+	}
+
+	// List the series with the time they span, the summaries above are computed from them
+	fmt.Println()
+	fmt.Println("Series:")
+	if w.Series().IsEmpty() {
+		fmt.Println("  none recorded")
+		return nil
+	}
+	if len(w.Route) > 0 {
+		first, last := w.Route[0].Timestamp, w.Route[len(w.Route)-1].Timestamp
+		fmt.Printf("  Route: %s%s\n", count(len(w.Route), "point"), span(first, last, ctx))
+	}
+	printSamples("Heart rate", w.HeartRateData, "reading", ctx)
+	printSamples("Heart rate recovery", w.HeartRateRecovery, "reading", ctx)
+	printSamples("Steps", w.StepCount, "sample", ctx)
+	printSamples("Elevation", w.ElevationUp, "sample", ctx)
+	return nil
+}
+
+// printSamples prints the size and time span of a sample series, nothing if it is empty
+func printSamples(name string, samples models.Samples, noun string, ctx fields.Context) {
+	if len(samples) == 0 {
+		return
+	}
+	fmt.Printf("  %s: %s%s\n", name, count(len(samples), noun), span(samples[0].Date, samples[len(samples)-1].Date, ctx))
+}
+
+// span describes the time from the first to the last entry of a series, empty if it is not timed
+func span(first, last models.Timestamp, ctx fields.Context) string {
+	if !first.Valid() || !last.Valid() {
+		return ""
+	}
+	return fmt.Sprintf(", %s to %s", ctx.Zone.Format(first, "15:04:05"), ctx.Zone.Format(last, "15:04:05"))
+}
+
+// count gives a number of things, with the noun in the plural unless there is one
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,136 @@
+// quantity/quantity.go
+// Physical quantities with units and conversions between them
+
+package quantity
+
+import (
+	"fitness/models"
+	"fmt"
+	"strings"
+)
+
+// Dimension is the kind of physical quantity a unit measures
+type Dimension string
+
+const (
+	Distance    Dimension = "distance"
+	Energy      Dimension = "energy"
+	Temperature Dimension = "temperature"
+	Mass        Dimension = "mass"
+	Speed       Dimension = "speed"
+	Duration    Dimension = "duration"
+)
+
+// Unit is a unit of measurement within a dimension
+// A value in the unit converts to the dimension's base unit as value*scale + offset
+type Unit struct {
+	Symbol    string    // Canonical symbol, e.g. km
+	Dimension Dimension // What the unit measures
+	scale     float64   // Size of the unit in base units
+	offset    float64   // Zero point of the unit in base units, only used by temperatures
+}
+
+// Units of each dimension, the base units are m, kcal, degC, kg, m/s and s
+var (
+	Meter       = Unit{"m", Distance, 1, 0}
+	Kilometer   = Unit{"km", Distance, 1000, 0}
+	Mile        = Unit{"mi", Distance, 1609.344, 0}
+	Yard        = Unit{"yd", Distance, 0.9144, 0}
+	Foot        = Unit{"ft", Distance, 0.3048, 0}
+	Kilocalorie = Unit{"kcal", Energy, 1, 0}
+	Calorie     = Unit{"cal", Energy, 0.001, 0}
+	Kilojoule   = Unit{"kJ", Energy, 1 / 4.184, 0}
+	Celsius     = Unit{"degC", Temperature, 1, 0}
+	Fahrenheit  = Unit{"degF", Temperature, 5.0 / 9.0, -32 * 5.0 / 9.0}
+	Kilogram    = Unit{"kg", Mass, 1, 0}
+	Gram        = Unit{"g", Mass, 0.001, 0}
+	Pound       = Unit{"lb", Mass, 0.45359237, 0}
+	MeterSec    = Unit{"m/s", Speed, 1, 0}
+	KmHour      = Unit{"km/hr", Speed, 1000.0 / 3600, 0}
+	MileHour    = Unit{"mi/hr", Speed, 1609.344 / 3600, 0}
+	Second      = Unit{"s", Duration, 1, 0}
+	Minute      = Unit{"min", Duration, 60, 0}
+	Hour        = Unit{"hr", Duration, 3600, 0}
+)
+
+// unitsBySymbol maps every accepted spelling of a unit, in lower case, to the unit
+// Spellings that could mean two units, such as calories or a bare c and f, are not accepted
+var unitsBySymbol = map[string]Unit{
+	"m": Meter, "meter": Meter, "meters": Meter,
+	"km": Kilometer, "kilometer": Kilometer, "kilometers": Kilometer,
+	"mi": Mile, "mile": Mile, "miles": Mile,
+	"yd": Yard, "yard": Yard, "yards": Yard,
+	"ft": Foot, "foot": Foot, "feet": Foot,
+	"kcal": Kilocalorie, "kilocalorie": Kilocalorie, "kilocalories": Kilocalorie,
+	"cal":  Calorie,
+	"kj":   Kilojoule,
+	"degc": Celsius, "°c": Celsius,
+	"degf": Fahrenheit, "°f": Fahrenheit,
+	"kg": Kilogram, "g": Gram,
+	"lb": Pound, "lbs": Pound,
+	"m/s":   MeterSec,
+	"km/hr": KmHour, "km/h": KmHour, "kph": KmHour,
+	"mi/hr": MileHour, "mi/h": MileHour, "mph": MileHour,
+	"s": Second, "sec": Second, "seconds": Second,
+	"min": Minute, "minutes": Minute,
+	"hr": Hour, "h": Hour, "hours": Hour,
+}
+
+// ParseUnit looks up a unit by its symbol or name
+func ParseUnit(symbol string) (Unit, error) {
+	unit, ok := unitsBySymbol[strings.ToLower(strings.TrimSpace(symbol))]
+	if !ok {
+		return Unit{}, fmt.Errorf("unknown unit %q", symbol)
+	}
+	return unit, nil
+}
+
+// Quantity is a value in a unit
+type Quantity struct {
+	Value float64
+	Unit  Unit
+}
+
+// New creates a quantity from a value and a unit symbol
+func New(value float64, symbol string) (Quantity, error) {
+	unit, err := ParseUnit(symbol)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: value, Unit: unit}, nil
+}
+
+// FromMeasurement converts a measurement read from an export into a quantity
+func FromMeasurement(m *models.Measurement) (Quantity, error) {
+	if m == nil {
+		return Quantity{}, fmt.Errorf("no measurement")
+	}
+	return New(m.Qty, m.Units)
+}
+
+// In converts the quantity to another unit of the same dimension
+func (q Quantity) In(unit Unit) (Quantity, error) {
+	if q.Unit.Dimension != unit.Dimension {
+		return Quantity{}, fmt.Errorf("cannot convert %s (%s) to %s (%s)", q.Unit.Symbol, q.Unit.Dimension, unit.Symbol, unit.Dimension)
+	}
+	if q.Unit == unit {
+		return q, nil
+	}
+	base := q.Value*q.Unit.scale + q.Unit.offset
+	return Quantity{Value: (base - unit.offset) / unit.scale, Unit: unit}, nil
+}
+
+// Add returns the sum of two quantities in the unit of q
+// Quantities of different dimensions cannot be added
+func (q Quantity) Add(other Quantity) (Quantity, error) {
+	converted, err := other.In(q.Unit)
+	if err != nil {
+		return Quantity{}, fmt.Errorf("cannot add %s to %s: %v", other.Unit.Symbol, q.Unit.Symbol, err)
+	}
+	return Quantity{Value: q.Value + converted.Value, Unit: q.Unit}, nil
+}
+
+// String formats the quantity with two decimals and its unit symbol
+func (q Quantity) String() string {
+	return fmt.Sprintf("%.2f %s", q.Value, q.Unit.Symbol)
+}
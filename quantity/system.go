@@ -0,0 +1,93 @@
+// quantity/system.go
+// Unit systems used for display
+
+package quantity
+
+import (
+	"fitness/models"
+	"fmt"
+	"strings"
+)
+
+// System is a set of preferred display units
+type System string
+
+const (
+	Metric   System = "metric"   // Kilometers, kilograms, degrees Celsius
+	Imperial System = "imperial" // Miles, pounds, degrees Fahrenheit
+)
+
+// displayUnits lists the unit each system uses for each dimension
+var displayUnits = map[System]map[Dimension]Unit{
+	Metric: {
+		Distance:    Kilometer,
+		Energy:      Kilocalorie,
+		Temperature: Celsius,
+		Mass:        Kilogram,
+		Speed:       KmHour,
+		Duration:    Second,
+	},
+	Imperial: {
+		Distance:    Mile,
+		Energy:      Kilocalorie,
+		Temperature: Fahrenheit,
+		Mass:        Pound,
+		Speed:       MileHour,
+		Duration:    Second,
+	},
+}
+
+// ParseSystem converts a unit system name into a System, an empty name is imperial
+func ParseSystem(name string) (System, error) {
+	switch system := System(strings.ToLower(strings.TrimSpace(name))); system {
+	case Metric, Imperial:
+		return system, nil
+	case "":
+		return Imperial, nil
+	default:
+		return "", fmt.Errorf("unknown unit system %q (expected metric or imperial)", name)
+	}
+}
+
+// Unit returns the display unit of a dimension, an unset system is imperial
+func (s System) Unit(dimension Dimension) Unit {
+	units, ok := displayUnits[s]
+	if !ok {
+		units = displayUnits[Imperial]
+	}
+	return units[dimension]
+}
+
+// Convert converts a quantity to the system's unit for its dimension
+func (s System) Convert(q Quantity) Quantity {
+	converted, err := q.In(s.Unit(q.Unit.Dimension))
+	if err != nil {
+		return q
+	}
+	return converted
+}
+
+// Measurement converts a measurement to the system's units
+// Measurements in units the package does not know are returned unchanged
+func (s System) Measurement(m models.Measurement) models.Measurement {
+	q, err := New(m.Qty, m.Units)
+	if err != nil {
+		return m
+	}
+	converted := s.Convert(q)
+	return models.Measurement{Units: converted.Unit.Symbol, Qty: converted.Value}
+}
+
+// Value converts a measurement to the system's unit for a dimension
+// It fails if the measurement is in an unknown unit or measures something else
+func (s System) Value(m *models.Measurement, dimension Dimension) (float64, error) {
+	q, err := FromMeasurement(m)
+	if err != nil {
+		return 0, err
+	}
+	converted, err := q.In(s.Unit(dimension))
+	if err != nil {
+		return 0, err
+	}
+	return converted.Value, nil
+}
@@ -0,0 +1,329 @@
+// query/eval.go
+// Values and the evaluation of parsed expressions
+
+package query
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/utils"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Env holds what expressions need to know besides the record
+type Env struct {
+	Units quantity.System    // Unit system numbers without a unit are read in
+	Zone  models.Zone        // Zone deciding the weekday, hour and calendar day of a time
+	Dates utils.DateResolver // Resolves date() and text compared with times, and its clock
+}
+
+// kind is the type of a value
+type kind int
+
+const (
+	nullKind     kind = iota // Missing optional field, or null
+	boolKind                 // Result of a comparison, true or false
+	numberKind               // Number without a unit
+	quantityKind             // Number with a unit
+	unitKind                 // Number literal with a unit whose dimension depends on what it is compared with
+	textKind                 // Text
+	timeKind                 // Instant in time
+	spanKind                 // Span of time such as a day or a month
+	regexKind                // Regular expression
+)
+
+// value is the result of evaluating an expression
+type value struct {
+	kind kind
+	b    bool
+	n    float64
+	q    quantity.Quantity
+	unit quantity.Unit // Unit that numbers compared with a field's quantity are in, the display unit of its dimension if unset
+	s    string        // Text, or the literal of a unit value
+	t    time.Time
+	span utils.Bucket
+	re   *regexp.Regexp
+}
+
+// null is the value of missing fields
+var null = value{kind: nullKind}
+
+func boolValue(b bool) value      { return value{kind: boolKind, b: b} }
+func numberValue(n float64) value { return value{kind: numberKind, n: n} }
+func textValue(s string) value    { return value{kind: textKind, s: s} }
+
+// truthy reports whether a value counts as true, any present value other than false does
+func (v value) truthy() bool {
+	if v.kind == boolKind {
+		return v.b
+	}
+	return v.kind != nullKind
+}
+
+// unitPattern splits a number literal into its number and unit
+var unitPattern = regexp.MustCompile(`^(-?(?:[0-9]+\.?[0-9]*|\.[0-9]+))(.*)$`)
+
+// parseNumber reads a number literal, returning a unit value if it has a unit
+func parseNumber(text string) (value, error) {
+	parts := unitPattern.FindStringSubmatch(text)
+	if parts == nil {
+		return null, fmt.Errorf("invalid number %q", text)
+	}
+	n, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return null, fmt.Errorf("invalid number %q", text)
+	}
+	if parts[2] == "" {
+		return numberValue(n), nil
+	}
+	return value{kind: unitKind, n: n, s: text}, nil
+}
+
+// resolveUnit turns a unit literal into a quantity of the given dimension
+// Durations may be written like Go durations, so 45m is 45 minutes when compared with a duration
+// A percentage, or a literal compared with a plain number, must have no unit other than %
+func resolveUnit(v value, dimension quantity.Dimension) (value, error) {
+	symbol := unitPattern.FindStringSubmatch(v.s)[2]
+	if symbol == "%" {
+		return numberValue(v.n), nil
+	}
+	if dimension == "" {
+		return null, fmt.Errorf("%s has a unit but is compared with a plain number", v.s)
+	}
+	if dimension == quantity.Duration {
+		if d, err := time.ParseDuration(v.s); err == nil {
+			return value{kind: quantityKind, q: quantity.Quantity{Value: d.Seconds(), Unit: quantity.Second}}, nil
+		}
+	}
+	unit, err := quantity.ParseUnit(symbol)
+	if err != nil {
+		return null, err
+	}
+	if unit.Dimension != dimension {
+		return null, fmt.Errorf("%s is a %s, not a %s", v.s, unit.Dimension, dimension)
+	}
+	return value{kind: quantityKind, q: quantity.Quantity{Value: v.n, Unit: unit}}, nil
+}
+
+// compareNumbers orders two numbers
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// applyOp turns the order of two values into the result of a comparison operator
+func applyOp(op string, c int) bool {
+	switch op {
+	case "==", "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// flippedOps maps each operator to the one that gives the same result with its operands swapped
+var flippedOps = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<=", "=": "=", "==": "==", "!=": "!="}
+
+// literalKinds are the kinds that are converted to match the value they are compared with
+var literalKinds = map[kind]bool{unitKind: true, spanKind: true, regexKind: true}
+
+// compare evaluates a comparison between two values
+// Comparisons with a missing value are false, except that == and != can check for null
+func compare(op string, a, b value, env *Env) bool {
+	if a.kind == nullKind || b.kind == nullKind {
+		switch op {
+		case "==", "=":
+			return a.kind == b.kind
+		case "!=":
+			return a.kind != b.kind
+		}
+		return false
+	}
+
+	// Put the value that needs converting on the right
+	if literalKinds[a.kind] && !literalKinds[b.kind] || a.kind == numberKind && b.kind == quantityKind ||
+		a.kind == textKind && b.kind == timeKind {
+		if op == "~" || op == "!~" {
+			return false
+		}
+		return compare(flippedOps[op], b, a, env)
+	}
+
+	if b.kind == unitKind {
+		dimension := quantity.Dimension("")
+		if a.kind == quantityKind {
+			dimension = a.q.Unit.Dimension
+		}
+		resolved, err := resolveUnit(b, dimension)
+		if err != nil {
+			return false
+		}
+		b = resolved
+	}
+	if b.kind == textKind && a.kind == timeKind {
+		span, err := env.Dates.Span(b.s)
+		if err != nil {
+			return false
+		}
+		b = value{kind: spanKind, span: span}
+	}
+
+	switch {
+	case op == "~" || op == "!~":
+		if a.kind != textKind {
+			return false
+		}
+		var matched bool
+		switch b.kind {
+		case regexKind:
+			matched = b.re.MatchString(a.s)
+		case textKind:
+			matched = strings.Contains(strings.ToLower(a.s), strings.ToLower(b.s))
+		default:
+			return false
+		}
+		return matched == (op == "~")
+
+	case a.kind == quantityKind && b.kind == quantityKind:
+		converted, err := b.q.In(a.q.Unit)
+		if err != nil {
+			return false
+		}
+		return applyOp(op, compareNumbers(a.q.Value, converted.Value))
+
+	case a.kind == quantityKind && b.kind == numberKind:
+		// Numbers without a unit are in the display unit of the field, or of the quantity's dimension
+		converted := env.Units.Convert(a.q)
+		if a.unit.Symbol != "" {
+			if in, err := a.q.In(a.unit); err == nil {
+				converted = in
+			}
+		}
+		return applyOp(op, compareNumbers(converted.Value, b.n))
+
+	case a.kind == numberKind && b.kind == numberKind:
+		return applyOp(op, compareNumbers(a.n, b.n))
+
+	case a.kind == textKind && b.kind == textKind:
+		return applyOp(op, strings.Compare(strings.ToLower(a.s), strings.ToLower(b.s)))
+
+	case a.kind == timeKind && b.kind == timeKind:
+		return applyOp(op, a.t.Compare(b.t))
+
+	case a.kind == timeKind && b.kind == spanKind:
+		// A time equals a span it falls in, is before it if earlier and after it if later
+		if b.span.Start.Equal(b.span.End) {
+			return applyOp(op, a.t.Compare(b.span.Start))
+		}
+		c := 0
+		if a.t.Before(b.span.Start) {
+			c = -1
+		} else if !a.t.Before(b.span.End) {
+			c = 1
+		}
+		return applyOp(op, c)
+
+	case a.kind == boolKind && b.kind == boolKind:
+		switch op {
+		case "==", "=":
+			return a.b == b.b
+		case "!=":
+			return a.b != b.b
+		}
+	}
+	return false
+}
+
+// node is a parsed expression
+type node interface {
+	eval(r record, env *Env) value
+}
+
+// literal is a constant value
+type literal struct {
+	v   value
+	col int
+}
+
+// fieldRef reads a field of the record
+type fieldRef struct {
+	name string
+	f    field
+	col  int
+}
+
+// logical is a && or || of two expressions
+type logical struct {
+	and         bool
+	left, right node
+}
+
+// negation is the ! of an expression
+type negation struct {
+	x node
+}
+
+// comparison compares two expressions with an operator
+type comparison struct {
+	op          string
+	left, right node
+}
+
+// membership checks whether a value equals any of a list, or falls in a span
+type membership struct {
+	left   node
+	items  []node
+	negate bool
+}
+
+func (n literal) eval(record, *Env) value { return n.v }
+
+func (n fieldRef) eval(r record, env *Env) value { return n.f.get(r, env) }
+
+func (n logical) eval(r record, env *Env) value {
+	left := n.left.eval(r, env).truthy()
+	if n.and != left {
+		// false && x and true || x are decided by the left side
+		return boolValue(left)
+	}
+	return boolValue(n.right.eval(r, env).truthy())
+}
+
+func (n negation) eval(r record, env *Env) value {
+	return boolValue(!n.x.eval(r, env).truthy())
+}
+
+func (n comparison) eval(r record, env *Env) value {
+	return boolValue(compare(n.op, n.left.eval(r, env), n.right.eval(r, env), env))
+}
+
+func (n membership) eval(r record, env *Env) value {
+	left := n.left.eval(r, env)
+	if left.kind == nullKind {
+		return boolValue(false)
+	}
+	for _, item := range n.items {
+		if compare("==", left, item.eval(r, env), env) {
+			return boolValue(!n.negate)
+		}
+	}
+	return boolValue(n.negate)
+}
@@ -0,0 +1,123 @@
+// query/fields.go
+// Fields of workouts and metric data points that expressions can refer to, taken from the field registry
+
+package query
+
+import (
+	"fitness/fields"
+	"fitness/models"
+	"fitness/quantity"
+	"strings"
+	"time"
+)
+
+// record is the workout or metric data point an expression is evaluated against
+type record struct {
+	workout *models.Workout
+	metric  *models.Metric
+	point   *models.MetricData
+}
+
+// field describes a field and how to read it from each kind of record
+type field struct {
+	kind      kind               // Kind of the field's values
+	dimension quantity.Dimension // Dimension of a quantity field, empty if it is only known per record
+	workout   func(w models.Workout, env *Env) value
+	point     func(m models.Metric, d models.MetricData, env *Env) value
+}
+
+// kinds maps the kinds of registry fields to the kinds of expression values
+var kinds = map[fields.Kind]kind{
+	fields.Text:     textKind,
+	fields.Number:   numberKind,
+	fields.Quantity: quantityKind,
+	fields.Time:     timeKind,
+}
+
+// fieldValue turns the value of a registry field into an expression value, null if it is missing
+// Quantities keep the display unit of the field, and those in units the quantity package does not know become plain numbers
+func fieldValue(k fields.Kind, display quantity.Unit, v fields.Value) value {
+	switch {
+	case v.Missing:
+		return null
+	case k == fields.Text:
+		return textValue(v.Text)
+	case k == fields.Time:
+		if !v.Time.Valid() {
+			return null
+		}
+		return value{kind: timeKind, t: v.Time.Time}
+	case k == fields.Quantity:
+		if q, err := quantity.FromMeasurement(v.Measure); err == nil {
+			return value{kind: quantityKind, q: q, unit: display}
+		}
+	}
+	return numberValue(v.Measure.Qty)
+}
+
+// context is the settings registry fields are read with
+func (env *Env) context() fields.Context {
+	return fields.Context{Units: env.Units, Zone: env.Zone}
+}
+
+// fieldsByName and fieldAliases list the fields of workouts and metric data points by name and alias,
+// fields a record does not have are null
+var fieldsByName, fieldAliases = func() (map[string]field, map[string]string) {
+	byName := make(map[string]field)
+	aliases := make(map[string]string)
+	for _, w := range fields.Workouts.All() {
+		byName[w.Name] = field{kind: kinds[w.Kind], dimension: w.Dimension,
+			workout: func(record models.Workout, env *Env) value {
+				return fieldValue(w.Kind, w.DisplayUnit(env.Units), w.Get(record, env.context()))
+			}}
+		for _, alias := range w.Aliases {
+			aliases[alias] = w.Name
+		}
+	}
+	for _, p := range fields.Points.All() {
+		f, ok := byName[p.Name]
+		if !ok {
+			f = field{kind: kinds[p.Kind], dimension: p.Dimension}
+		}
+		f.point = func(m models.Metric, d models.MetricData, env *Env) value {
+			return fieldValue(p.Kind, p.DisplayUnit(env.Units), p.Get(fields.Point{Metric: m, Data: d}, env.context()))
+		}
+		byName[p.Name] = f
+		for _, alias := range p.Aliases {
+			aliases[alias] = p.Name
+		}
+	}
+	return byName, aliases
+}()
+
+// weekdays maps weekday constants such as sat and saturday to the value of the weekday field
+var weekdays = func() map[string]value {
+	names := make(map[string]value)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		names[name] = textValue(name)
+		names[name[:3]] = textValue(name)
+	}
+	return names
+}()
+
+// lookupField finds a field by name or alias
+func lookupField(name string) (field, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+	f, ok := fieldsByName[name]
+	return f, ok
+}
+
+// get reads a field from the record, null if the record has no such field
+func (f field) get(r record, env *Env) value {
+	switch {
+	case r.workout != nil && f.workout != nil:
+		return f.workout(*r.workout, env)
+	case r.point != nil && f.point != nil:
+		return f.point(*r.metric, *r.point, env)
+	}
+	return null
+}
@@ -0,0 +1,139 @@
+// query/lexer.go
+// Splitting --where expressions into tokens
+
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind is the kind of a token
+type tokenKind int
+
+const (
+	tokenEOF    tokenKind = iota
+	tokenIdent            // Field, constant, function or keyword, e.g. distance, sat, date, and
+	tokenNumber           // Number with an optional unit, e.g. 5, 5.5km, 1h30m, 80%
+	tokenString           // Quoted text, e.g. "Pool Swim"
+	tokenRegex            // Regular expression between slashes, e.g. /^outdoor/
+	tokenOp               // Comparison or boolean operator, e.g. >=, ~, &&
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+// token is a lexical unit of an expression
+type token struct {
+	kind tokenKind
+	text string // Text of the token, unquoted for strings and regular expressions
+	col  int    // Column the token starts at, counting from 1
+}
+
+// SyntaxError is an error in an expression, pointing at the column it was found at
+type SyntaxError struct {
+	Source string // The expression
+	Column int    // Column of the error, counting from 1
+	Msg    string // What is wrong
+}
+
+// Error describes the error and draws a caret under the offending column
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at column %d\n  %s\n  %s^", e.Msg, e.Column, e.Source, strings.Repeat(" ", e.Column-1))
+}
+
+// operators lists the operators, longer ones first so >= is not read as >
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "=", "<", ">", "~", "!"}
+
+// lex splits an expression into tokens, ending with an EOF token
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	fail := func(col int, format string, args ...interface{}) ([]token, error) {
+		return nil, &SyntaxError{Source: src, Column: col, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		col := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(' || r == ')' || r == ',':
+			kind := map[rune]tokenKind{'(': tokenLParen, ')': tokenRParen, ',': tokenComma}[r]
+			tokens = append(tokens, token{kind: kind, text: string(r), col: col})
+			i++
+
+		case r == '"' || r == '\'' || r == '/':
+			// Strings and regular expressions run to the matching unescaped delimiter
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) && (runes[j+1] == r || runes[j+1] == '\\' && r != '/') {
+					j++
+				}
+				text.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				if r == '/' {
+					return fail(col, "unterminated regular expression")
+				}
+				return fail(col, "unterminated string")
+			}
+			i = j + 1
+			if r != '/' {
+				tokens = append(tokens, token{kind: tokenString, text: text.String(), col: col})
+				break
+			}
+			// Flags such as i for case-insensitive matching follow the closing slash
+			flags := i
+			for i < len(runes) && strings.ContainsRune("imsU", runes[i]) {
+				i++
+			}
+			pattern := text.String()
+			if i > flags {
+				pattern = "(?" + string(runes[flags:i]) + ")" + pattern
+			}
+			tokens = append(tokens, token{kind: tokenRegex, text: pattern, col: col})
+
+		case unicode.IsDigit(r) || r == '.' || r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			// Numbers may be followed directly by a unit such as km, degC, km/hr, % or 1h30m
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			if j < len(runes) && (unicode.IsLetter(runes[j]) || runes[j] == '%' || runes[j] == '°') {
+				for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || strings.ContainsRune("%°/.", runes[j])) {
+					j++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[i:j]), col: col})
+			i = j
+
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[i:j]), col: col})
+			i = j
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{kind: tokenOp, text: op, col: col})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return fail(col, "unexpected character %q", r)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, col: len(runes) + 1}), nil
+}
@@ -0,0 +1,389 @@
+// query/parser.go
+// Parsing --where expressions and matching records against them
+
+package query
+
+import (
+	"fitness/fields"
+	"fitness/models"
+	"fitness/utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Expr is a compiled --where expression
+type Expr struct {
+	root node
+	env  Env
+}
+
+// parser reads tokens by recursive descent, from the loosest binding operator to the tightest
+//
+//	or         = and { ("||" | "or") and }
+//	and        = unary { ("&&" | "and") unary }
+//	unary      = ("!" | "not") unary | comparison
+//	comparison = operand [ op operand | ["not"] "in" ( "(" operand { "," operand } ")" | operand ) ]
+//	operand    = number | string | regex | field | constant | function "(" [ operand { "," operand } ] ")" | "(" or ")"
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+	env    *Env
+}
+
+// Compile parses an expression such as name ~ "run" && (distance > 5km || duration > 1h)
+// Errors are *SyntaxError values that point at the offending column
+func Compile(src string, env Env) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens, env: &env}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorAt(tok, "unexpected %s", describe(tok))
+	}
+	return &Expr{root: root, env: env}, nil
+}
+
+// MatchWorkout reports whether a workout satisfies the expression
+func (e *Expr) MatchWorkout(w models.Workout) bool {
+	return e.root.eval(record{workout: &w}, &e.env).truthy()
+}
+
+// MatchPoint reports whether a data point of a metric satisfies the expression
+func (e *Expr) MatchPoint(m models.Metric, d models.MetricData) bool {
+	return e.root.eval(record{metric: &m, point: &d}, &e.env).truthy()
+}
+
+// Match reports whether a workout, or any data point of a metric, satisfies the expression
+// It has the signature of printer.FilterFunc, so the expression can be used as a print filter
+func (e *Expr) Match(v interface{}) bool {
+	switch v := v.(type) {
+	case models.Workout:
+		return e.MatchWorkout(v)
+	case models.Metric:
+		for _, d := range v.Data {
+			if e.MatchPoint(v, d) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// peek returns the current token without consuming it
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes the current token
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword reports whether a token is the given keyword, in any case
+func keyword(tok token, word string) bool {
+	return tok.kind == tokenIdent && strings.EqualFold(tok.text, word)
+}
+
+// describe names a token for error messages
+func describe(tok token) string {
+	switch tok.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string %q", tok.text)
+	case tokenRegex:
+		return fmt.Sprintf("regular expression /%s/", tok.text)
+	}
+	return fmt.Sprintf("%q", tok.text)
+}
+
+// errorAt creates a syntax error at a token
+func (p *parser) errorAt(tok token, format string, args ...interface{}) error {
+	return &SyntaxError{Source: p.src, Column: tok.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.text == "||" && tok.kind == tokenOp || keyword(tok, "or"); tok = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.text == "&&" && tok.kind == tokenOp || keyword(tok, "and"); tok = p.peek() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok := p.peek(); tok.text == "!" && tok.kind == tokenOp || keyword(tok, "not") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negation{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenOp && tok.text != "&&" && tok.text != "||" && tok.text != "!":
+		p.next()
+		rightTok := p.peek()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return p.check(tok, left, right, rightTok)
+
+	case keyword(tok, "in") || keyword(tok, "not") && keyword(p.tokens[min(p.pos+1, len(p.tokens)-1)], "in"):
+		negate := keyword(tok, "not")
+		if negate {
+			p.next()
+		}
+		p.next()
+		return p.parseMembership(left, negate)
+	}
+	return left, nil
+}
+
+// parseMembership reads the list or span after in
+func (p *parser) parseMembership(left node, negate bool) (node, error) {
+	m := membership{left: left, negate: negate}
+	eq := token{kind: tokenOp, text: "=="}
+
+	if p.peek().kind != tokenLParen {
+		tok := p.peek()
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.check(eq, left, item, tok); err != nil {
+			return nil, err
+		}
+		m.items = []node{p.convert(left, item)}
+		return m, nil
+	}
+
+	p.next()
+	for {
+		tok := p.peek()
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.check(eq, left, item, tok); err != nil {
+			return nil, err
+		}
+		m.items = append(m.items, p.convert(left, item))
+		if tok := p.next(); tok.kind == tokenRParen {
+			return m, nil
+		} else if tok.kind != tokenComma {
+			return nil, p.errorAt(tok, "expected , or ) but found %s", describe(tok))
+		}
+	}
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		v, err := parseNumber(tok.text)
+		if err != nil {
+			return nil, p.errorAt(tok, "%v", err)
+		}
+		return literal{v: v, col: tok.col}, nil
+
+	case tokenString:
+		return literal{v: textValue(tok.text), col: tok.col}, nil
+
+	case tokenRegex:
+		re, err := regexp.Compile(tok.text)
+		if err != nil {
+			return nil, p.errorAt(tok, "invalid regular expression: %v", err)
+		}
+		return literal{v: value{kind: regexKind, re: re}, col: tok.col}, nil
+
+	case tokenLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.errorAt(closing, "expected ) but found %s", describe(closing))
+		}
+		return x, nil
+
+	case tokenIdent:
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+		name := strings.ToLower(tok.text)
+		switch name {
+		case "null", "nil":
+			return literal{v: null, col: tok.col}, nil
+		case "true", "false":
+			return literal{v: boolValue(name == "true"), col: tok.col}, nil
+		}
+		if f, ok := lookupField(name); ok {
+			return fieldRef{name: name, f: f, col: tok.col}, nil
+		}
+		if day, ok := weekdays[name]; ok {
+			return literal{v: day, col: tok.col}, nil
+		}
+		var names []string
+		for known := range fieldsByName {
+			names = append(names, known)
+		}
+		for alias := range fieldAliases {
+			names = append(names, alias)
+		}
+		sort.Strings(names)
+		if suggestions := fields.Suggest(name, names); len(suggestions) > 0 {
+			return nil, p.errorAt(tok, "unknown field %q (did you mean %s?)", tok.text, strings.Join(suggestions, " or "))
+		}
+		return nil, p.errorAt(tok, "unknown field %q", tok.text)
+	}
+	return nil, p.errorAt(tok, "unexpected %s", describe(tok))
+}
+
+// parseCall reads a date function and evaluates it, its arguments must be constants
+//
+//	date("2024-07") is the span a date expression names, as accepted by --since and --period
+//	today() is the current day, now() the current instant and daysago(7) the day a week ago
+func (p *parser) parseCall(name token) (node, error) {
+	p.next()
+	var args []literal
+	for p.peek().kind != tokenRParen {
+		if len(args) > 0 {
+			if tok := p.next(); tok.kind != tokenComma {
+				return nil, p.errorAt(tok, "expected , or ) but found %s", describe(tok))
+			}
+		}
+		tok := p.peek()
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		lit, ok := arg.(literal)
+		if !ok {
+			return nil, p.errorAt(tok, "arguments of %s must be constants", name.text)
+		}
+		args = append(args, lit)
+	}
+	p.next()
+
+	span := func(expr string) (node, error) {
+		bucket, err := p.env.Dates.Span(expr)
+		if err != nil {
+			return nil, p.errorAt(name, "%v", err)
+		}
+		return literal{v: value{kind: spanKind, span: bucket}, col: name.col}, nil
+	}
+	switch fn := strings.ToLower(name.text); {
+	case fn == "date" && len(args) == 1 && args[0].v.kind == textKind:
+		return span(args[0].v.s)
+	case fn == "today" && len(args) == 0:
+		return span("today")
+	case fn == "now" && len(args) == 0:
+		return literal{v: value{kind: timeKind, t: p.env.Dates.Time()}, col: name.col}, nil
+	case fn == "daysago" && len(args) == 1 && args[0].v.kind == numberKind:
+		today, _ := p.env.Dates.Span("today")
+		day := utils.Bucketer{Period: utils.Day}.BucketOf(today.Start.AddDate(0, 0, -int(args[0].v.n)))
+		return literal{v: value{kind: spanKind, span: day}, col: name.col}, nil
+	case fn == "date", fn == "today", fn == "now", fn == "daysago":
+		return nil, p.errorAt(name, "wrong arguments for %s, expected date(\"2024-07-01\"), today(), now() or daysago(7)", name.text)
+	}
+	return nil, p.errorAt(name, "unknown function %q", name.text)
+}
+
+// check validates a comparison, converting literals to the kind of the field they are compared with
+func (p *parser) check(op token, left, right node, rightTok token) (node, error) {
+	leftField, leftIsField := left.(fieldRef)
+	rightLit, rightIsLit := right.(literal)
+
+	if op.text == "~" || op.text == "!~" {
+		if leftIsField && leftField.f.kind != textKind {
+			return nil, p.errorAt(op, "%s only matches text, %s is not text", op.text, leftField.name)
+		}
+		return comparison{op: op.text, left: left, right: right}, nil
+	}
+	if rightIsLit && rightLit.v.kind == regexKind {
+		return nil, p.errorAt(rightTok, "regular expressions can only be matched with ~ or !~")
+	}
+
+	if leftIsField && rightIsLit {
+		switch {
+		case rightLit.v.kind == unitKind && leftField.f.kind == quantityKind && leftField.f.dimension != "",
+			rightLit.v.kind == unitKind && leftField.f.kind == numberKind:
+			// Check units against the field now, values of fields without a fixed dimension are checked per record
+			if _, err := resolveUnit(rightLit.v, leftField.f.dimension); err != nil {
+				return nil, p.errorAt(rightTok, "%v", err)
+			}
+		case rightLit.v.kind == textKind && leftField.f.kind == timeKind:
+			if _, err := p.env.Dates.Span(rightLit.v.s); err != nil {
+				return nil, p.errorAt(rightTok, "%v", err)
+			}
+		}
+	}
+	return comparison{op: op.text, left: left, right: p.convert(left, right)}, nil
+}
+
+// convert resolves a literal compared with a field into the field's kind where that is known
+func (p *parser) convert(left, right node) node {
+	leftField, leftIsField := left.(fieldRef)
+	rightLit, rightIsLit := right.(literal)
+	if !leftIsField || !rightIsLit {
+		return right
+	}
+	switch {
+	case rightLit.v.kind == unitKind && leftField.f.dimension != "":
+		if v, err := resolveUnit(rightLit.v, leftField.f.dimension); err == nil {
+			rightLit.v = v
+		}
+	case rightLit.v.kind == textKind && leftField.f.kind == timeKind:
+		if span, err := p.env.Dates.Span(rightLit.v.s); err == nil {
+			rightLit.v = value{kind: spanKind, span: span}
+		}
+	}
+	return rightLit
+}
@@ -0,0 +1,52 @@
+// semantics/builtin.go
+// Metrics Health Auto Export knows about
+
+package semantics
+
+// metric describes a built-in metric with a valid range
+func metric(name, display string, aggregation Aggregation, units string, low, high float64) Info {
+	return Info{Name: name, Display: display, Aggregation: aggregation, Units: units, Min: &low, Max: &high}
+}
+
+// Builtin describes the metrics of Health Auto Export, extended by the config file with Extend
+var Builtin = NewRegistry(
+	// Activity, counted up over the day
+	metric("active_energy", "Active Energy", Sum, "kcal", 0, 5000),
+	metric("basal_energy_burned", "Resting Energy", Sum, "kcal", 0, 5000),
+	metric("step_count", "Steps", Sum, "count", 0, 100000),
+	metric("walking_running_distance", "Walking + Running Distance", Sum, "km", 0, 200),
+	metric("cycling_distance", "Cycling Distance", Sum, "km", 0, 500),
+	metric("swimming_distance", "Swimming Distance", Sum, "m", 0, 20000),
+	metric("flights_climbed", "Flights Climbed", Sum, "count", 0, 500),
+	metric("apple_exercise_time", "Exercise Time", Sum, "min", 0, 1440),
+	metric("apple_stand_time", "Stand Time", Sum, "min", 0, 1440),
+	metric("apple_stand_hour", "Stand Hours", Sum, "count", 0, 24),
+	metric("mindful_minutes", "Mindful Minutes", Sum, "min", 0, 1440),
+	metric("time_in_daylight", "Time in Daylight", Sum, "min", 0, 1440),
+	metric("dietary_water", "Water", Sum, "mL", 0, 20000),
+	metric("dietary_energy", "Dietary Energy", Sum, "kcal", 0, 20000),
+
+	// Vitals, averaged over the period
+	metric("heart_rate", "Heart Rate", Mean, "count/min", 20, 250),
+	metric("resting_heart_rate", "Resting Heart Rate", Mean, "count/min", 20, 200),
+	metric("walking_heart_rate_average", "Walking Heart Rate", Mean, "count/min", 20, 250),
+	metric("heart_rate_variability", "Heart Rate Variability", Mean, "ms", 0, 500),
+	metric("respiratory_rate", "Respiratory Rate", Mean, "count/min", 4, 60),
+	metric("blood_oxygen_saturation", "Blood Oxygen", Mean, "%", 50, 100),
+	metric("body_temperature", "Body Temperature", Mean, "degC", 30, 45),
+	metric("walking_speed", "Walking Speed", Mean, "km/hr", 0, 20),
+	metric("walking_step_length", "Walking Step Length", Mean, "cm", 10, 200),
+	metric("environmental_audio_exposure", "Environmental Sound Levels", Mean, "dBASPL", 0, 140),
+	metric("headphone_audio_exposure", "Headphone Audio Levels", Mean, "dBASPL", 0, 140),
+
+	// Body measurements, the latest one stands
+	metric("weight_body_mass", "Weight", Latest, "kg", 20, 400),
+	metric("lean_body_mass", "Lean Body Mass", Latest, "kg", 10, 300),
+	metric("body_fat_percentage", "Body Fat", Latest, "%", 2, 75),
+	metric("body_mass_index", "Body Mass Index", Latest, "count", 10, 80),
+	metric("height", "Height", Latest, "cm", 50, 250),
+	metric("vo2_max", "VO2 Max", Latest, "ml/(kg·min)", 10, 100),
+
+	// Sleep, added up per night
+	metric("sleep_analysis", "Sleep", Sleep, "hr", 0, 24),
+)
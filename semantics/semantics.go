@@ -0,0 +1,197 @@
+// semantics/semantics.go
+// What metrics measure: how their values combine, their units, names and valid ranges
+
+package semantics
+
+import (
+	"fitness/config"
+	"fitness/models"
+	"fitness/quantity"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Aggregation is how the values of a metric combine over a period such as a week
+type Aggregation string
+
+const (
+	Sum    Aggregation = "sum"    // Values add up, e.g. steps or active energy
+	Mean   Aggregation = "mean"   // Values are averaged, e.g. heart rate
+	Latest Aggregation = "latest" // The most recent value stands, e.g. body weight
+	Sleep  Aggregation = "sleep"  // Values add up per night and nights are averaged, e.g. time asleep
+)
+
+// ParseAggregation reads an aggregation name
+func ParseAggregation(name string) (Aggregation, error) {
+	switch a := Aggregation(strings.ToLower(strings.TrimSpace(name))); a {
+	case Sum, Mean, Latest, Sleep:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown aggregation %q (expected sum, mean, latest or sleep)", name)
+}
+
+// Info describes a metric
+type Info struct {
+	Name        string      // Name of the metric in Health Auto Export files, e.g. resting_heart_rate
+	Display     string      // Human readable name, e.g. Resting Heart Rate
+	Aggregation Aggregation // How values combine over a period
+	Units       string      // Canonical units of the values, e.g. count/min
+	Min         *float64    // Smallest valid value in the canonical units, nil if there is none
+	Max         *float64    // Largest valid value in the canonical units, nil if there is none
+	Known       bool        // Whether the metric is defined, unknown metrics are averaged
+}
+
+// Canonical converts a value recorded in some units to the metric's canonical units
+// Values without units are taken to be in them, values that do not convert are returned as recorded
+func (i Info) Canonical(m models.Measurement) models.Measurement {
+	if i.Units == "" || strings.EqualFold(m.Units, i.Units) {
+		return m
+	}
+	if m.Units == "" {
+		return models.Measurement{Qty: m.Qty, Units: i.Units}
+	}
+	q, err := quantity.New(m.Qty, m.Units)
+	if err != nil {
+		return m
+	}
+	unit, err := quantity.ParseUnit(i.Units)
+	if err != nil {
+		return m
+	}
+	converted, err := q.In(unit)
+	if err != nil {
+		return m
+	}
+	return models.Measurement{Qty: converted.Value, Units: i.Units}
+}
+
+// InSystem converts a value recorded in some units to the canonical units and then to the system's units
+// Durations stay in the canonical units, so sleep is shown in hours rather than seconds
+func (i Info) InSystem(m models.Measurement, system quantity.System) models.Measurement {
+	canonical := i.Canonical(m)
+	if q, err := quantity.New(canonical.Qty, canonical.Units); err == nil && q.Unit.Dimension == quantity.Duration {
+		return canonical
+	}
+	return system.Measurement(canonical)
+}
+
+// Valid reports whether a value recorded in some units lies in the metric's valid range
+// Values that cannot be converted to the canonical units are taken to be valid
+func (i Info) Valid(m models.Measurement) bool {
+	if i.Min == nil && i.Max == nil {
+		return true
+	}
+	canonical := i.Canonical(m)
+	if i.Units != "" && !strings.EqualFold(canonical.Units, i.Units) {
+		return true
+	}
+	return (i.Min == nil || canonical.Qty >= *i.Min) && (i.Max == nil || canonical.Qty <= *i.Max)
+}
+
+// Range formats the valid range, e.g. 20..250 count/min, empty if there is none
+func (i Info) Range() string {
+	if i.Min == nil && i.Max == nil {
+		return ""
+	}
+	bound := func(b *float64) string {
+		if b == nil {
+			return ""
+		}
+		return fmt.Sprintf("%g", *b)
+	}
+	return strings.TrimSpace(bound(i.Min) + ".." + bound(i.Max) + " " + i.Units)
+}
+
+// Registry holds the descriptions of metrics by name
+type Registry struct {
+	metrics map[string]Info
+}
+
+// NewRegistry creates a registry of the metrics
+func NewRegistry(metrics ...Info) *Registry {
+	r := &Registry{metrics: make(map[string]Info)}
+	for _, m := range metrics {
+		m.Known = true
+		r.metrics[strings.ToLower(m.Name)] = m
+	}
+	return r
+}
+
+// Lookup finds a metric by name, ignoring case
+func (r *Registry) Lookup(name string) (Info, bool) {
+	if r == nil {
+		r = Builtin
+	}
+	info, ok := r.metrics[strings.ToLower(name)]
+	return info, ok
+}
+
+// Info describes a metric, unknown metrics are averaged and named after their name
+func (r *Registry) Info(name string) Info {
+	if info, ok := r.Lookup(name); ok {
+		return info
+	}
+	return Info{Name: name, Display: DisplayName(name), Aggregation: Mean}
+}
+
+// Names lists the names of the metrics in alphabetical order
+func (r *Registry) Names() []string {
+	if r == nil {
+		r = Builtin
+	}
+	names := make([]string, 0, len(r.metrics))
+	for _, info := range r.metrics {
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Extend returns a registry with the metrics of the config file added, or merged into the ones it has
+func (r *Registry) Extend(specs map[string]config.MetricSpec) (*Registry, error) {
+	if r == nil {
+		r = Builtin
+	}
+	extended := &Registry{metrics: make(map[string]Info, len(r.metrics)+len(specs))}
+	for key, info := range r.metrics {
+		extended.metrics[key] = info
+	}
+	for name, spec := range specs {
+		info := r.Info(name)
+		info.Known = true
+		if spec.Name != "" {
+			info.Display = spec.Name
+		}
+		if spec.Aggregation != "" {
+			aggregation, err := ParseAggregation(spec.Aggregation)
+			if err != nil {
+				return nil, fmt.Errorf("metric %s: %v", name, err)
+			}
+			info.Aggregation = aggregation
+		}
+		if spec.Units != "" {
+			info.Units = spec.Units
+		}
+		if spec.Min != nil {
+			info.Min = spec.Min
+		}
+		if spec.Max != nil {
+			info.Max = spec.Max
+		}
+		if info.Min != nil && info.Max != nil && *info.Min > *info.Max {
+			return nil, fmt.Errorf("metric %s: min %g is above max %g", name, *info.Min, *info.Max)
+		}
+		extended.metrics[strings.ToLower(name)] = info
+	}
+	return extended, nil
+}
+
+// DisplayName turns a metric name such as resting_heart_rate into Resting Heart Rate
+func DisplayName(name string) string {
+	words := strings.Fields(strings.ReplaceAll(name, "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
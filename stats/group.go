@@ -0,0 +1,348 @@
+// stats/group.go
+// Grouping records by dimensions and summarizing each group with measures
+
+package stats
+
+import (
+	"fitness/fields"
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/utils"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// none is the key of records that have no value for a text dimension
+const none = "(none)"
+
+// periods lists the calendar periods a dimension can bucket records by
+var periods = fields.List{"day", "week", "isoweek", "month", "quarter", "year"}
+
+// Dimension groups records by the keys they fall under
+type Dimension[T any] struct {
+	Name string                           // Name as written, e.g. month or location
+	keys func(T, fields.Context) []string // Keys of a record, none leaves the record out
+	less func(a, b string) bool           // Order of the keys
+}
+
+// Measure is a statistic over a numeric field, or the number of records
+type Measure[T any] struct {
+	Name  string           // Canonical name, e.g. distance:sum or count
+	Field *fields.Field[T] // Field summarized, nil to count records
+	Stat  Stat
+}
+
+// Report is a parsed group-by report over records with the fields of a registry
+type Report[T any] struct {
+	Dimensions []Dimension[T]
+	Measures   []Measure[T]
+}
+
+// ParseReport reads the dimensions and measures of a report, as given to --by and --measure
+func ParseReport[T any](by, measure string, registry *fields.Registry[T], week utils.Bucketer) (Report[T], error) {
+	dimensions, err := ParseDimensions(by, registry, week)
+	if err != nil {
+		return Report[T]{}, err
+	}
+	measures, err := ParseMeasures(measure, registry)
+	if err != nil {
+		return Report[T]{}, err
+	}
+	return Report[T]{Dimensions: dimensions, Measures: measures}, nil
+}
+
+// Group groups the records and summarizes each group, see Group
+func (r Report[T]) Group(records []T, ctx fields.Context) Table {
+	return Group(records, r.Dimensions, r.Measures, ctx)
+}
+
+// ParseDimensions reads comma-separated dimensions: a calendar period (day, week, isoweek, month,
+// quarter, year or a number of days such as 7d) of the records' start, or a text or number field
+// such as name, location, tag, weekday or hour. Weeks follow the week bucketer, whose zone places
+// every period and calendar part.
+func ParseDimensions[T any](spec string, registry *fields.Registry[T], week utils.Bucketer) ([]Dimension[T], error) {
+	var dimensions []Dimension[T]
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		dimension, err := parseDimension(name, registry, week)
+		if err != nil {
+			return nil, err
+		}
+		dimensions = append(dimensions, dimension)
+	}
+	return dimensions, nil
+}
+
+// parseDimension reads a single dimension, periods take precedence over fields of the same name
+func parseDimension[T any](name string, registry *fields.Registry[T], week utils.Bucketer) (Dimension[T], error) {
+	if bucketer, err := utils.ParsePeriod(name); err == nil {
+		start, ok := registry.Lookup("start")
+		if !ok {
+			return Dimension[T]{}, fmt.Errorf("dimension %q needs records with a start time", name)
+		}
+		if bucketer.Period == utils.Week {
+			bucketer = week
+		}
+		bucketer.Zone = week.Zone
+		return Dimension[T]{
+			Name: name,
+			keys: func(record T, ctx fields.Context) []string {
+				bucket, ok := bucketer.Bucket(start.Get(record, ctx).Time)
+				if !ok {
+					return nil
+				}
+				return []string{bucket.Label}
+			},
+			// Labels of periods sort chronologically
+			less: func(a, b string) bool { return a < b },
+		}, nil
+	}
+
+	f, ok := registry.Lookup(name)
+	if !ok {
+		return Dimension[T]{}, fields.Unknown("dimension", name, registry, periods)
+	}
+	dimension := Dimension[T]{Name: name}
+	switch f.Kind {
+	case fields.Text:
+		dimension.keys = func(record T, ctx fields.Context) []string {
+			v := f.Get(record, ctx)
+			switch {
+			case v.Missing:
+				return []string{none}
+			case v.Values != nil:
+				return v.Values
+			}
+			return []string{v.Text}
+		}
+		dimension.less = func(a, b string) bool {
+			if (a == none) != (b == none) {
+				return b == none
+			}
+			return strings.ToLower(a) < strings.ToLower(b)
+		}
+		if f.Name == "weekday" {
+			// Records without a valid time have no weekday and are left out, as from periods
+			dimension.keys = func(record T, ctx fields.Context) []string {
+				if v := f.Get(record, ctx); !v.Missing {
+					return []string{v.Text}
+				}
+				return nil
+			}
+			dimension.less = func(a, b string) bool { return weekdayRank(a, week) < weekdayRank(b, week) }
+		}
+	case fields.Number:
+		dimension.keys = func(record T, ctx fields.Context) []string {
+			v := f.Get(record, ctx)
+			if v.Missing {
+				return nil
+			}
+			return []string{strconv.FormatFloat(v.Measure.Qty, 'f', -1, 64)}
+		}
+		dimension.less = func(a, b string) bool {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			return x < y
+		}
+	default:
+		return Dimension[T]{}, fmt.Errorf("cannot group by %s, use a period such as month for times and text or whole number fields otherwise", name)
+	}
+	return dimension, nil
+}
+
+// weekdayRank orders weekday names from the first day of the week
+func weekdayRank(name string, week utils.Bucketer) int {
+	start := week.WeekStart
+	if week.Period == utils.ISOWeek {
+		start = time.Monday
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return (int(day) - int(start) + 7) % 7
+		}
+	}
+	return 7
+}
+
+// ParseMeasures reads comma-separated measures such as count, distance:sum or duration:p90
+// A field without a statistic is rolled up the way its values add up if it knows how, and summed otherwise
+func ParseMeasures[T any](spec string, registry *fields.Registry[T]) ([]Measure[T], error) {
+	var measures []Measure[T]
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		if part == string(Count) {
+			measures = append(measures, Measure[T]{Name: part, Stat: Count})
+			continue
+		}
+
+		name, statName, found := strings.Cut(part, ":")
+		f, ok := registry.Lookup(name)
+		if !ok {
+			return nil, fields.Unknown("measure field", name, registry)
+		}
+		if f.Kind != fields.Number && f.Kind != fields.Quantity {
+			return nil, fmt.Errorf("cannot measure %s, only number fields can be summarized", name)
+		}
+		if !found {
+			statName = string(Sum)
+			if f.Rollup != nil {
+				statName = string(Auto)
+			}
+		}
+		stat, err := ParseStat(statName)
+		if err != nil {
+			return nil, err
+		}
+		if stat == Auto && f.Rollup == nil {
+			return nil, fmt.Errorf("%s has no natural way to add up, give a statistic such as %s:sum", name, name)
+		}
+		measures = append(measures, Measure[T]{Name: f.Name + ":" + string(stat), Field: f, Stat: stat})
+	}
+	if len(measures) == 0 {
+		return nil, fmt.Errorf("no measures given (expected e.g. count or distance:sum)")
+	}
+	return measures, nil
+}
+
+// Table is the result of grouping, with a row per combination of keys and a column per measure
+type Table struct {
+	Dimensions []string // Names of the dimensions
+	Measures   []string // Names of the measures
+	Rows       []Row    // Rows in the order of their keys
+	formats    []func(float64) string
+}
+
+// Row is a group of records and its measures
+type Row struct {
+	Keys   []string  // Key of the group in each dimension
+	Values []float64 // Value of each measure
+	Valid  []bool    // Whether each measure has a value, statistics other than count and sum of no values have none
+}
+
+// Format formats the value of a measure of a row in the display units, "-" if it has no value
+func (t Table) Format(row Row, measure int) string {
+	if !row.Valid[measure] {
+		return "-"
+	}
+	return t.formats[measure](row.Values[measure])
+}
+
+// group collects the records and the values of the measures for one combination of keys
+type group[T any] struct {
+	keys    []string
+	records []T
+	values  [][]float64
+}
+
+// Group splits the records by every combination of their keys and summarizes each group
+// Records fall in one group per key of a dimension with several, such as tags, and a record
+// without a key in some dimension is left out
+func Group[T any](records []T, dimensions []Dimension[T], measures []Measure[T], ctx fields.Context) Table {
+	table := Table{}
+	for _, d := range dimensions {
+		table.Dimensions = append(table.Dimensions, d.Name)
+	}
+	for _, m := range measures {
+		table.Measures = append(table.Measures, m.Name)
+		table.formats = append(table.formats, m.formatter(ctx))
+	}
+
+	groups := make(map[string]*group[T])
+	for _, record := range records {
+		combinations := [][]string{nil}
+		for _, d := range dimensions {
+			var next [][]string
+			for _, key := range d.keys(record, ctx) {
+				for _, combination := range combinations {
+					next = append(next, append(append([]string(nil), combination...), key))
+				}
+			}
+			combinations = next
+		}
+
+		for _, keys := range combinations {
+			id := strings.Join(keys, "\x00")
+			g, ok := groups[id]
+			if !ok {
+				g = &group[T]{keys: keys, values: make([][]float64, len(measures))}
+				groups[id] = g
+			}
+			g.records = append(g.records, record)
+			for i, m := range measures {
+				if n, ok := m.value(record, ctx); ok {
+					g.values[i] = append(g.values[i], n)
+				}
+			}
+		}
+	}
+
+	for _, g := range groups {
+		row := Row{Keys: g.keys}
+		for i, m := range measures {
+			value, ok := m.Stat.Compute(g.values[i])
+			if m.Stat == Auto {
+				value, ok = m.Field.Number(m.Field.Rollup(g.records, ctx), ctx)
+			}
+			row.Values = append(row.Values, value)
+			row.Valid = append(row.Valid, ok)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	sort.Slice(table.Rows, func(i, j int) bool {
+		for k, d := range dimensions {
+			a, b := table.Rows[i].Keys[k], table.Rows[j].Keys[k]
+			if a != b {
+				return d.less(a, b)
+			}
+		}
+		return false
+	})
+	return table
+}
+
+// value reads the number a measure summarizes from a record, in the display units
+// Counting records takes every record, quantities that do not convert are left out
+func (m Measure[T]) value(record T, ctx fields.Context) (float64, bool) {
+	if m.Field == nil {
+		return 0, true
+	}
+	v := m.Field.Get(record, ctx)
+	if v.Missing || v.Measure == nil {
+		return 0, false
+	}
+	if m.Field.Kind == fields.Quantity && m.Field.Dimension != "" {
+		q, err := quantity.FromMeasurement(v.Measure)
+		if err == nil {
+			q, err = q.In(m.Field.DisplayUnit(ctx.Units))
+		}
+		return q.Value, err == nil
+	}
+	return m.Field.Number(v, ctx)
+}
+
+// formatter formats the measure's values like the field they summarize
+func (m Measure[T]) formatter(ctx fields.Context) func(float64) string {
+	if m.Field == nil || m.Stat == Count {
+		return func(n float64) string { return strconv.Itoa(int(n)) }
+	}
+	f := m.Field
+	if f.Kind == fields.Number {
+		return func(n float64) string { return strconv.FormatFloat(n, 'f', max(f.Precision, 2), 64) }
+	}
+	units := ""
+	if f.Dimension != "" {
+		units = f.DisplayUnit(ctx.Units).Symbol
+	}
+	return func(n float64) string {
+		return f.String(fields.MeasureValue(&models.Measurement{Qty: n, Units: units}), ctx)
+	}
+}
@@ -0,0 +1,41 @@
+// stats/preset.go
+// Reports that have a flag of their own
+
+package stats
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+)
+
+// Preset is a group-by report with a title, such as the totals of -distance-per-week
+type Preset struct {
+	Title     string             // Heading of the report
+	By        string             // Dimensions, as given to -by
+	Measure   string             // Measures, as given to -measure
+	Dimension quantity.Dimension // Dimension of the metrics a report of data points covers, every metric if empty
+}
+
+var (
+	WorkoutsPerMonth   = Preset{Title: "Workouts Per Month", By: "month", Measure: "count"}
+	DistancePerWorkout = Preset{Title: "Distance Per Workout", By: "name", Measure: "distance:sum"}
+	DistancePerWeek    = Preset{Title: "Distance Per Week", By: "week", Measure: "distance:sum"}
+	EnergyPerWeek      = Preset{Title: "Energy Burned Per Week", By: "week", Measure: "energy:sum"}
+)
+
+// The same flags with -type metrics report on the selected data points, each metric combined the way it adds up
+var (
+	PointsPerMonth        = Preset{Title: "Data Points Per Month", By: "name,month", Measure: "count"}
+	DistancePerMetric     = Preset{Title: "Distance Per Metric", By: "name", Measure: "value", Dimension: quantity.Distance}
+	MetricDistancePerWeek = Preset{Title: "Distance Per Week", By: "name,week", Measure: "value", Dimension: quantity.Distance}
+	MetricEnergyPerWeek   = Preset{Title: "Energy Per Week", By: "name,week", Measure: "value", Dimension: quantity.Energy}
+)
+
+// Covers reports whether a report of data points includes a metric, by the dimension of its units
+func (p Preset) Covers(m models.Metric) bool {
+	if p.Dimension == "" {
+		return true
+	}
+	unit, err := quantity.ParseUnit(m.Units)
+	return err == nil && unit.Dimension == p.Dimension
+}
@@ -0,0 +1,125 @@
+// stats/stat.go
+// Statistics that summarize the values of a group
+
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Stat is a statistic over the values of a group: count, sum, mean, min, max, median,
+// stddev or a percentile such as p90
+type Stat string
+
+const (
+	Count  Stat = "count"  // Number of values
+	Sum    Stat = "sum"    // Total of the values
+	Mean   Stat = "mean"   // Average of the values
+	Min    Stat = "min"    // Smallest value
+	Max    Stat = "max"    // Largest value
+	Median Stat = "median" // Middle value, the 50th percentile
+	StdDev Stat = "stddev" // Population standard deviation
+	Auto   Stat = "auto"   // The way the field's values add up, such as each metric's own aggregation
+)
+
+// statAliases maps alternative spellings to statistics
+var statAliases = map[string]Stat{
+	"avg":     Mean,
+	"average": Mean,
+	"total":   Sum,
+	"std":     StdDev,
+}
+
+// ParseStat reads a statistic name, percentiles are written p1 to p99
+func ParseStat(name string) (Stat, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := statAliases[name]; ok {
+		return alias, nil
+	}
+	switch stat := Stat(name); stat {
+	case Count, Sum, Mean, Min, Max, Median, StdDev, Auto:
+		return stat, nil
+	}
+	if _, ok := percentile(Stat(name)); ok {
+		return Stat(name), nil
+	}
+	return "", fmt.Errorf("unknown statistic %q (expected count, sum, mean, min, max, median, stddev, auto or a percentile such as p90)", name)
+}
+
+// percentile returns the percentile of a pNN statistic
+func percentile(s Stat) (float64, bool) {
+	digits, ok := strings.CutPrefix(string(s), "p")
+	if !ok {
+		return 0, false
+	}
+	p, err := strconv.Atoi(digits)
+	if err != nil || p < 1 || p > 99 {
+		return 0, false
+	}
+	return float64(p), true
+}
+
+// Compute summarizes the values, false if a statistic other than count or sum has no values
+// Auto depends on the records rather than their values and has none here
+func (s Stat) Compute(values []float64) (float64, bool) {
+	switch s {
+	case Auto:
+		return 0, false
+	case Count:
+		return float64(len(values)), true
+	case Sum:
+		total := 0.0
+		for _, v := range values {
+			total += v
+		}
+		return total, true
+	}
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	switch s {
+	case Mean:
+		total, _ := Sum.Compute(values)
+		return total / float64(len(values)), true
+	case Min:
+		low := values[0]
+		for _, v := range values[1:] {
+			low = math.Min(low, v)
+		}
+		return low, true
+	case Max:
+		high := values[0]
+		for _, v := range values[1:] {
+			high = math.Max(high, v)
+		}
+		return high, true
+	case Median:
+		return quantile(values, 0.5), true
+	case StdDev:
+		mean, _ := Mean.Compute(values)
+		squares := 0.0
+		for _, v := range values {
+			squares += (v - mean) * (v - mean)
+		}
+		return math.Sqrt(squares / float64(len(values))), true
+	}
+	p, _ := percentile(s)
+	return quantile(values, p/100), true
+}
+
+// quantile interpolates linearly between the two values closest to the quantile
+func quantile(values []float64, q float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	position := q * float64(len(sorted)-1)
+	below := int(math.Floor(position))
+	if below+1 >= len(sorted) {
+		return sorted[below]
+	}
+	return sorted[below] + (position-float64(below))*(sorted[below+1]-sorted[below])
+}
@@ -0,0 +1,139 @@
+// test/apple_test.go
+
+package test
+
+import (
+	"fitness/config"
+	"fitness/data"
+	"fitness/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAppleXML(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<HealthData locale="en_US">
+ <Record type="HKQuantityTypeIdentifierStepCount" unit="count" startDate="2024-01-05 08:00:00 -0500" endDate="2024-01-05 08:10:00 -0500" value="1200"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" unit="count" startDate="2024-01-05 12:00:00 -0500" endDate="2024-01-05 12:10:00 -0500" value="800"/>
+ <Record type="HKQuantityTypeIdentifierHeartRate" unit="count/min" startDate="2024-01-05 08:00:00 -0500" endDate="2024-01-05 08:00:00 -0500" value="60">
+  <MetadataEntry key="HKMetadataKeyHeartRateMotionContext" value="1"/>
+ </Record>
+ <Record type="HKQuantityTypeIdentifierHeartRate" unit="count/min" startDate="2024-01-05 09:00:00 -0500" endDate="2024-01-05 09:00:00 -0500" value="80"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" startDate="2024-01-05 01:00:00 -0500" endDate="2024-01-05 07:00:00 -0500" value="HKCategoryValueSleepAnalysisAsleepCore"/>
+ <Workout workoutActivityType="HKWorkoutActivityTypeRunning" duration="30.5" durationUnit="min" startDate="2024-01-05 07:00:00 -0500" endDate="2024-01-05 07:30:30 -0500">
+  <MetadataEntry key="HKIndoorWorkout" value="1"/>
+  <WorkoutStatistics type="HKQuantityTypeIdentifierActiveEnergyBurned" sum="320" unit="Cal"/>
+  <WorkoutStatistics type="HKQuantityTypeIdentifierDistanceWalkingRunning" sum="3.2" unit="mi"/>
+ </Workout>
+</HealthData>`
+
+	export, err := data.ParseAppleXML(strings.NewReader(input))
+	assert.NoError(t, err)
+	health := export.Health
+	assert.Empty(t, export.Warnings)
+
+	// Test 1: Workouts are converted with Health Auto Export names and units
+	workouts := health.Data.Workouts
+	assert.Len(t, workouts, 1)
+	assert.Equal(t, "Indoor Run", workouts[0].Name)
+	assert.Equal(t, 1830.0, workouts[0].Duration)
+	assert.Equal(t, "kcal", workouts[0].ActiveEnergyBurned.Units)
+	assert.Equal(t, 3.2, workouts[0].Distance.Qty)
+
+	// Test 2: Records are rolled up per day, summing steps and averaging heart rate
+	metrics := health.Data.Metrics
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, "heart_rate", metrics[0].Name)
+	assert.Equal(t, 70.0, metrics[0].Data[0].Qty)
+	assert.Equal(t, "step_count", metrics[1].Name)
+	assert.Equal(t, 2000.0, metrics[1].Data[0].Qty)
+	assert.Equal(t, "2024-01-05 00:00:00 -0500", metrics[1].Data[0].Date.String())
+}
+
+func TestAppleDailyTotals(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<HealthData locale="en_US">
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="Jo's iPhone" unit="count" startDate="2024-01-05 08:00:00 -0500" value="1300"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="Jo's Apple Watch" unit="count" startDate="2024-01-05 08:00:00 -0500" value="1200"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="Jo's Apple Watch" unit="count" startDate="2024-01-05 12:00:00 -0500" value="800"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="Jo's iPhone" unit="count" startDate="2024-01-06 08:00:00 -0500" value="500"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="Jo's iPhone" unit="count" startDate="last tuesday" value="500"/>
+ <Record type="HKQuantityTypeIdentifierDistanceWalkingRunning" sourceName="Jo's Apple Watch" unit="km" startDate="2024-01-05 08:00:00 -0500" value="1.5"/>
+ <Record type="HKQuantityTypeIdentifierDistanceWalkingRunning" sourceName="Jo's Apple Watch" unit="mi" startDate="2024-01-05 12:00:00 -0500" value="1"/>
+ <Record type="HKQuantityTypeIdentifierDistanceWalkingRunning" sourceName="Jo's Apple Watch" unit="kcal" startDate="2024-01-05 13:00:00 -0500" value="1"/>
+</HealthData>`
+
+	export, err := data.ParseAppleXML(strings.NewReader(input))
+	assert.NoError(t, err)
+	metrics := export.Health.Data.Metrics
+	assert.Len(t, metrics, 2)
+
+	// Test 1: Steps the Watch and the iPhone both counted are taken from the Watch, days with one source keep it
+	steps := metrics[0]
+	assert.Equal(t, "step_count", steps.Name)
+	assert.Len(t, steps.Data, 2)
+	assert.Equal(t, 2000.0, steps.Data[0].Qty)
+	assert.Equal(t, 500.0, steps.Data[1].Qty)
+
+	// Test 2: Values are converted to the units of the metric's first record before they are added up
+	distance := metrics[1]
+	assert.Equal(t, "km", distance.Units)
+	assert.InDelta(t, 3.109, distance.Data[0].Qty, 1e-3)
+
+	// Test 3: Records without a readable date or in other units are left out and reported
+	assert.Equal(t, []string{
+		"1 step_count records left out: unreadable start date",
+		"1 walking_running_distance records left out: units kcal that do not convert to km",
+	}, export.Warnings)
+}
+
+func TestAppleAndAutoExportSession(t *testing.T) {
+	autoExport := `{"data":{"workouts":[{"id":"7C1E","name":"Outdoor Run","start":"2024-01-05 12:00:00 +0000","duration":1800}],"metrics":[]}}`
+	appleExport := `<?xml version="1.0" encoding="UTF-8"?>
+<HealthData locale="en_US">
+ <Workout workoutActivityType="HKWorkoutActivityTypeRunning" duration="31" durationUnit="min" startDate="2024-01-05 07:00:00 -0500" endDate="2024-01-05 07:31:00 -0500"/>
+ <Workout workoutActivityType="HKWorkoutActivityTypeWalking" duration="20" durationUnit="min" startDate="2024-01-05 18:00:00 -0500" endDate="2024-01-05 18:20:00 -0500"/>
+</HealthData>`
+	importBoth := func(appleFirst bool) []models.Workout {
+		dir := t.TempDir()
+		cfg := config.Default()
+		cfg.ICloudDirPath = filepath.Join(dir, "icloud")
+		cfg.CacheFilePath = filepath.Join(dir, "cache.json")
+		exportPath := filepath.Join(dir, "export.xml")
+		assert.NoError(t, os.Mkdir(cfg.ICloudDirPath, 0755))
+		assert.NoError(t, os.WriteFile(exportPath, []byte(appleExport), 0644))
+		var store data.Store
+		captureStdout(t, func() {
+			if appleFirst {
+				first, err := data.OpenStore(cfg)
+				assert.NoError(t, err)
+				assert.NoError(t, data.ImportAppleExport(first, exportPath))
+				assert.NoError(t, first.Save())
+			}
+			assert.NoError(t, os.WriteFile(filepath.Join(cfg.ICloudDirPath, "export.json"), []byte(autoExport), 0644))
+			var err error
+			store, err = data.ImportData(cfg)
+			assert.NoError(t, err)
+			if !appleFirst {
+				assert.NoError(t, data.ImportAppleExport(store, exportPath))
+			}
+		})
+		return store.Workouts(data.AllTime)
+	}
+
+	// Test 1: A run imported from Health Auto Export and then from Apple Health is one workout, keeping its ID
+	workouts := importBoth(false)
+	assert.Len(t, workouts, 2)
+	assert.Equal(t, "7C1E", workouts[0].ID)
+	assert.Equal(t, 1860.0, workouts[0].Duration)
+
+	// Test 2: The same holds the other way around
+	workouts = importBoth(true)
+	assert.Len(t, workouts, 2)
+	assert.Equal(t, "7C1E", workouts[0].ID)
+	assert.Equal(t, 1800.0, workouts[0].Duration)
+}
@@ -0,0 +1,65 @@
+// test/bucket_test.go
+
+package test
+
+import (
+	"fitness/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bucketLabel returns the label of the bucket a timestamp falls in
+func bucketLabel(t *testing.T, b utils.Bucketer, value string) string {
+	bucket, ok := b.Bucket(ts(value))
+	assert.True(t, ok)
+	return bucket.Label
+}
+
+func TestBucketer(t *testing.T) {
+	monday, err := utils.ParseWeekStart("monday")
+	assert.NoError(t, err)
+	sunday, err := utils.ParseWeekStart("sunday")
+	assert.NoError(t, err)
+	iso, err := utils.ParseWeekStart("iso")
+	assert.NoError(t, err)
+
+	// Test 1: A Sunday belongs to the week that started on the Monday before it
+	assert.Equal(t, "2024-01-01..2024-01-07", bucketLabel(t, monday, "2024-01-07 10:00:00 +0000"))
+	assert.Equal(t, "2024-01-08..2024-01-14", bucketLabel(t, monday, "2024-01-08 10:00:00 +0000"))
+
+	// Test 2: Weeks can start on another day
+	assert.Equal(t, "2024-01-07..2024-01-13", bucketLabel(t, sunday, "2024-01-07 10:00:00 +0000"))
+
+	// Test 3: ISO weeks are labelled with the ISO year
+	assert.Equal(t, "2020-W53", bucketLabel(t, iso, "2021-01-03 10:00:00 +0000"))
+	assert.Equal(t, "2024-W01", bucketLabel(t, iso, "2024-01-07 10:00:00 +0000"))
+
+	// Test 4: Calendar periods
+	for spec, label := range map[string]string{
+		"day":     "2024-08-15",
+		"month":   "2024-08",
+		"quarter": "2024-Q3",
+		"year":    "2024",
+	} {
+		b, err := utils.ParsePeriod(spec)
+		assert.NoError(t, err)
+		assert.Equal(t, label, bucketLabel(t, b, "2024-08-15 10:00:00 +0000"), spec)
+	}
+
+	// Test 5: Rolling windows count back from the anchor day
+	rolling, err := utils.ParsePeriod("7d")
+	assert.NoError(t, err)
+	rolling.Anchor = time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2024-01-08..2024-01-14", bucketLabel(t, rolling, "2024-01-14 23:00:00 +0000"))
+	assert.Equal(t, "2024-01-08..2024-01-14", bucketLabel(t, rolling, "2024-01-08 00:00:00 +0000"))
+	assert.Equal(t, "2024-01-01..2024-01-07", bucketLabel(t, rolling, "2024-01-07 12:00:00 +0000"))
+	assert.Equal(t, "2024-01-15..2024-01-21", bucketLabel(t, rolling, "2024-01-15 12:00:00 +0000"))
+
+	// Test 6: Unknown periods and invalid timestamps are rejected
+	_, err = utils.ParsePeriod("fortnight")
+	assert.Error(t, err)
+	_, ok := monday.Bucket(ts("not a time"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,86 @@
+// test/cache_test.go
+
+package test
+
+import (
+	"fitness/data"
+	"fitness/models"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheMigration(t *testing.T) {
+	// Test 1: A cache without a schema version is upgraded to the current version
+	legacy := `{"data": {"workouts": [{"id": "a", "name": "Outdoor Run", "start": "2024-01-01 07:00:00 -0500"}], "metrics": []}, "lastUpdated": "2024-01-01"}`
+	cache, migrated, err := data.DecodeCache([]byte(legacy))
+	assert.NoError(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, data.CacheSchemaVersion, cache.SchemaVersion)
+	assert.Len(t, cache.Data.Workouts, 1)
+
+	// Test 2: A cache from a newer version of the program is refused
+	_, _, err = data.DecodeCache([]byte(`{"schemaVersion": 999, "data": {}}`))
+	assert.Error(t, err)
+
+	// Test 3: A version 1 cache moves the workout counts of route files to their track counts
+	v1 := `{"schemaVersion": 1, "data": {"workouts": [], "metrics": []}, "manifest": {
+		"/exports/run.gpx": {"path": "/exports/run.gpx", "workouts": 2, "status": "ingested"},
+		"/exports/export.json": {"path": "/exports/export.json", "workouts": 5, "metrics": 9, "status": "ingested"}}}`
+	cache, migrated, err = data.DecodeCache([]byte(v1))
+	assert.NoError(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, 0, cache.Manifest["/exports/run.gpx"].Workouts)
+	assert.Equal(t, 2, cache.Manifest["/exports/run.gpx"].Tracks)
+	assert.Equal(t, 5, cache.Manifest["/exports/export.json"].Workouts)
+	assert.Equal(t, 0, cache.Manifest["/exports/export.json"].Tracks)
+
+	// Test 4: A current cache is read as it is
+	_, migrated, err = data.DecodeCache([]byte(`{"schemaVersion": 2, "data": {}}`))
+	assert.NoError(t, err)
+	assert.False(t, migrated)
+}
+
+func TestCacheRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	workouts := []models.Workout{{ID: "a", Name: "Outdoor Run", Start: ts("2024-01-01 07:00:00 -0500")}}
+
+	// Write the cache twice so the first version is kept as a backup
+	assert.NoError(t, data.WriteToCache(path, workouts, nil, nil, nil))
+	assert.NoError(t, data.WriteToCache(path, workouts, nil, nil, nil))
+	_, err := os.Stat(data.BackupPath(path, 1))
+	assert.NoError(t, err, "Expected the previous cache to be backed up.")
+
+	// Test 1: A corrupted cache is recovered from the backup
+	assert.NoError(t, os.WriteFile(path, []byte(`{"data": {"workouts": [`), 0644))
+	store := data.NewFileStore(path, data.MergeNewest)
+	assert.NoError(t, store.Load())
+	assert.Len(t, store.Workouts(data.AllTime), 1)
+	assert.True(t, store.Changed(), "Expected a recovered cache to be rewritten.")
+
+	// Test 2: Without a readable backup the corruption is reported as an error
+	assert.NoError(t, os.Remove(data.BackupPath(path, 1)))
+	assert.Error(t, data.NewFileStore(path, data.MergeNewest).Load())
+}
+
+func TestCacheBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	workouts := []models.Workout{{ID: "a", Name: "Outdoor Run", Start: ts("2024-01-01 07:00:00 -0500")}}
+	assert.NoError(t, data.WriteToCache(path, workouts, nil, nil, nil))
+	assert.NoError(t, data.WriteToCache(path, workouts, nil, nil, nil))
+	good, err := os.ReadFile(data.BackupPath(path, 1))
+	assert.NoError(t, err)
+
+	// Test 1: A cache that is valid JSON but fails the schema check is not rotated into the backups
+	for _, bad := range []string{`{"schemaVersion": 999, "data": {}}`, `{"data": {"workouts": 5}}`} {
+		assert.NoError(t, os.WriteFile(path, []byte(bad), 0644))
+		assert.NoError(t, data.WriteToCache(path, workouts, nil, nil, nil))
+		backup, err := os.ReadFile(data.BackupPath(path, 1))
+		assert.NoError(t, err)
+		assert.Equal(t, string(good), string(backup))
+		_, err = os.Stat(data.BackupPath(path, 2))
+		assert.True(t, os.IsNotExist(err), bad)
+	}
+}
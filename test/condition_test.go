@@ -0,0 +1,103 @@
+// test/condition_test.go
+
+package test
+
+import (
+	"fitness/data"
+	"fitness/models"
+	"fitness/quantity"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// matches reports whether a workout satisfies a condition, failing the test if it does not parse
+func matches(t *testing.T, spec string, w models.Workout) bool {
+	c, err := data.ParseCondition(spec, quantity.Metric, utc)
+	assert.NoError(t, err, spec)
+	return c.Match(w)
+}
+
+// utc places conditions on UTC calendar days
+var utc = models.FixedZone(time.UTC)
+
+func TestConditions(t *testing.T) {
+	home := "Home"
+	run := models.Workout{
+		Name:        "Outdoor Run",
+		Start:       ts("2024-03-10T07:00:00Z"),
+		Duration:    2700,
+		Distance:    &models.Measurement{Qty: 3.5, Units: "mi"},
+		Temperature: &models.Measurement{Qty: 68, Units: "degF"},
+		Humidity:    &models.Measurement{Qty: 80, Units: "%"},
+		Location:    &home,
+	}
+
+	// Test 1: Ordering operators compare across units, bare numbers are in the display units
+	assert.True(t, matches(t, "distance>5km", run))
+	assert.True(t, matches(t, "distance<6", run))
+	assert.False(t, matches(t, "distance>=4mi", run))
+	assert.True(t, matches(t, "temperature>19degC", run))
+
+	// Test 2: Durations accept Go durations, unit symbols and seconds
+	assert.True(t, matches(t, "duration>=45m", run))
+	assert.False(t, matches(t, "duration>45m", run))
+	assert.True(t, matches(t, "duration<1h", run))
+	assert.True(t, matches(t, "duration=2700", run))
+
+	// Test 3: Ranges are inclusive, a bare lower end takes the upper end's unit
+	assert.True(t, matches(t, "distance=5..6km", run))
+	assert.True(t, matches(t, "duration=30m..45m", run))
+	assert.True(t, matches(t, "distance!=1..3mi", run))
+	assert.False(t, matches(t, "distance!=3..4mi", run))
+
+	// Test 4: Empty ranges are rejected, also when the lower end took the unit of the upper end
+	for spec, reason := range map[string]string{
+		"distance=6..5":     "6 is above 5",
+		"distance=5km..3mi": "5km is above 3mi",
+		"duration=30..1h":   "30h is above 1h (a lower end without a unit",
+	} {
+		_, err := data.ParseCondition(spec, quantity.Metric, utc)
+		assert.ErrorContains(t, err, reason, spec)
+	}
+
+	// Test 5: Equality matches values that round to the literal
+	assert.True(t, matches(t, "distance=5.6", run))
+	assert.False(t, matches(t, "distance=5.7", run))
+
+	// Test 6: Text fields compare case-insensitively, ~ matches substrings
+	assert.True(t, matches(t, "location=home", run))
+	assert.True(t, matches(t, "name~run", run))
+	assert.False(t, matches(t, "name=run", run))
+	assert.True(t, matches(t, "name!=Pool Swim", run))
+
+	// Test 7: Dates compare by calendar day, fields compared as recorded accept a percent sign
+	assert.True(t, matches(t, "date=2024-03-10", run))
+	assert.True(t, matches(t, "start<2024-03-11", run))
+	assert.True(t, matches(t, "humidity>=80%", run))
+
+	// Test 8: Workouts without a value never match, not even !=
+	assert.False(t, matches(t, "energy<100", run))
+	assert.False(t, matches(t, "intensity!=5", run))
+
+	// Test 9: Invalid conditions are rejected
+	for _, spec := range []string{"vo2max>3", "distance>5kcal", "distance>3..6", "name>a", "distance", "intensity>5km"} {
+		_, err := data.ParseCondition(spec, quantity.Metric, utc)
+		assert.Error(t, err, spec)
+	}
+
+	// Test 10: The -f/-value shorthand matches substrings of text and equals numbers unless given an operator
+	c, err := data.ShorthandCondition("name", "outdoor", quantity.Metric, utc)
+	assert.NoError(t, err)
+	assert.True(t, c.Match(run))
+	c, err = data.ShorthandCondition("distance", ">5", quantity.Metric, utc)
+	assert.NoError(t, err)
+	assert.True(t, c.Match(run))
+
+	// Test 11: All conditions must match
+	first, _ := data.ParseCondition("distance>5km", quantity.Metric, utc)
+	second, _ := data.ParseCondition("duration<30m", quantity.Metric, utc)
+	assert.True(t, data.MatchAll([]data.Condition{first}, run))
+	assert.False(t, data.MatchAll([]data.Condition{first, second}, run))
+}
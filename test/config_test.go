@@ -0,0 +1,61 @@
+// test/config_test.go
+
+package test
+
+import (
+	"fitness/config"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	// Write a config file that sets units, sort and the cache path
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"units": "metric", "sort": "date", "cacheFile": "/tmp/fitness-cache.json"}`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	// Environment overrides the file, flags override the environment
+	t.Setenv("FITNESS_SORT", "name")
+	t.Setenv("FITNESS_TYPE", "metrics")
+
+	cfg, err := config.Load(path)
+	assert.NoError(t, err)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("type", "workouts", "")
+	assert.NoError(t, fs.Parse([]string{"-type", "workouts"}))
+	assert.NoError(t, cfg.ApplyFlags(fs))
+
+	// Test 1: Values come from the highest precedence source
+	assert.Equal(t, "metric", cfg.Units)
+	assert.Equal(t, "name", cfg.SortBy)
+	assert.Equal(t, "workouts", cfg.DataType)
+	assert.Equal(t, "/tmp/fitness-cache.json", cfg.CacheFilePath)
+
+	// Test 2: Each setting records where it came from
+	assert.Equal(t, config.SourceFile, cfg.Sources["units"])
+	assert.Equal(t, config.SourceEnv, cfg.Sources["sort"])
+	assert.Equal(t, config.SourceFlag, cfg.Sources["type"])
+	assert.Equal(t, config.SourceDefault, cfg.Sources["timeFormat"])
+}
+
+func TestConfigValidation(t *testing.T) {
+	// Test 1: A missing config file leaves the defaults in place
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+
+	// Test 2: Unsupported values are rejected
+	assert.NoError(t, cfg.Set("units", "furlongs", config.SourceFlag))
+	assert.Error(t, cfg.Validate())
+
+	// Test 3: Unknown keys in the config file are reported
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"colour": "blue"}`), 0644))
+	_, err = config.Load(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+// test/csv_test.go
+
+package test
+
+import (
+	"fitness/data"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWorkoutsCSV(t *testing.T) {
+	input := `Workout Type,Start,End,Duration,Active Energy (kcal),Distance (km),Location,Tags
+Outdoor Run,2024-01-02 08:00:00 -0500,2024-01-02 08:45:00 -0500,00:45:00,410.5,8.2,Outdoor,"commute; easy,"
+Pool Swim,2024-01-03 07:00:00 -0500,2024-01-03 07:30:00 -0500,00:30:00,,,,
+`
+	health, err := data.ParseCSV(strings.NewReader(input))
+	assert.NoError(t, err)
+	workouts := health.Data.Workouts
+
+	// Test 1: Each row becomes a workout with units taken from the header
+	assert.Len(t, workouts, 2)
+	assert.Equal(t, "Outdoor Run", workouts[0].Name)
+	assert.Equal(t, "2024-01-02 08:00:00 -0500", workouts[0].Start.String())
+	assert.Equal(t, 2700.0, workouts[0].Duration)
+	assert.Equal(t, "km", workouts[0].Distance.Units)
+	assert.Equal(t, 8.2, workouts[0].Distance.Qty)
+	assert.Equal(t, "kcal", workouts[0].ActiveEnergyBurned.Units)
+
+	// Test 2: Empty cells leave optional fields unset
+	assert.Nil(t, workouts[1].Distance)
+	assert.Nil(t, workouts[1].Location)
+
+	// Test 3: Tags are split on commas and semicolons
+	assert.Equal(t, []string{"commute", "easy"}, workouts[0].Tags)
+	assert.Empty(t, workouts[1].Tags)
+}
+
+func TestParseMetricsCSV(t *testing.T) {
+	input := `Date/Time,Step Count (count),Heart Rate [Min] (count/min),Heart Rate [Avg] (count/min),Weight & Body Mass (lb)
+2024-01-02 00:00:00 -0500,10234,52,78.5,
+2024-01-03 00:00:00 -0500,8000,55,75,180.2
+`
+	health, err := data.ParseCSV(strings.NewReader(input))
+	assert.NoError(t, err)
+	metrics := health.Data.Metrics
+
+	// Test 1: Each column becomes a metric named like the JSON export
+	assert.Len(t, metrics, 3)
+	assert.Equal(t, "step_count", metrics[0].Name)
+	assert.Equal(t, "count", metrics[0].Units)
+	assert.Len(t, metrics[0].Data, 2)
+
+	// Test 2: Split heart rate columns become one point with the average as its value
+	assert.Equal(t, "heart_rate", metrics[1].Name)
+	assert.Len(t, metrics[1].Data, 2)
+	assert.Equal(t, 78.5, metrics[1].Data[0].Qty)
+	assert.Equal(t, 52.0, *metrics[1].Data[0].Min)
+	assert.Equal(t, 78.5, *metrics[1].Data[0].Avg)
+	assert.Nil(t, metrics[1].Data[0].Max)
+
+	// Test 3: Empty cells are skipped
+	assert.Equal(t, "weight_body_mass", metrics[2].Name)
+	assert.Len(t, metrics[2].Data, 1)
+
+	// Test 4: Text and time columns are not read as metrics, they describe the points of their row
+	health, err = data.ParseCSV(strings.NewReader(`Date/Time,Sleep Analysis (hr),Sleep Start,Sleep End,Source
+2024-01-02 00:00:00 -0500,7.5,2024-01-01 23:10:00 -0500,2024-01-02 06:55:00 -0500,Apple Watch
+`))
+	assert.NoError(t, err)
+	assert.Len(t, health.Data.Metrics, 1)
+	assert.Equal(t, "sleep_analysis", health.Data.Metrics[0].Name)
+	point := health.Data.Metrics[0].Data[0]
+	assert.Equal(t, "Apple Watch", point.Source)
+	assert.Equal(t, "2024-01-01 23:10:00 -0500", point.SleepStart.String())
+	assert.Equal(t, "2024-01-02 06:55:00 -0500", point.SleepEnd.String())
+
+	// Test 5: Unknown layouts are rejected
+	_, err = data.ParseCSV(strings.NewReader("foo,bar\n1,2\n"))
+	assert.Error(t, err)
+
+	// Test 6: Sleep stages share a point valued by the time asleep, unknown stages are kept as extra fields
+	sleep := `Date/Time,Sleep Analysis [Asleep] (hr),Sleep Analysis [In Bed] (hr),Sleep Analysis [Deep] (hr),Sleep Analysis [Awake] (hr)
+2024-01-02 00:00:00 -0500,7.25,8,1.5,0.5
+`
+	health, err = data.ParseCSV(strings.NewReader(sleep))
+	assert.NoError(t, err)
+	assert.Len(t, health.Data.Metrics, 1)
+	point = health.Data.Metrics[0].Data[0]
+	assert.Equal(t, 7.25, point.Qty)
+	assert.Equal(t, 8.0, *point.InBed)
+	assert.Equal(t, 1.5, *point.Deep)
+	assert.Equal(t, "0.5", string(point.Extra["awake"]))
+
+	// Test 7: Text and time columns split by a statistic belong to their metric
+	sleep = `Date/Time,Sleep Analysis [Asleep] (hr),Sleep Analysis [Sleep Start],Sleep Analysis [Sleep End],Sleep Analysis [Source]
+2024-01-02 00:00:00 -0500,7.25,2024-01-01 23:10:00 -0500,2024-01-02 06:55:00 -0500,Apple Watch
+`
+	health, err = data.ParseCSV(strings.NewReader(sleep))
+	assert.NoError(t, err)
+	assert.Len(t, health.Data.Metrics, 1)
+	point = health.Data.Metrics[0].Data[0]
+	assert.Equal(t, 7.25, point.Qty)
+	assert.Equal(t, "Apple Watch", point.Source)
+	assert.Equal(t, "2024-01-01 23:10:00 -0500", point.SleepStart.String())
+	assert.Equal(t, "2024-01-02 06:55:00 -0500", point.SleepEnd.String())
+}
@@ -12,6 +12,12 @@ import (
 
 var workoutData []models.Workout
 
+// ts parses a timestamp literal, keeping the raw text if it is not a valid time
+func ts(value string) models.Timestamp {
+	timestamp, _ := models.ParseTimestamp(value)
+	return timestamp
+}
+
 func init() {
 	// Create mock data for testing
 	workoutData = []models.Workout{
@@ -20,8 +26,8 @@ func init() {
 			Name:               "Outdoor Run",
 			Duration:           1800, // Duration in seconds (30 minutes)
 			Distance:           &models.Measurement{Units: "mi", Qty: 5.0},
-			Start:              "2021-01-01T07:00:00Z",
-			End:                "2021-01-01T07:30:00Z",
+			Start:              ts("2021-01-01T07:00:00Z"),
+			End:                ts("2021-01-01T07:30:00Z"),
 			ActiveEnergyBurned: &models.Measurement{Qty: 350.0, Units: "kcal"}, // Added calories
 		},
 		{
@@ -29,8 +35,8 @@ func init() {
 			Name:               "Indoor Run",
 			Duration:           2700, // Duration in seconds (45 minutes)
 			Distance:           &models.Measurement{Units: "mi", Qty: 7.5},
-			Start:              "2021-01-02T07:00:00Z",
-			End:                "2021-01-02T07:45:00Z",
+			Start:              ts("2021-01-02T07:00:00Z"),
+			End:                ts("2021-01-02T07:45:00Z"),
 			ActiveEnergyBurned: &models.Measurement{Qty: 250.0, Units: "kcal"}, // Added calories
 		},
 		{
@@ -38,8 +44,8 @@ func init() {
 			Name:               "Pool Swim",
 			Duration:           3600, // Duration in seconds (60 minutes)
 			Distance:           &models.Measurement{Units: "mi", Qty: 1.0},
-			Start:              "2021-01-03T07:00:00Z",
-			End:                "2021-01-03T08:00:00Z",
+			Start:              ts("2021-01-03T07:00:00Z"),
+			End:                ts("2021-01-03T08:00:00Z"),
 			ActiveEnergyBurned: &models.Measurement{Qty: 1400.0, Units: "kcal"}, // Added calories
 		},
 		{
@@ -47,8 +53,8 @@ func init() {
 			Name:               "Outdoor Run",
 			Duration:           1500, // Duration in seconds (25 minutes)
 			Distance:           &models.Measurement{Units: "mi", Qty: 4.0},
-			Start:              "2021-01-04T07:00:00Z",
-			End:                "2021-01-04T07:25:00Z",
+			Start:              ts("2021-01-04T07:00:00Z"),
+			End:                ts("2021-01-04T07:25:00Z"),
 			ActiveEnergyBurned: &models.Measurement{Qty: 300.0, Units: "kcal"}, // Added calories
 		},
 		{
@@ -56,8 +62,8 @@ func init() {
 			Name:               "Indoor Run",
 			Duration:           2400, // Duration in seconds (40 minutes)
 			Distance:           &models.Measurement{Units: "mi", Qty: 6.0},
-			Start:              "2021-01-05T07:00:00Z",
-			End:                "2021-01-05T07:40:00Z",
+			Start:              ts("2021-01-05T07:00:00Z"),
+			End:                ts("2021-01-05T07:40:00Z"),
 			ActiveEnergyBurned: &models.Measurement{Qty: 270.0, Units: "kcal"}, // Added calories
 		},
 		{
@@ -65,8 +71,8 @@ func init() {
 			Name:               "Pool Swim",
 			Duration:           3300, // Duration in seconds (55 minutes)
 			Distance:           &models.Measurement{Units: "mi", Qty: 0.5},
-			Start:              "2021-01-06T07:00:00Z",
-			End:                "2021-01-06T07:55:00Z",
+			Start:              ts("2021-01-06T07:00:00Z"),
+			End:                ts("2021-01-06T07:55:00Z"),
 			ActiveEnergyBurned: &models.Measurement{Qty: 350.0, Units: "kcal"}, // Added calories
 		},
 	}
@@ -134,4 +140,16 @@ func TestFilterCalories(t *testing.T) {
 			assert.GreaterOrEqual(t, workout.ActiveEnergyBurned, 0, "Expected no ActiveEnergyBurned for this workout.")
 		}
 	}
+
+	// Test 5: Energy without units is taken to be in kcal, other energy units are converted
+	energy := []models.Workout{
+		{ID: "bare", ActiveEnergyBurned: &models.Measurement{Qty: 400}},
+		{ID: "kj", ActiveEnergyBurned: &models.Measurement{Qty: 1500, Units: "kJ"}},
+		{ID: "cal", ActiveEnergyBurned: &models.Measurement{Qty: 400, Units: "cal"}},
+	}
+	filtered, ok := data.FilterCalories(energy, 300)
+	assert.True(t, ok)
+	assert.Len(t, filtered, 2)
+	assert.Equal(t, "bare", filtered[0].ID)
+	assert.Equal(t, "kj", filtered[1].ID)
 }
@@ -0,0 +1,135 @@
+// test/daterange_test.go
+
+package test
+
+import (
+	"fitness/data"
+	"fitness/models"
+	"fitness/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// spanOf resolves a date expression and returns its first and last day
+func spanOf(t *testing.T, r utils.DateResolver, expr string) [2]string {
+	span, err := r.Span(expr)
+	assert.NoError(t, err, expr)
+	return [2]string{span.Start.Format("2006-01-02"), span.End.AddDate(0, 0, -1).Format("2006-01-02")}
+}
+
+func TestDateResolver(t *testing.T) {
+	// Wednesday 14 August 2024, late in the evening in UTC
+	now := func() time.Time { return time.Date(2024, 8, 14, 23, 30, 0, 0, time.UTC) }
+	week := utils.Bucketer{Period: utils.Week, WeekStart: time.Monday, Zone: models.FixedZone(time.UTC)}
+	r := utils.DateResolver{Now: now, Week: week}
+
+	// Test 1: Absolute dates name whole days, months, quarters, years and ISO weeks
+	assert.Equal(t, [2]string{"2024-07-14", "2024-07-14"}, spanOf(t, r, "2024-07-14"))
+	assert.Equal(t, [2]string{"2024-07-01", "2024-07-31"}, spanOf(t, r, "2024-07"))
+	assert.Equal(t, [2]string{"2024-07-01", "2024-09-30"}, spanOf(t, r, "2024-Q3"))
+	assert.Equal(t, [2]string{"2023-01-01", "2023-12-31"}, spanOf(t, r, "2023"))
+	assert.Equal(t, [2]string{"2024-01-29", "2024-02-04"}, spanOf(t, r, "2024-W05"))
+
+	// Test 2: Relative dates resolve against the clock
+	assert.Equal(t, [2]string{"2024-08-14", "2024-08-14"}, spanOf(t, r, "today"))
+	assert.Equal(t, [2]string{"2024-08-13", "2024-08-13"}, spanOf(t, r, "yesterday"))
+	assert.Equal(t, [2]string{"2024-08-08", "2024-08-14"}, spanOf(t, r, "7d"))
+	assert.Equal(t, [2]string{"2024-07-15", "2024-08-14"}, spanOf(t, r, "1mo"))
+	assert.Equal(t, [2]string{"2024-01-01", "2024-08-14"}, spanOf(t, r, "ytd"))
+	assert.Equal(t, [2]string{"2024-08-12", "2024-08-18"}, spanOf(t, r, "this-week"))
+	assert.Equal(t, [2]string{"2024-08-05", "2024-08-11"}, spanOf(t, r, "last-week"))
+	assert.Equal(t, [2]string{"2024-07-01", "2024-07-31"}, spanOf(t, r, "last-month"))
+	assert.Equal(t, [2]string{"2024-04-01", "2024-06-30"}, spanOf(t, r, "last-quarter"))
+	assert.Equal(t, [2]string{"2023-01-01", "2023-12-31"}, spanOf(t, r, "last-year"))
+
+	// Test 3: The zone decides which day it is
+	tokyo, err := models.ParseZone("Asia/Tokyo")
+	assert.NoError(t, err)
+	week.Zone = tokyo
+	assert.Equal(t, [2]string{"2024-08-15", "2024-08-15"}, spanOf(t, utils.DateResolver{Now: now, Week: week}, "today"))
+
+	// Test 4: Since and until override the ends of the period, until includes the whole day
+	from, to, err := r.Range("", "", "2024-Q3")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), to)
+	from, to, err = r.Range("2024-08-01", "2024-08-10", "2024-Q3")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2024, 8, 11, 0, 0, 0, 0, time.UTC), to)
+	from, to, err = r.Range("7d", "", "")
+	assert.NoError(t, err)
+	assert.True(t, to.IsZero())
+	assert.Equal(t, time.Date(2024, 8, 8, 0, 0, 0, 0, time.UTC), from)
+
+	// Test 5: Invalid and empty ranges are rejected
+	for _, expr := range []string{"bogus", "0d", "2024-W54", "2023-W53", "2024-Q5"} {
+		_, err := r.Span(expr)
+		assert.Error(t, err, expr)
+	}
+	_, _, err = r.Range("2024-09", "2024-08", "")
+	assert.Error(t, err)
+	_, err = r.Span("3m")
+	assert.ErrorContains(t, err, "write 3mo for months")
+
+	// Test 6: The range selects workouts and metric data points
+	store := data.NewMemoryStore(data.MergeNewest)
+	store.UpsertWorkouts([]models.Workout{
+		{ID: "1", Name: "Run", Start: ts("2024-07-31T10:00:00Z")},
+		{ID: "2", Name: "Run", Start: ts("2024-08-01T10:00:00Z")},
+	})
+	store.UpsertMetrics([]models.Metric{{Name: "step_count", Units: "count", Data: []models.MetricData{
+		{Date: ts("2024-07-31T00:00:00Z"), Qty: 1000},
+		{Date: ts("2024-08-02T00:00:00Z"), Qty: 2000},
+	}}})
+	from, to, err = r.Range("", "", "last-month")
+	assert.NoError(t, err)
+	workouts := store.Workouts(data.TimeRange{From: from, To: to})
+	assert.Len(t, workouts, 1)
+	assert.Equal(t, "1", workouts[0].ID)
+	metrics := store.Metrics(data.TimeRange{From: from, To: to})
+	assert.Len(t, metrics, 1)
+	assert.Len(t, metrics[0].Data, 1)
+	assert.Equal(t, 1000.0, metrics[0].Data[0].Qty)
+
+	// Test 7: A timestamp bound includes its instant, also as the end of the range
+	from, to, err = r.Range("2024-07-31T10:00:00Z", "2024-07-31T10:00:00Z", "")
+	assert.NoError(t, err)
+	workouts = store.Workouts(data.TimeRange{From: from, To: to})
+	assert.Len(t, workouts, 1)
+	assert.Equal(t, "1", workouts[0].ID)
+	_, to, err = r.Range("", "2024-08-01T10:00:00Z", "")
+	assert.NoError(t, err)
+	assert.Len(t, store.Workouts(data.TimeRange{To: to}), 2)
+}
+
+func TestDateRangeZone(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 8, 14, 12, 0, 0, 0, time.UTC) }
+	store := data.NewMemoryStore(data.MergeNewest)
+	store.UpsertWorkouts([]models.Workout{
+		{ID: "tokyo", Name: "Run", Start: ts("2024-08-01 08:30:00 +0900")},
+		{ID: "utc", Name: "Run", Start: ts("2024-07-31 12:00:00 +0000")},
+	})
+	selected := func(zone models.Zone) []string {
+		week := utils.Bucketer{Period: utils.Week, WeekStart: time.Monday, Zone: zone}
+		from, to, err := utils.DateResolver{Now: now, Week: week}.Range("", "", "last-month")
+		assert.NoError(t, err)
+		var ids []string
+		for _, w := range store.Workouts(data.TimeRange{From: from, To: to, Zone: zone}) {
+			ids = append(ids, w.ID)
+		}
+		return ids
+	}
+
+	// Test 1: Under the original policy a workout is on the day of its own wall clock, as in the date filters
+	original := models.Zone{}
+	assert.Equal(t, []string{"utc"}, selected(original))
+	filtered, _ := data.FilterDate(store.Workouts(data.AllTime), "2024-08-01", true, original)
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, "tokyo", filtered[0].ID)
+
+	// Test 2: Under a fixed zone the workout is on the day it falls on in that zone
+	assert.Equal(t, []string{"utc", "tokyo"}, selected(models.FixedZone(time.UTC)))
+}
@@ -0,0 +1,86 @@
+// test/fields_test.go
+
+package test
+
+import (
+	"fitness/fields"
+	"fitness/models"
+	"fitness/quantity"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fieldNames lists the names of selected fields in order
+func fieldNames[T any](selected []*fields.Field[T]) []string {
+	var names []string
+	for _, f := range selected {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestFieldSelection(t *testing.T) {
+	table := func(f *fields.Field[models.Workout]) bool { return f.Table }
+
+	// Test 1: Without -i the default columns are shown in registry order, less the excluded ones
+	selected, err := fields.Workouts.Select(nil, []string{"calories"}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"name", "start", "duration", "distance"}, fieldNames(selected))
+
+	// Test 2: -i picks and orders the columns, aliases included
+	selected, err = fields.Workouts.Select([]string{"Name", "pace", "temp", "date"}, []string{"start"}, table)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"name", "pace", "temperature"}, fieldNames(selected))
+
+	// Test 3: Unknown fields are rejected with suggestions, or the list of fields if nothing is close
+	_, err = fields.Workouts.Select([]string{"name", "distnce"}, nil, table)
+	assert.EqualError(t, err, `unknown field "distnce" (did you mean distance?)`)
+	_, err = fields.Workouts.Select(nil, []string{"tme"}, table)
+	assert.EqualError(t, err, `unknown field "tme" (did you mean time?)`)
+	_, err = fields.Workouts.Select([]string{"vo2max"}, nil, table)
+	assert.ErrorContains(t, err, "expected one of name, id, start")
+
+	// Test 4: Names may belong to any of several sets
+	assert.NoError(t, fields.Check("field", []string{"latest", "value", "date"}, fields.Metrics, fields.Points))
+	assert.Error(t, fields.Check("field", []string{"distance"}, fields.Metrics, fields.Points))
+}
+
+func TestFieldValues(t *testing.T) {
+	ctx := fields.Context{Units: quantity.Metric, Zone: utc, TimeFormat: "2006-01-02 15:04"}
+	show := func(name string, w models.Workout) string {
+		f, ok := fields.Workouts.Lookup(name)
+		assert.True(t, ok, name)
+		return f.String(f.Get(w, ctx), ctx)
+	}
+	run := models.Workout{
+		Name:               "Outdoor Run",
+		Start:              ts("2024-08-10T07:30:00Z"),
+		Duration:           1650,
+		Distance:           &models.Measurement{Qty: 5, Units: "km"},
+		ActiveEnergyBurned: &models.Measurement{Qty: 1255.2, Units: "kJ"},
+		Humidity:           &models.Measurement{Qty: 70, Units: "%"},
+	}
+
+	// Test 1: Values are formatted in the display units
+	assert.Equal(t, "5.00 km", show("distance", run))
+	assert.Equal(t, "300 kcal", show("energy", run))
+	assert.Equal(t, "27:30", show("duration", run))
+	assert.Equal(t, "70%", show("humidity", run))
+	assert.Equal(t, "2024-08-10 07:30", show("start", run))
+	assert.Equal(t, "saturday", show("weekday", run))
+
+	// Test 2: Pace is the time per display unit of distance
+	assert.Equal(t, "05:30 /km", show("pace", run))
+	ctx.Units = quantity.Imperial
+	assert.Equal(t, "08:51 /mi", show("pace", run))
+
+	// Test 3: Missing values are shown as -
+	assert.Equal(t, "-", show("location", run))
+	assert.Equal(t, "-", show("pace", models.Workout{Duration: 600}))
+
+	// Test 4: Metric values are shown without units in the display units of the metric
+	latest, _ := fields.Metrics.Lookup("latest")
+	weight := models.Metric{Name: "weight_body_mass", Units: "kg", Data: []models.MetricData{{Qty: 80}}}
+	assert.Equal(t, "176.37", latest.String(latest.Get(weight, ctx), ctx))
+}
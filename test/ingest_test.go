@@ -0,0 +1,170 @@
+// test/ingest_test.go
+
+package test
+
+import (
+	"encoding/json"
+	"fitness/data"
+	"fitness/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCorpus generates daily export files that each repeat the previous day's records
+// Every file overlaps the one before it, so the merge order decides which values win
+func writeCorpus(tb testing.TB, dir string, files, workoutsPerFile, pointsPerMetric int) {
+	tb.Helper()
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for f := 0; f < files; f++ {
+		var health models.HealthData
+		for w := 0; w < workoutsPerFile; w++ {
+			start := day.AddDate(0, 0, f-1).Add(time.Duration(w) * time.Hour)
+			health.Data.Workouts = append(health.Data.Workouts, models.Workout{
+				ID:       fmt.Sprintf("w-%d-%d", f+w/2, w%2),
+				Name:     "Outdoor Run",
+				Start:    models.NewTimestamp(start),
+				End:      models.NewTimestamp(start.Add(30 * time.Minute)),
+				Duration: float64(1800 + f),
+				Distance: &models.Measurement{Units: "mi", Qty: float64(f)},
+			})
+		}
+		for _, name := range []string{"step_count", "active_energy", "resting_heart_rate"} {
+			metric := models.Metric{Name: name, Units: "count"}
+			for p := 0; p < pointsPerMetric; p++ {
+				date := day.AddDate(0, 0, f+p-pointsPerMetric/2)
+				metric.Data = append(metric.Data, models.MetricData{Date: models.NewTimestamp(date), Qty: float64(f*1000 + p)})
+			}
+			health.Data.Metrics = append(health.Data.Metrics, metric)
+		}
+
+		content, err := json.Marshal(health)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("HealthAutoExport-%s.json", day.AddDate(0, 0, f).Format("2006-01-02")))
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// ingestCorpus reads every file in dir into a new store with the given number of workers
+func ingestCorpus(tb testing.TB, dir string, workers int) *data.MemoryStore {
+	tb.Helper()
+	store := data.NewMemoryStore(data.MergeNewest)
+	files, err := data.ScanDirectory(dir, store.Manifest())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	data.IngestFilesConcurrently(store, files, workers)
+	return store
+}
+
+func TestIngestFilesConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	writeCorpus(t, dir, 20, 10, 10)
+
+	var serial, concurrent *data.MemoryStore
+	captureStdout(t, func() {
+		serial = ingestCorpus(t, dir, 1)
+		concurrent = ingestCorpus(t, dir, 8)
+	})
+
+	// Test 1: The merged result does not depend on the number of workers
+	assert.Equal(t, serial.Workouts(data.AllTime), concurrent.Workouts(data.AllTime))
+	assert.Equal(t, serial.Metrics(data.AllTime), concurrent.Metrics(data.AllTime))
+
+	// Test 2: Newer files win conflicts, as if the files were read one by one
+	workouts := concurrent.Workouts(data.AllTime)
+	assert.Len(t, workouts, 48)
+	for _, w := range workouts {
+		if w.ID == "w-5-0" {
+			assert.Equal(t, float64(5), w.Distance.Qty, "Expected the last file containing the workout to win.")
+		}
+	}
+
+	// Test 3: Every file is recorded in the manifest with its hash
+	manifest := concurrent.Manifest()
+	assert.Len(t, manifest, 20)
+	for _, entry := range manifest {
+		assert.Equal(t, models.FileIngested, entry.Status)
+		assert.Len(t, entry.Hash, 64)
+	}
+}
+
+func TestIngestStreamedExport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.json": `{"version": 2, "data": {"metrics": null, "workouts": [{"id": "a", "name": "Outdoor Run", "start": "2024-01-01 07:00:00 -0500"}], "symptoms": [{"name": "x"}]}}`,
+		"b.json": `{"data": {"workouts": {"id": "b"}}}`,
+		"c.json": `{"data": {"workouts": []}} {}`,
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	var store *data.MemoryStore
+	captureStdout(t, func() { store = ingestCorpus(t, dir, 2) })
+	manifest := store.Manifest()
+
+	// Test 1: Workouts and metrics are read one by one, other keys and null lists are passed over
+	assert.Equal(t, models.FileIngested, manifest[filepath.Join(dir, "a.json")].Status)
+	assert.Len(t, store.Workouts(data.AllTime), 1)
+
+	// Test 2: A list of the wrong shape or data after the document fails the file
+	assert.Contains(t, manifest[filepath.Join(dir, "b.json")].Error, "expected an array")
+	assert.Contains(t, manifest[filepath.Join(dir, "c.json")].Error, "unexpected data after the top-level value")
+}
+
+// benchmarkIngest measures ingesting a corpus of a year of daily exports
+func benchmarkIngest(b *testing.B, workers int) {
+	dir := b.TempDir()
+	writeCorpus(b, dir, 365, 20, 60)
+
+	b.ResetTimer()
+	captureStdout(b, func() {
+		for i := 0; i < b.N; i++ {
+			ingestCorpus(b, dir, workers)
+		}
+	})
+}
+
+// BenchmarkIngestBaseline reads and merges the corpus one file at a time, as ingest used to
+func BenchmarkIngestBaseline(b *testing.B) {
+	dir := b.TempDir()
+	writeCorpus(b, dir, 365, 20, 60)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store := data.NewMemoryStore(data.MergeNewest)
+		files, err := data.ScanDirectory(dir, store.Manifest())
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file.Path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var health models.HealthData
+			if err := json.Unmarshal(content, &health); err != nil {
+				b.Fatal(err)
+			}
+			store.UpsertWorkouts(health.Data.Workouts)
+			store.UpsertMetrics(health.Data.Metrics)
+		}
+	}
+}
+
+func BenchmarkIngestSerial(b *testing.B) {
+	benchmarkIngest(b, 1)
+}
+
+func BenchmarkIngestConcurrent(b *testing.B) {
+	benchmarkIngest(b, runtime.NumCPU())
+}
@@ -0,0 +1,140 @@
+// test/manifest_test.go
+
+package test
+
+import (
+	"fitness/cli"
+	"fitness/config"
+	"fitness/data"
+	"fitness/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// manifestExport is an export file with a single workout
+const manifestExport = `{"data":{"workouts":[{"id":"a","name":"Outdoor Run","start":"2024-01-01 07:00:00 -0500","duration":1800}],"metrics":[]}}`
+
+// scanFiles scans dir against the manifest, keyed by file name
+func scanFiles(t *testing.T, dir string, manifest models.Manifest) ([]data.FileStatus, map[string]data.FileStatus) {
+	t.Helper()
+	files, err := data.ScanDirectory(dir, manifest)
+	assert.NoError(t, err)
+	byName := make(map[string]data.FileStatus)
+	for _, file := range files {
+		byName[filepath.Base(file.Path)] = file
+	}
+	return files, byName
+}
+
+// captureStdout returns what run prints to standard output
+func captureStdout(tb testing.TB, run func()) string {
+	tb.Helper()
+	return captureOutput(tb, &os.Stdout, run)
+}
+
+// captureStderr returns what run prints to standard error
+func captureStderr(tb testing.TB, run func()) string {
+	tb.Helper()
+	return captureOutput(tb, &os.Stderr, run)
+}
+
+// captureOutput returns what run writes to the given standard file
+func captureOutput(tb testing.TB, file **os.File, run func()) string {
+	tb.Helper()
+	out, err := os.CreateTemp(tb.TempDir(), "output")
+	assert.NoError(tb, err)
+	original := *file
+	*file = out
+	run()
+	*file = original
+	out.Close()
+	content, err := os.ReadFile(out.Name())
+	assert.NoError(tb, err)
+	return string(content)
+}
+
+func TestScanDirectory(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	bad := filepath.Join(dir, "bad.json")
+	assert.NoError(t, os.WriteFile(good, []byte(manifestExport), 0644))
+	assert.NoError(t, os.WriteFile(bad, []byte("{bad"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0644))
+	store := data.NewMemoryStore(data.MergeNewest)
+	ingest := func() (updated bool) {
+		captureStdout(t, func() {
+			var err error
+			updated, err = data.LoadDirectory(store, dir)
+			assert.NoError(t, err)
+		})
+		return updated
+	}
+
+	// Test 1: Files that were never ingested are pending, other file types are left out
+	files, byName := scanFiles(t, dir, store.Manifest())
+	assert.Len(t, files, 2)
+	assert.Equal(t, models.FilePending, byName["good.json"].Status)
+	assert.Nil(t, byName["good.json"].Entry)
+	assert.True(t, byName["bad.json"].NeedsIngest())
+
+	// Test 2: Ingested files are unchanged on the next scan
+	assert.True(t, ingest())
+	_, byName = scanFiles(t, dir, store.Manifest())
+	assert.Equal(t, models.FileIngested, byName["good.json"].Status)
+	assert.False(t, byName["good.json"].NeedsIngest())
+	assert.Equal(t, 1, byName["good.json"].Entry.Workouts)
+
+	// Test 3: A file that failed is recorded with its error and retried while it is unchanged
+	assert.Equal(t, models.FileFailed, store.Manifest()[bad].Status)
+	assert.NotEmpty(t, store.Manifest()[bad].Error)
+	assert.True(t, byName["bad.json"].Failed())
+	assert.Equal(t, models.FilePending, byName["bad.json"].Status)
+	assert.True(t, byName["bad.json"].NeedsIngest())
+
+	// Test 4: A second ingest skips the unchanged file
+	assert.NoError(t, os.Remove(bad))
+	assert.False(t, ingest())
+	assert.Len(t, store.Workouts(data.AllTime), 1)
+
+	// Test 5: A file with a new mtime but the same content is unchanged, and its mtime is refreshed
+	touched := time.Now().Add(time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(good, touched, touched))
+	_, byName = scanFiles(t, dir, store.Manifest())
+	assert.Equal(t, models.FileIngested, byName["good.json"].Status)
+	assert.Equal(t, byName["good.json"].Entry.Hash, byName["good.json"].Hash)
+	assert.True(t, ingest())
+	assert.True(t, store.Manifest()[good].ModTime.Equal(touched))
+	_, byName = scanFiles(t, dir, store.Manifest())
+	assert.Empty(t, byName["good.json"].Hash)
+
+	// Test 6: A file whose content changed is changed
+	assert.NoError(t, os.WriteFile(good, []byte(manifestExport+"\n"), 0644))
+	_, byName = scanFiles(t, dir, store.Manifest())
+	assert.Equal(t, models.FileChanged, byName["good.json"].Status)
+	assert.True(t, byName["good.json"].NeedsIngest())
+}
+
+func TestImportStatus(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.ICloudDirPath = dir
+	cfg.CacheFilePath = filepath.Join(t.TempDir(), "cache.json")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "good.json"), []byte(manifestExport), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{bad"), 0644))
+	captureStdout(t, func() {
+		_, err := data.ImportData(cfg)
+		assert.NoError(t, err)
+	})
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "new.json"), []byte(manifestExport), 0644))
+
+	// Test 1: Each file is listed with its status and counts, failed files with their error
+	out := captureStdout(t, func() { assert.NoError(t, cli.PrintImportStatus(cfg)) })
+	assert.Regexp(t, `(?m)^failed +0 +0 +0 +\S+ \S+ bad\.json\n +error unmarshaling file`, out)
+	assert.Regexp(t, `(?m)^ingested +1 +0 +0 +\S+ \S+ good\.json$`, out)
+	assert.Regexp(t, `(?m)^pending +- +- +- +- +new\.json$`, out)
+	assert.Contains(t, out, "1 ingested, 1 pending, 0 changed, 1 failed\nFailed files are retried on every run\n")
+}
@@ -0,0 +1,118 @@
+// test/merge_test.go
+
+package test
+
+import (
+	"fitness/config"
+	"fitness/data"
+	"fitness/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeWorkouts(t *testing.T) {
+	// The same workout seen again in a newer export with an updated duration
+	updated := workoutData[0]
+	updated.Duration = 1900
+
+	// A workout without an ID is keyed by name and start time
+	noID := models.Workout{Name: "Yoga", Start: ts("2021-01-07T07:00:00Z"), Duration: 600}
+	noIDLonger := noID
+	noIDLonger.Duration = 900
+
+	// Test 1: Newest wins replaces the existing record and removes duplicates
+	merged := data.MergeWorkouts(workoutData, []models.Workout{updated, noID, noIDLonger}, data.MergeNewest)
+	assert.Len(t, merged, len(workoutData)+1, "Expected duplicates to be merged.")
+	assert.Equal(t, 1900.0, merged[0].Duration)
+	assert.Equal(t, 900.0, merged[len(merged)-1].Duration)
+
+	// Test 2: Keep first ignores later versions
+	merged = data.MergeWorkouts(workoutData, []models.Workout{updated}, data.MergeFirst)
+	assert.Equal(t, 1800.0, merged[0].Duration)
+
+	// Test 3: Keep max prefers the longer workout regardless of order
+	merged = data.MergeWorkouts([]models.Workout{noIDLonger}, []models.Workout{noID}, data.MergeMax)
+	assert.Len(t, merged, 1)
+	assert.Equal(t, 900.0, merged[0].Duration)
+
+	// Test 4: A winning version without tags keeps the tags of the other
+	tagged := noID
+	tagged.Tags = []string{"stretch"}
+	merged = data.MergeWorkouts([]models.Workout{tagged}, []models.Workout{noIDLonger}, data.MergeNewest)
+	assert.Equal(t, 900.0, merged[0].Duration)
+	assert.Equal(t, []string{"stretch"}, merged[0].Tags)
+}
+
+func TestMergeMetrics(t *testing.T) {
+	existing := []models.Metric{
+		{Name: "step_count", Units: "count", Data: []models.MetricData{
+			{Date: ts("2021-01-02 00:00:00 -0500"), Qty: 9000},
+			{Date: ts("2021-01-01 00:00:00 -0500"), Qty: 8000},
+		}},
+	}
+	incoming := []models.Metric{
+		{Name: "step_count", Units: "count", Data: []models.MetricData{
+			{Date: ts("2021-01-02 00:00:00 -0500"), Qty: 8500},
+			{Date: ts("2021-01-03 00:00:00 -0500"), Qty: 7000},
+		}},
+		{Name: "active_energy", Units: "kcal", Data: []models.MetricData{
+			{Date: ts("2021-01-01 00:00:00 -0500"), Qty: 450},
+		}},
+	}
+
+	// Test 1: One entry per metric name, data sorted by date with no duplicate dates
+	merged := data.MergeMetrics(existing, incoming, data.MergeNewest)
+	assert.Len(t, merged, 2)
+	assert.Equal(t, "active_energy", merged[0].Name)
+	steps := merged[1]
+	assert.Len(t, steps.Data, 3)
+	assert.Equal(t, "2021-01-01 00:00:00 -0500", steps.Data[0].Date.String())
+	assert.Equal(t, 8500.0, steps.Data[1].Qty)
+
+	// Test 2: Keep max keeps the larger value for a duplicate date
+	merged = data.MergeMetrics(existing, incoming, data.MergeMax)
+	assert.Equal(t, 9000.0, merged[1].Data[1].Qty)
+
+	// Test 3: Data points in other units than the stored ones are converted, whatever the policy
+	weight := []models.Metric{{Name: "weight_body_mass", Units: "kg", Data: []models.MetricData{{Date: ts("2021-01-01 00:00:00 -0500"), Qty: 80}}}}
+	incoming = []models.Metric{{Name: "weight_body_mass", Units: "lb", Data: []models.MetricData{{Date: ts("2021-01-02 00:00:00 -0500"), Qty: 176}}}}
+	merged = data.MergeMetrics(weight, incoming, data.MergeNewest)
+	assert.Equal(t, "kg", merged[0].Units)
+	assert.Len(t, merged[0].Data, 2)
+	assert.InDelta(t, 79.83, merged[0].Data[1].Qty, 0.01)
+
+	// Test 4: Data points in units that do not convert are left out
+	incoming = []models.Metric{{Name: "weight_body_mass", Units: "kcal", Data: []models.MetricData{{Date: ts("2021-01-02 00:00:00 -0500"), Qty: 176}}}}
+	var stdout string
+	stderr := captureStderr(t, func() {
+		stdout = captureStdout(t, func() { merged = data.MergeMetrics(weight, incoming, data.MergeNewest) })
+	})
+	assert.Len(t, merged[0].Data, 1)
+	assert.Contains(t, stderr, "Warning: weight_body_mass: 1 data points in kcal left out")
+	assert.Empty(t, stdout, "Expected warnings to stay out of the listing output.")
+
+	// Test 5: Unknown policies are rejected
+	_, err := data.ParseMergePolicy("latest")
+	assert.Error(t, err)
+
+	// Test 6: The statistics of converted points are converted as well
+	max := 180.0
+	incoming = []models.Metric{{Name: "weight_body_mass", Units: "lb", Data: []models.MetricData{{Date: ts("2021-01-02 00:00:00 -0500"), Qty: 176, Max: &max}}}}
+	merged = data.MergeMetrics(weight, incoming, data.MergeNewest)
+	assert.InDelta(t, 81.65, *merged[0].Data[1].Max, 0.01)
+	assert.Equal(t, 180.0, max)
+}
+
+func TestImportMergePolicy(t *testing.T) {
+	cfg := config.Default()
+	cfg.ICloudDirPath = t.TempDir()
+	cfg.CacheFilePath = filepath.Join(t.TempDir(), "cache.json")
+	cfg.MergePolicy = "latest"
+
+	// Test 1: An invalid merge policy is an error naming the policy, not a failure to load the cache
+	_, err := data.ImportData(cfg)
+	assert.ErrorContains(t, err, `invalid merge policy: unknown merge policy "latest"`)
+	assert.NotContains(t, err.Error(), "failed to load cache")
+}
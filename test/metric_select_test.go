@@ -0,0 +1,88 @@
+// test/metric_select_test.go
+
+package test
+
+import (
+	"fitness/data"
+	"fitness/models"
+	"fitness/quantity"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// condition parses a condition in metric units and UTC, failing the test if it does not parse
+func condition(t *testing.T, spec string) data.Condition {
+	c, err := data.ParseCondition(spec, quantity.Metric, utc)
+	assert.NoError(t, err, spec)
+	return c
+}
+
+func TestMetricPatterns(t *testing.T) {
+	// Test 1: Names and globs are matched case-insensitively
+	patterns, err := data.ParseMetricPatterns("heart_rate*, Step_Count")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"heart_rate*", "step_count"}, patterns)
+	assert.True(t, data.MatchMetricName(patterns, "heart_rate_variability"))
+	assert.True(t, data.MatchMetricName(patterns, "step_count"))
+	assert.False(t, data.MatchMetricName(patterns, "step_count_total"))
+	assert.False(t, data.MatchMetricName(patterns, "resting_heart_rate"))
+
+	// Test 2: No patterns select every metric
+	patterns, err = data.ParseMetricPatterns("")
+	assert.NoError(t, err)
+	assert.True(t, data.MatchMetricName(patterns, "anything"))
+
+	// Test 3: Malformed globs are rejected
+	_, err = data.ParseMetricPatterns("heart_rate[")
+	assert.Error(t, err)
+}
+
+func TestMetricPointConditions(t *testing.T) {
+	weight := models.Metric{Name: "weight_body_mass", Units: "lb", Data: []models.MetricData{
+		{Date: ts("2024-07-20T00:00:00Z"), Qty: 190},
+		{Date: ts("2024-08-05T00:00:00Z"), Qty: 170},
+	}}
+	steps := models.Metric{Name: "step_count", Units: "count", Data: []models.MetricData{
+		{Date: ts("2024-08-05T00:00:00Z"), Qty: 9000},
+	}}
+
+	// Test 1: Values compare in the metric's units, converted from the condition's
+	assert.True(t, condition(t, "value>80kg").MatchPoint(weight, weight.Data[0]))
+	assert.False(t, condition(t, "value>80kg").MatchPoint(weight, weight.Data[1]))
+	assert.False(t, condition(t, "value>80kg").MatchPoint(steps, steps.Data[0]))
+	assert.True(t, condition(t, "value>=9000").MatchPoint(steps, steps.Data[0]))
+
+	// Test 2: Dates select the points of a metric, names the metric itself
+	assert.True(t, condition(t, "date>=2024-08-01").MatchPoint(weight, weight.Data[1]))
+	assert.False(t, condition(t, "date>=2024-08-01").MatchPoint(weight, weight.Data[0]))
+	assert.True(t, condition(t, "name~weight").MatchPoint(weight, weight.Data[0]))
+
+	// Test 3: A metric matches if any of its points does
+	match := func(metric models.Metric, specs ...string) bool {
+		var conditions []data.Condition
+		for _, spec := range specs {
+			conditions = append(conditions, condition(t, spec))
+		}
+		return data.MatchAllMetric(conditions, metric)
+	}
+	assert.True(t, match(weight, "value<80kg"))
+	assert.False(t, match(steps, "value>9000"))
+	assert.False(t, match(weight, "distance>5km"))
+	assert.True(t, match(weight, "name~weight"))
+	assert.True(t, match(models.Metric{Name: "weight_body_mass"}, "name~weight"))
+
+	// Test 4: Conditions on points must hold for the same point
+	assert.False(t, match(weight, "value>80kg", "date>=2024-08-01"))
+	assert.True(t, match(weight, "value<80kg", "date>=2024-08-01"))
+	assert.False(t, match(weight, "name~steps", "value<80kg"))
+
+	// Test 5: Every condition must hold for a point
+	conditions := []data.Condition{condition(t, "date>=2024-08-01"), condition(t, "value<80kg")}
+	assert.True(t, data.MatchAllPoints(conditions, weight, weight.Data[1]))
+	assert.False(t, data.MatchAllPoints(conditions, weight, weight.Data[0]))
+
+	// Test 6: Fields only data points have are rejected for workouts, fields both have are not
+	assert.NoError(t, data.CheckWorkoutFields(conditions[:1]))
+	assert.ErrorContains(t, data.CheckWorkoutFields(conditions), `unknown field for workouts "value"`)
+}
@@ -0,0 +1,92 @@
+// test/metricdata_test.go
+
+package test
+
+import (
+	"encoding/json"
+	"fitness/fields"
+	"fitness/models"
+	"fitness/quantity"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricDataJSON(t *testing.T) {
+	input := `[
+		{"date": "2024-02-01 00:00:00 -0500", "Min": 52, "Avg": 71.5, "Max": 160, "source": "Apple Watch", "context": {"kind":"resting"}},
+		{"date": "2024-02-01 00:00:00 -0500", "asleep": 7.25, "inBed": 8, "rem": 2, "sleepStart": "2024-01-31 23:10:00 -0500", "awake": 0.5},
+		{"date": "2024-02-01 00:00:00 -0500", "qty": 9000}
+	]`
+	var points []models.MetricData
+	assert.NoError(t, json.Unmarshal([]byte(input), &points))
+
+	// Test 1: Summary points are valued by their average, sleep points by the time asleep
+	assert.Equal(t, 71.5, points[0].Qty)
+	assert.Equal(t, 160.0, *points[0].Max)
+	assert.Equal(t, "Apple Watch", points[0].Source)
+	assert.Equal(t, 7.25, points[1].Qty)
+	assert.Equal(t, 2.0, *points[1].REM)
+	assert.Equal(t, "2024-01-31 23:10:00 -0500", points[1].SleepStart.String())
+	assert.Equal(t, 9000.0, points[2].Qty)
+
+	// Test 2: Unknown fields are kept and only those
+	assert.Equal(t, []string{"context"}, keysOf(points[0].Extra))
+	assert.Equal(t, []string{"awake"}, keysOf(points[1].Extra))
+	assert.Nil(t, points[2].Extra)
+
+	// Test 3: Writing and reading back loses nothing, extra fields are written compactly
+	encoded, err := json.Marshal(points)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"context":{"kind":"resting"}`)
+	var decoded []models.MetricData
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, points, decoded)
+
+	// Test 4: Known fields match ignoring case, and a field of the wrong type is reported by name
+	var point models.MetricData
+	assert.NoError(t, json.Unmarshal([]byte(`{"DATE": "2024-02-01 00:00:00 -0500", "QTY": 3, "avg": 2}`), &point))
+	assert.Equal(t, 3.0, point.Qty)
+	assert.Equal(t, 2.0, *point.Avg)
+	assert.Nil(t, point.Extra)
+	err = json.Unmarshal([]byte(`{"date": "2024-02-01 00:00:00 -0500", "qty": "many"}`), &point)
+	assert.ErrorContains(t, err, "metric data field qty")
+}
+
+func TestMetricDataFields(t *testing.T) {
+	low, high, inBed := 52.0, 160.0, 8.0
+	heart := models.Metric{Name: "heart_rate", Units: "count/min"}
+	sleep := models.Metric{Name: "sleep_analysis", Units: "hr"}
+	ctx := fields.Context{Units: quantity.Metric, Zone: utc, TimeFormat: "2006-01-02"}
+	show := func(name string, p fields.Point) string {
+		f, ok := fields.Points.Lookup(name)
+		assert.True(t, ok, name)
+		return f.String(f.Get(p, ctx), ctx)
+	}
+
+	// Test 1: Readings and sleep stages are fields of data points, missing where a point has none
+	assert.Equal(t, "52.00", show("min", fields.Point{Metric: heart, Data: models.MetricData{Min: &low, Max: &high}}))
+	assert.Equal(t, "160.00", show("max", fields.Point{Metric: heart, Data: models.MetricData{Min: &low, Max: &high}}))
+	assert.Equal(t, "8.00", show("in_bed", fields.Point{Metric: sleep, Data: models.MetricData{InBed: &inBed}}))
+	assert.Equal(t, "-", show("deep", fields.Point{Metric: sleep, Data: models.MetricData{InBed: &inBed}}))
+	assert.Equal(t, "-", show("source", fields.Point{Metric: sleep}))
+
+	// Test 2: Readings and sleep stages are flagged to follow the value in listings, the value is not
+	for _, name := range []string{"min", "max", "deep", "sleepstart", "source"} {
+		f, _ := fields.Points.Lookup(name)
+		assert.True(t, f.Reading, name)
+	}
+	value, _ := fields.Points.Lookup("value")
+	assert.False(t, value.Reading)
+}
+
+// keysOf lists the keys of extra fields in order
+func keysOf(extra map[string]json.RawMessage) []string {
+	var keys []string
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,80 @@
+// test/quantity_test.go
+
+package test
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantityConversion(t *testing.T) {
+	// Test 1: Units convert within a dimension
+	mile, err := quantity.New(1, "mi")
+	assert.NoError(t, err)
+	km, err := mile.In(quantity.Kilometer)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.609344, km.Value, 1e-9)
+
+	hot, _ := quantity.New(212, "degF")
+	celsius, err := hot.In(quantity.Celsius)
+	assert.NoError(t, err)
+	assert.InDelta(t, 100, celsius.Value, 1e-9)
+
+	// Test 2: Quantities of the same dimension add up in the first unit
+	fiveK, _ := quantity.New(5, "km")
+	total, err := mile.Add(fiveK)
+	assert.NoError(t, err)
+	assert.Equal(t, quantity.Mile, total.Unit)
+	assert.InDelta(t, 4.10686, total.Value, 1e-5)
+
+	// Test 3: Incompatible and unknown units are refused
+	energy, _ := quantity.New(300, "kcal")
+	_, err = mile.Add(energy)
+	assert.Error(t, err)
+	_, err = quantity.New(1, "furlongs per fortnight")
+	assert.Error(t, err)
+
+	// Test 4: A calorie is a thousandth of a kilocalorie, ambiguous spellings are refused
+	small, err := quantity.New(320000, "cal")
+	assert.NoError(t, err)
+	kcal, err := small.In(quantity.Kilocalorie)
+	assert.NoError(t, err)
+	assert.InDelta(t, 320, kcal.Value, 1e-9)
+	for _, symbol := range []string{"calories", "c", "f"} {
+		_, err = quantity.New(1, symbol)
+		assert.Error(t, err, symbol)
+	}
+}
+
+func TestUnitSystems(t *testing.T) {
+	// Test 1: Measurements are converted to the system's display units
+	distance := quantity.Metric.Measurement(models.Measurement{Units: "mi", Qty: 3.1})
+	assert.Equal(t, "km", distance.Units)
+	assert.InDelta(t, 4.989, distance.Qty, 1e-3)
+
+	weight := quantity.Imperial.Measurement(models.Measurement{Units: "kg", Qty: 80})
+	assert.Equal(t, "lb", weight.Units)
+	assert.InDelta(t, 176.37, weight.Qty, 1e-2)
+
+	// Test 2: Measurements in unknown units are left unchanged
+	humidity := models.Measurement{Units: "%", Qty: 60}
+	assert.Equal(t, humidity, quantity.Metric.Measurement(humidity))
+
+	// Test 3: Durations convert like any other dimension
+	sleep := models.Measurement{Units: "hr", Qty: 7.5}
+	assert.Equal(t, models.Measurement{Units: "s", Qty: 27000}, quantity.Metric.Measurement(sleep))
+
+	// Test 4: Values in the wrong dimension are refused
+	_, err := quantity.Metric.Value(&models.Measurement{Units: "kcal", Qty: 300}, quantity.Distance)
+	assert.Error(t, err)
+
+	// Test 5: Unknown systems are rejected, an empty name is imperial
+	_, err = quantity.ParseSystem("nautical")
+	assert.Error(t, err)
+	system, err := quantity.ParseSystem("")
+	assert.NoError(t, err)
+	assert.Equal(t, quantity.Imperial, system)
+}
@@ -0,0 +1,113 @@
+// test/query_test.go
+
+package test
+
+import (
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/query"
+	"fitness/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// queryEnv evaluates expressions in metric units and UTC, on Wednesday 14 August 2024
+func queryEnv() query.Env {
+	zone := models.FixedZone(time.UTC)
+	now := func() time.Time { return time.Date(2024, 8, 14, 12, 0, 0, 0, time.UTC) }
+	week := utils.Bucketer{Period: utils.Week, WeekStart: time.Monday, Zone: zone}
+	return query.Env{Units: quantity.Metric, Zone: zone, Dates: utils.DateResolver{Now: now, Week: week}}
+}
+
+// where compiles an expression and matches a workout against it
+func where(t *testing.T, src string, w models.Workout) bool {
+	expr, err := query.Compile(src, queryEnv())
+	if !assert.NoError(t, err, src) {
+		return false
+	}
+	return expr.MatchWorkout(w)
+}
+
+func TestWhereExpressions(t *testing.T) {
+	park := "Park"
+	run := models.Workout{
+		Name:        "Outdoor Run",
+		Start:       ts("2024-08-10T07:30:00Z"), // A Saturday
+		Duration:    3900,
+		Distance:    &models.Measurement{Qty: 4, Units: "mi"},
+		Temperature: &models.Measurement{Qty: 59, Units: "degF"},
+		Humidity:    &models.Measurement{Qty: 70, Units: "%"},
+		Location:    &park,
+	}
+
+	// Test 1: Boolean operators, parentheses and weekday constants
+	assert.True(t, where(t, `name ~ "run" && (distance > 5 || duration > 1h) && weekday in (sat, sun)`, run))
+	assert.False(t, where(t, `name ~ "swim" || weekday in (mon, tue)`, run))
+	assert.True(t, where(t, `not (distance < 6km) and weekday not in (mon)`, run))
+
+	// Test 2: Units convert, and 45m is minutes for durations but meters for distances
+	assert.True(t, where(t, `distance > 6400m && distance < 4.1mi`, run))
+	assert.True(t, where(t, `duration > 45m && duration >= 1h5m && duration <= 3900`, run))
+	assert.True(t, where(t, `temperature = 15degC && humidity >= 70%`, run))
+
+	// Test 3: Substring and regular expression matching
+	assert.True(t, where(t, `name ~ /^outdoor/i || name ~ /^Outdoor/`, run))
+	assert.True(t, where(t, `location !~ "home" && name !~ /swim/`, run))
+
+	// Test 4: Null checks for optional fields
+	assert.True(t, where(t, `energy == null && location != null`, run))
+	assert.True(t, where(t, `location && !intensity`, run))
+	assert.False(t, where(t, `energy < 100`, run))
+
+	// Test 5: Dates, date functions and calendar parts
+	assert.True(t, where(t, `start in date("last-week") && date = "2024-08-10"`, run))
+	assert.True(t, where(t, `start >= date("2024-08") && start < today() && start > daysago(7)`, run))
+	assert.True(t, where(t, `start < now() && hour == 7 && month = 8 && year = 2024`, run))
+	assert.False(t, where(t, `start > "2024-08-10"`, run))
+
+	// Test 6: Metric data points have a name, value and date
+	expr, err := query.Compile(`name = "weight_body_mass" && value > 80kg && date >= "2024-08"`, queryEnv())
+	assert.NoError(t, err)
+	metric := models.Metric{Name: "weight_body_mass", Units: "lb", Data: []models.MetricData{
+		{Date: ts("2024-07-20T00:00:00Z"), Qty: 190},
+		{Date: ts("2024-08-05T00:00:00Z"), Qty: 185},
+		{Date: ts("2024-08-12T00:00:00Z"), Qty: 170},
+	}}
+	assert.True(t, expr.Match(metric))
+	assert.False(t, expr.MatchPoint(metric, metric.Data[0]))
+	assert.True(t, expr.MatchPoint(metric, metric.Data[1]))
+	assert.False(t, expr.MatchPoint(metric, metric.Data[2]))
+	assert.False(t, expr.Match(run))
+}
+
+func TestWhereErrors(t *testing.T) {
+	cases := map[string]int{
+		`distance > 5km &&`:       18, // Missing operand
+		`(distance > 5`:           14, // Unclosed parenthesis
+		`vo2max > 5`:              1,  // Unknown field
+		`distance > 5kcal`:        12, // Unit of the wrong dimension
+		`name = /run/`:            8,  // Regular expression without ~
+		`duration ~ "1h"`:         10, // Substring match on a number
+		`name ~ "run`:             8,  // Unterminated string
+		`start > date("someday")`: 9,  // Invalid date
+		`distance > 5 # 3`:        14, // Unexpected character
+		`weekday in (sat sun)`:    17, // Missing comma
+	}
+	for src, column := range cases {
+		_, err := query.Compile(src, queryEnv())
+		syntaxErr, ok := err.(*query.SyntaxError)
+		if assert.True(t, ok, src) {
+			assert.Equal(t, column, syntaxErr.Column, src)
+		}
+	}
+
+	// Test 1: The message draws a caret under the column
+	_, err := query.Compile(`vo2max > 5`, queryEnv())
+	assert.Equal(t, "unknown field \"vo2max\" at column 1\n  vo2max > 5\n  ^", err.Error())
+
+	// Test 2: Misspelled fields suggest the closest ones
+	_, err = query.Compile(`distnce > 5`, queryEnv())
+	assert.Contains(t, err.Error(), `unknown field "distnce" (did you mean distance?)`)
+}
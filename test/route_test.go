@@ -0,0 +1,104 @@
+// test/route_test.go
+
+package test
+
+import (
+	"archive/zip"
+	"fitness/data"
+	"fitness/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachRoutes(t *testing.T) {
+	gpx := `<gpx><trk><name>Morning Run</name><trkseg>
+  <trkpt lat="40.7484" lon="-73.9857"><ele>10</ele><time>2024-01-05T07:05:00Z</time></trkpt>
+  <trkpt lat="40.7584" lon="-73.9857"><ele>12</ele><time>2024-01-05T07:20:00Z</time></trkpt>
+</trkseg></trk><trk><trkseg>
+  <trkpt lat="40.0" lon="-74.0"><time>2024-01-09T15:00:00Z</time></trkpt>
+  <trkpt lat="40.1" lon="-74.0"><time>2024-01-09T15:30:00Z</time></trkpt>
+</trkseg></trk></gpx>`
+	tracks, err := data.ParseGPX(strings.NewReader(gpx))
+	assert.NoError(t, err)
+	assert.Len(t, tracks, 2)
+
+	workouts := []models.Workout{
+		{ID: "run", Name: "Outdoor Run", Start: ts("2024-01-05 07:00:00 +0000"), End: ts("2024-01-05 07:30:00 +0000")},
+		{ID: "walk", Name: "Outdoor Walk", Start: ts("2024-01-05 09:00:00 +0000"), End: ts("2024-01-05 09:30:00 +0000")},
+	}
+	attached, standalone := data.AttachRoutes(workouts, tracks)
+
+	// Test 1: The first track is attached to the workout it overlaps
+	assert.Len(t, attached[0].Route, 2)
+	assert.Empty(t, attached[1].Route)
+	assert.Equal(t, 12.0, attached[0].Route[1].Altitude)
+
+	// Test 2: The unmatched track becomes a standalone workout with computed stats
+	assert.Len(t, standalone, 1)
+	assert.Equal(t, "Outdoor Route", standalone[0].Name)
+	assert.Equal(t, 1800.0, standalone[0].Duration)
+	assert.InDelta(t, 6.9, standalone[0].Distance.Qty, 0.05)
+	assert.True(t, standalone[0].RouteOnly)
+
+	// Test 3: Route times keep the offset they were recorded with
+	assert.Equal(t, "2024-01-09T15:00:00Z", standalone[0].Start.Time.Format(time.RFC3339))
+
+	// Test 4: Once the workout is imported, the standalone route is moved onto it and dropped
+	stored := data.MergeWorkouts(nil, append(attached, standalone...), data.MergeNewest)
+	assert.Len(t, stored, 3)
+	later := models.Workout{ID: "ride", Name: "Outdoor Cycling", Start: ts("2024-01-09 09:55:00 -0500"), End: ts("2024-01-09 10:40:00 -0500")}
+	stored = data.MergeWorkouts(stored, []models.Workout{later}, data.MergeNewest)
+	assert.Len(t, stored, 3)
+	assert.Equal(t, "ride", stored[2].ID)
+	assert.Len(t, stored[2].Route, 2)
+	assert.False(t, stored[2].RouteOnly)
+}
+
+func TestAppleExportRoutes(t *testing.T) {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.UTC
+
+	// Write an archive with a workout and its route as a TCX file
+	path := filepath.Join(t.TempDir(), "export.zip")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	archive := zip.NewWriter(file)
+	entries := map[string]string{
+		"apple_health_export/export.xml": `<HealthData>
+ <Workout workoutActivityType="HKWorkoutActivityTypeRunning" duration="30" durationUnit="min" startDate="2024-01-05 07:00:00 +0000" endDate="2024-01-05 07:30:00 +0000"/>
+</HealthData>`,
+		"apple_health_export/workout-routes/route_2024-01-05.tcx": `<TrainingCenterDatabase><Activities><Activity Sport="Running"><Lap><Track>
+  <Trackpoint><Time>2024-01-05T07:05:00Z</Time><Position><LatitudeDegrees>40.7484</LatitudeDegrees><LongitudeDegrees>-73.9857</LongitudeDegrees></Position></Trackpoint>
+  <Trackpoint><Time>2024-01-05T07:20:00Z</Time><Position><LatitudeDegrees>40.7584</LatitudeDegrees><LongitudeDegrees>-73.9857</LongitudeDegrees></Position></Trackpoint>
+</Track></Lap></Activity></Activities></TrainingCenterDatabase>`,
+	}
+	for name, content := range entries {
+		w, err := archive.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, archive.Close())
+	assert.NoError(t, file.Close())
+
+	// Test 1: TCX files in the workout-routes folder are read as TCX and attached
+	export, err := data.ParseAppleExport(path)
+	assert.NoError(t, err)
+	workouts := export.Health.Data.Workouts
+	assert.Len(t, workouts, 1)
+	assert.Len(t, workouts[0].Route, 2)
+
+	// Test 2: A newer version of the workout without a route keeps the route when it is merged
+	newer := workouts[0]
+	newer.Route = nil
+	newer.Duration = 1900
+	merged := data.MergeWorkouts(workouts, []models.Workout{newer}, data.MergeNewest)
+	assert.Len(t, merged, 1)
+	assert.Equal(t, 1900.0, merged[0].Duration)
+	assert.Len(t, merged[0].Route, 2)
+}
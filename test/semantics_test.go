@@ -0,0 +1,123 @@
+// test/semantics_test.go
+
+package test
+
+import (
+	"fitness/config"
+	"fitness/fields"
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/semantics"
+	"fitness/stats"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricSemantics(t *testing.T) {
+	// Test 1: Known metrics have an aggregation, units, a display name and a valid range
+	steps := semantics.Builtin.Info("step_count")
+	assert.True(t, steps.Known)
+	assert.Equal(t, semantics.Sum, steps.Aggregation)
+	assert.Equal(t, semantics.Latest, semantics.Builtin.Info("Weight_Body_Mass").Aggregation)
+	assert.Equal(t, "Resting Heart Rate", semantics.Builtin.Info("resting_heart_rate").Display)
+	assert.Equal(t, "20..250 count/min", semantics.Builtin.Info("heart_rate").Range())
+
+	// Test 2: Unknown metrics are averaged and named after their name
+	unknown := semantics.Builtin.Info("dietary_caffeine")
+	assert.False(t, unknown.Known)
+	assert.Equal(t, semantics.Mean, unknown.Aggregation)
+	assert.Equal(t, "Dietary Caffeine", unknown.Display)
+
+	// Test 3: Values convert to the canonical units to check the valid range, unconvertible ones pass
+	weight := semantics.Builtin.Info("weight_body_mass")
+	assert.InDelta(t, 81.65, weight.Canonical(models.Measurement{Qty: 180, Units: "lb"}).Qty, 1e-2)
+	assert.True(t, weight.Valid(models.Measurement{Qty: 180, Units: "lb"}))
+	assert.False(t, weight.Valid(models.Measurement{Qty: 5, Units: "kg"}))
+	assert.True(t, weight.Valid(models.Measurement{Qty: 5, Units: "stone"}))
+	assert.False(t, semantics.Builtin.Info("heart_rate").Valid(models.Measurement{Qty: 0, Units: "count/min"}))
+
+	// Test 4: Values display in the system's units, durations stay in the canonical units
+	assert.InDelta(t, 180, weight.InSystem(models.Measurement{Qty: 81.65, Units: "kg"}, quantity.Imperial).Qty, 1e-1)
+	sleep := semantics.Builtin.Info("sleep_analysis")
+	assert.Equal(t, models.Measurement{Qty: 7.5, Units: "hr"}, sleep.InSystem(models.Measurement{Qty: 450, Units: "min"}, quantity.Metric))
+}
+
+func TestMetricSemanticsConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"units": "metric", "metrics": {"heart_rate": {"max": 200}, "dietary_caffeine": {"name": "Caffeine", "aggregation": "sum", "units": "mg"}}}`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	// Test 1: Definitions in the config file add to the built-in ones and override single properties
+	cfg, err := config.Load(path)
+	assert.NoError(t, err)
+	registry, err := semantics.Builtin.Extend(cfg.Metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, "20..200 count/min", registry.Info("heart_rate").Range())
+	assert.Equal(t, semantics.Mean, registry.Info("heart_rate").Aggregation)
+	caffeine := registry.Info("dietary_caffeine")
+	assert.True(t, caffeine.Known)
+	assert.Equal(t, "Caffeine", caffeine.Display)
+	assert.Equal(t, semantics.Sum, caffeine.Aggregation)
+
+	// Test 2: The built-in registry is left as it was
+	assert.Equal(t, "20..250 count/min", semantics.Builtin.Info("heart_rate").Range())
+	assert.False(t, semantics.Builtin.Info("dietary_caffeine").Known)
+
+	// Test 3: Unknown aggregations, empty ranges and unknown properties are rejected
+	_, err = semantics.Builtin.Extend(map[string]config.MetricSpec{"x": {Aggregation: "median"}})
+	assert.ErrorContains(t, err, `unknown aggregation "median"`)
+	low, high := 10.0, 5.0
+	_, err = semantics.Builtin.Extend(map[string]config.MetricSpec{"x": {Min: &low, Max: &high}})
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(path, []byte(`{"metrics": {"x": {"aggregate": "sum"}}}`), 0644))
+	_, err = config.Load(path)
+	assert.Error(t, err)
+}
+
+func TestMetricRollups(t *testing.T) {
+	metrics := []models.Metric{
+		{Name: "step_count", Units: "count", Data: []models.MetricData{
+			{Date: ts("2024-07-01T00:00:00Z"), Qty: 9000},
+			{Date: ts("2024-07-02T00:00:00Z"), Qty: 11000},
+		}},
+		{Name: "heart_rate", Units: "count/min", Data: []models.MetricData{
+			{Date: ts("2024-07-01T00:00:00Z"), Qty: 70},
+			{Date: ts("2024-07-02T00:00:00Z"), Qty: 0},
+			{Date: ts("2024-07-03T00:00:00Z"), Qty: 80},
+		}},
+		{Name: "weight_body_mass", Units: "lb", Data: []models.MetricData{
+			{Date: ts("2024-07-05T00:00:00Z"), Qty: 178},
+			{Date: ts("2024-07-01T00:00:00Z"), Qty: 180},
+		}},
+		{Name: "sleep_analysis", Units: "hr", Data: []models.MetricData{
+			{Date: ts("2024-07-01T07:00:00Z"), Qty: 7},
+			{Date: ts("2024-07-01T15:00:00Z"), Qty: 1},
+			{Date: ts("2024-07-02T07:00:00Z"), Qty: 6},
+		}},
+	}
+	report, err := stats.ParseReport("name,week", "value", fields.Points, statsWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"value:auto"}, report.Group(nil, fields.Context{}).Measures)
+
+	// Test 1: A bare value rolls up each metric its own way: steps add up, heart rates average without
+	// the reading out of range, the latest weight stands and sleep adds up per night before averaging
+	ctx := fields.Context{Units: quantity.Metric, Zone: utc}
+	assert.Equal(t, [][]string{
+		{"heart_rate", "2024-07-01..2024-07-07", "75.00"},
+		{"sleep_analysis", "2024-07-01..2024-07-07", "7.00"},
+		{"step_count", "2024-07-01..2024-07-07", "20000.00"},
+		{"weight_body_mass", "2024-07-01..2024-07-07", "80.74"},
+	}, rowText(report.Group(fields.PointsOf(metrics), ctx)))
+
+	// Test 2: Values of different metrics do not combine
+	report, err = stats.ParseReport("week", "value", fields.Points, statsWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"2024-07-01..2024-07-07", "-"}}, rowText(report.Group(fields.PointsOf(metrics), ctx)))
+
+	// Test 3: Only fields that know how to add up roll up
+	_, err = stats.ParseMeasures("distance:auto", fields.Workouts)
+	assert.ErrorContains(t, err, "distance:sum")
+}
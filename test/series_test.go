@@ -0,0 +1,244 @@
+// test/series_test.go
+
+package test
+
+import (
+	"encoding/json"
+	"fitness/data"
+	"fitness/fields"
+	"fitness/models"
+	"fitness/quantity"
+	"fitness/query"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seriesWorkout is a half-hour run with heart rate, recovery, step and elevation series
+func seriesWorkout(t *testing.T) models.Workout {
+	input := `{"id": "4F1A2B3C-0000-4000-8000-000000000001", "name": "Outdoor Run",
+		"start": "2024-03-01 07:00:00 -0500", "end": "2024-03-01 07:30:00 -0500", "duration": 1800,
+		"heartRateData": [
+			{"date": "2024-03-01 07:00:00 -0500", "Min": 95, "Avg": 120, "Max": 131, "units": "count/min"},
+			{"date": "2024-03-01 07:15:00 -0500", "Min": 140, "Avg": 150, "Max": 168, "units": "count/min"},
+			{"date": "2024-03-01 07:29:00 -0500", "Min": 150, "Avg": 160, "Max": 172, "units": "count/min"}
+		],
+		"heartRateRecovery": [
+			{"date": "2024-03-01 07:30:00 -0500", "Avg": 160, "units": "count/min"},
+			{"date": "2024-03-01 07:32:00 -0500", "Avg": 125, "units": "count/min"}
+		],
+		"stepCount": [
+			{"date": "2024-03-01 07:00:00 -0500", "qty": 2500, "units": "count"},
+			{"date": "2024-03-01 07:15:00 -0500", "qty": 2600, "units": "count"}
+		],
+		"elevationUp": {"qty": 42.5, "units": "m"}}`
+	var w models.Workout
+	assert.NoError(t, json.Unmarshal([]byte(input), &w))
+	return w
+}
+
+func TestWorkoutSeriesJSON(t *testing.T) {
+	w := seriesWorkout(t)
+
+	// Test 1: Series are read with their units, a single reading such as the elevation is a series of one
+	assert.Len(t, w.HeartRateData, 3)
+	assert.Equal(t, 120.0, w.HeartRateData[0].Qty)
+	assert.Equal(t, "count/min", w.HeartRateData[0].Units)
+	assert.Len(t, w.StepCount, 2)
+	assert.Equal(t, models.Samples{{Qty: 42.5, Units: "m"}}, w.ElevationUp)
+
+	// Test 2: Series survive a round trip through the cache format, workouts without them write none
+	encoded, err := json.Marshal(w)
+	assert.NoError(t, err)
+	var decoded models.Workout
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, w.Series(), decoded.Series())
+	encoded, err = json.Marshal(models.Workout{Name: "Yoga"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encoded), "heartRateData")
+}
+
+func TestWorkoutSeriesFields(t *testing.T) {
+	w := seriesWorkout(t)
+	value := func(name string, ctx fields.Context) string {
+		f, _ := fields.Workouts.Lookup(name)
+		return f.String(f.Get(w, ctx), ctx)
+	}
+	metric := fields.Context{Units: quantity.Metric, Zone: utc}
+	imperial := fields.Context{Units: quantity.Imperial, Zone: utc}
+
+	// Test 1: Heart rates are summarized from the readings, the maximum from their peaks
+	assert.Equal(t, "143 bpm", value("heartrate", metric))
+	assert.Equal(t, "172 bpm", value("maxhr", metric))
+	assert.Equal(t, "35 bpm", value("recovery", metric))
+
+	// Test 2: Elevation is shown in meters or feet, cadence is steps per minute
+	assert.Equal(t, "42 m", value("elevation", metric))
+	assert.Equal(t, "139 ft", value("elevation", imperial))
+	assert.Equal(t, "5100", value("steps", metric))
+	assert.Equal(t, "170 spm", value("cadence", metric))
+
+	// Test 3: Workouts without series have no value
+	w = models.Workout{Name: "Yoga", Duration: 1800}
+	for _, name := range []string{"heartrate", "maxhr", "recovery", "elevation", "steps", "cadence"} {
+		assert.Equal(t, "-", value(name, metric), name)
+	}
+}
+
+func TestElevationFilters(t *testing.T) {
+	w := seriesWorkout(t)
+	filter := func(spec string, system quantity.System) bool {
+		c, err := data.ParseCondition(spec, system, utc)
+		assert.NoError(t, err, spec)
+		return c.Match(w)
+	}
+	imperial := queryEnv()
+	imperial.Units = quantity.Imperial
+
+	// Test 1: Filters read a bare elevation in meters or feet, not in the units of distances
+	assert.True(t, filter("elevation>40", quantity.Metric))
+	assert.False(t, filter("elevation>50", quantity.Metric))
+	assert.True(t, filter("elevation>130", quantity.Imperial))
+	assert.False(t, filter("elevation>140", quantity.Imperial))
+	assert.True(t, filter("climb<0.1km", quantity.Imperial))
+
+	// Test 2: Expressions read a bare elevation the same way
+	assert.True(t, where(t, "elevation > 40 && elevation < 50", w))
+	expr, err := query.Compile("elevation > 130 && elevation < 140", imperial)
+	assert.NoError(t, err)
+	assert.True(t, expr.MatchWorkout(w))
+}
+
+func TestWorkoutSeriesMerge(t *testing.T) {
+	full := seriesWorkout(t)
+	bare := full
+	bare.SetSeries(models.WorkoutSeries{})
+	bare.Duration = 1900
+
+	// Test 1: A winning version without series keeps the series of the other
+	merged := data.MergeWorkouts([]models.Workout{full}, []models.Workout{bare}, data.MergeNewest)
+	assert.Len(t, merged, 1)
+	assert.Equal(t, 1900.0, merged[0].Duration)
+	assert.Equal(t, full.Series(), merged[0].Series())
+
+	// Test 2: Workouts are found by ID, ignoring case, or by the start of a single ID
+	other := models.Workout{ID: "4F1A9999-0000-4000-8000-000000000002", Name: "Pool Swim"}
+	workouts := []models.Workout{full, other}
+	found, err := data.FindWorkout(workouts, "4f1a2b")
+	assert.NoError(t, err)
+	assert.Equal(t, full.ID, found.ID)
+	_, err = data.FindWorkout(workouts, "4F1A")
+	assert.ErrorContains(t, err, "matches 2 workouts")
+	_, err = data.FindWorkout(workouts, "zz")
+	assert.EqualError(t, err, `no workout with id "zz"`)
+}
+
+func TestSeriesFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+	seriesPath := filepath.Join(dir, "series.json")
+
+	store := data.NewFileStore(path, data.MergeNewest)
+	store.SeriesPath = seriesPath
+	assert.NoError(t, store.Load())
+	store.UpsertWorkouts([]models.Workout{seriesWorkout(t), newWorkout("b", "Yoga", "2024-03-02 07:00:00 +0000")})
+	assert.NoError(t, store.Save())
+
+	// Test 1: The series go to the side file and not the cache
+	cache, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(cache), "heartRateData")
+	series, err := data.ReadSeriesFile(seriesPath)
+	assert.NoError(t, err)
+	assert.Len(t, series, 1)
+
+	// Test 2: Loading puts the series back on their workouts
+	reloaded := data.NewFileStore(path, data.MergeNewest)
+	reloaded.SeriesPath = seriesPath
+	assert.NoError(t, reloaded.Load())
+	found, err := data.FindWorkout(reloaded.Workouts(data.AllTime), "4f1a")
+	assert.NoError(t, err)
+	assert.Equal(t, seriesWorkout(t).Series(), found.Series())
+	assert.False(t, reloaded.Changed())
+
+	// Test 3: A corrupted series file is moved aside and the workouts load without series
+	assert.NoError(t, os.WriteFile(seriesPath, []byte("{bad"), 0644))
+	reloaded = data.NewFileStore(path, data.MergeNewest)
+	reloaded.SeriesPath = seriesPath
+	assert.NoError(t, reloaded.Load())
+	assert.Len(t, reloaded.Workouts(data.AllTime), 2)
+	found, err = data.FindWorkout(reloaded.Workouts(data.AllTime), "4f1a")
+	assert.NoError(t, err)
+	assert.True(t, found.Series().IsEmpty())
+	corrupt, err := os.ReadFile(data.CorruptSeriesPath(seriesPath, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, "{bad", string(corrupt))
+	_, err = os.Stat(seriesPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSeriesFileSafety(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+	seriesPath := filepath.Join(dir, "series.json")
+	load := func(seriesPath string) *data.FileStore {
+		store := data.NewFileStore(path, data.MergeNewest)
+		store.SeriesPath = seriesPath
+		assert.NoError(t, store.Load())
+		return store
+	}
+	save := func(store *data.FileStore) {
+		captureStdout(t, func() { assert.NoError(t, store.Save()) })
+	}
+
+	store := load(seriesPath)
+	store.UpsertWorkouts([]models.Workout{seriesWorkout(t)})
+	save(store)
+
+	// Test 1: The cache records the series file, and a load without it warns that the series are missing
+	var cache models.HealthData
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(content, &cache))
+	assert.Equal(t, seriesPath, cache.SeriesFile)
+	var unset *data.FileStore
+	stderr := captureStderr(t, func() { unset = load("") })
+	assert.Contains(t, stderr, "workout series were saved to "+seriesPath)
+
+	// Test 2: Saving without the series file keeps the record, so the warning stays
+	unset.UpsertWorkouts([]models.Workout{newWorkout("b", "Yoga", "2024-03-02 07:00:00 +0000")})
+	save(unset)
+	stderr = captureStderr(t, func() { load("") })
+	assert.Contains(t, stderr, "workout series were saved to "+seriesPath)
+	assert.Empty(t, captureStderr(t, func() { load(seriesPath) }))
+
+	// Test 3: Saving keeps the previous series file as a backup
+	save(load(seriesPath))
+	backup, err := os.ReadFile(data.BackupPath(seriesPath, 1))
+	assert.NoError(t, err)
+	current, err := os.ReadFile(seriesPath)
+	assert.NoError(t, err)
+	assert.Equal(t, current, backup)
+
+	// Test 4: A corrupted series file is recovered from its backup
+	assert.NoError(t, os.WriteFile(seriesPath, []byte("{bad"), 0644))
+	var recovered *data.FileStore
+	stderr = captureStderr(t, func() { recovered = load(seriesPath) })
+	assert.Contains(t, stderr, "Recovered series from backup")
+	found, err := data.FindWorkout(recovered.Workouts(data.AllTime), "4f1a")
+	assert.NoError(t, err)
+	assert.Equal(t, seriesWorkout(t).Series(), found.Series())
+
+	// Test 5: A later corrupted series file does not overwrite an earlier one
+	assert.NoError(t, os.WriteFile(seriesPath, []byte("{worse"), 0644))
+	captureStderr(t, func() { load(seriesPath) })
+	first, err := os.ReadFile(data.CorruptSeriesPath(seriesPath, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, "{bad", string(first))
+	second, err := os.ReadFile(data.CorruptSeriesPath(seriesPath, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, "{worse", string(second))
+}
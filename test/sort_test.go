@@ -0,0 +1,121 @@
+// test/sort_test.go
+
+package test
+
+import (
+	"fitness/models"
+	"fitness/printer"
+	"fitness/stats"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// workoutIDs lists the IDs of workouts in order
+func workoutIDs(workouts []models.Workout) []string {
+	var ids []string
+	for _, w := range workouts {
+		ids = append(ids, w.ID)
+	}
+	return ids
+}
+
+// sortOptions returns print options sorting by the given fields
+func sortOptions(sortBy string, desc bool) printer.PrintOptions {
+	opts := printer.DefaultPrintOptions()
+	opts.SortBy = sortBy
+	opts.SortDesc = desc
+	return opts
+}
+
+func TestSortWorkouts(t *testing.T) {
+	workouts := []models.Workout{
+		{ID: "1", Name: "Run", Duration: 1800, Distance: &models.Measurement{Qty: 5, Units: "km"}},
+		{ID: "2", Name: "Swim", Duration: 1800},
+		{ID: "3", Name: "run", Duration: 2400, Distance: &models.Measurement{Qty: 4, Units: "mi"}},
+		{ID: "4", Name: "Ride", Duration: 3600, Distance: &models.Measurement{Qty: 20, Units: "km"}},
+		{ID: "5", Name: "Run", Duration: 1200, Distance: &models.Measurement{Qty: 3, Units: "km"}},
+	}
+
+	// Test 1: Several fields, the second breaking ties of the first, with distances compared across units
+	sorted, err := printer.SortWorkouts(workouts, sortOptions("name,-distance", false))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4", "3", "1", "5", "2"}, workoutIDs(sorted))
+
+	// Test 2: Workouts without a distance come last in both directions
+	sorted, err = printer.SortWorkouts(workouts, sortOptions("distance", false))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5", "1", "3", "4", "2"}, workoutIDs(sorted))
+	sorted, err = printer.SortWorkouts(workouts, sortOptions("distance", true))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4", "3", "1", "5", "2"}, workoutIDs(sorted))
+
+	// Test 3: Equal workouts keep their order
+	sorted, err = printer.SortWorkouts(workouts, sortOptions("duration", false))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5", "1", "2", "3", "4"}, workoutIDs(sorted))
+
+	// Test 4: The input is left untouched
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, workoutIDs(workouts))
+
+	// Test 5: Unknown fields are rejected
+	_, err = printer.SortWorkouts(workouts, sortOptions("name,vo2max", false))
+	assert.Error(t, err)
+}
+
+func TestSortMetricsAndTotals(t *testing.T) {
+	metrics := []models.Metric{
+		{Name: "step_count", Units: "count", Data: []models.MetricData{{Qty: 9000}, {Qty: 4000}}},
+		{Name: "weight_body_mass", Units: "lb", Data: []models.MetricData{{Qty: 180}}},
+		{Name: "resting_heart_rate", Units: "count/min"},
+	}
+
+	// Test 1: Metrics sort by their latest value, metrics without data last
+	sorted, err := printer.SortMetrics(metrics, sortOptions("-latest", false))
+	assert.NoError(t, err)
+	assert.Equal(t, "step_count", sorted[0].Name)
+	assert.Equal(t, "weight_body_mass", sorted[1].Name)
+	assert.Equal(t, "resting_heart_rate", sorted[2].Name)
+
+	// Test 2: Metrics sort by their number of data points
+	sorted, err = printer.SortMetrics(metrics, sortOptions("points", true))
+	assert.NoError(t, err)
+	assert.Equal(t, "step_count", sorted[0].Name)
+
+	// Test 3: start is the date of the first data point of a metric, date the date of its last
+	spans := []models.Metric{
+		{Name: "short", Data: []models.MetricData{{Date: ts("2024-03-01 00:00:00 +0000")}, {Date: ts("2024-03-02 00:00:00 +0000")}}},
+		{Name: "long", Data: []models.MetricData{{Date: ts("2024-01-01 00:00:00 +0000")}, {Date: ts("2024-04-01 00:00:00 +0000")}}},
+	}
+	sorted, err = printer.SortMetrics(spans, sortOptions("start", false))
+	assert.NoError(t, err)
+	assert.Equal(t, "long", sorted[0].Name)
+	sorted, err = printer.SortMetrics(spans, sortOptions("date", false))
+	assert.NoError(t, err)
+	assert.Equal(t, "short", sorted[0].Name)
+
+	// Test 4: Grouped rows sort by key by default and by value on request
+	table := stats.Table{Dimensions: []string{"month"}, Measures: []string{"distance:sum"}, Rows: []stats.Row{
+		{Keys: []string{"2024-01"}, Values: []float64{12.5}, Valid: []bool{true}},
+		{Keys: []string{"2024-02"}, Values: []float64{30}, Valid: []bool{true}},
+		{Keys: []string{"2024-03"}, Values: []float64{7}, Valid: []bool{true}},
+	}}
+	rowKeys := func(table stats.Table) []string {
+		var keys []string
+		for _, row := range table.Rows {
+			keys = append(keys, row.Keys[0])
+		}
+		return keys
+	}
+	sortedTable, err := printer.SortStats(table, sortOptions("", false))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024-01", "2024-02", "2024-03"}, rowKeys(sortedTable))
+	sortedTable, err = printer.SortStats(table, sortOptions("-value", false))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024-02", "2024-01", "2024-03"}, rowKeys(sortedTable))
+
+	// Test 5: -desc alone reverses the default order of the rows
+	sortedTable, err = printer.SortStats(table, sortOptions("", true))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024-03", "2024-02", "2024-01"}, rowKeys(sortedTable))
+}
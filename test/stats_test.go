@@ -0,0 +1,252 @@
+// test/stats_test.go
+
+package test
+
+import (
+	"fitness/fields"
+	"fitness/models"
+	"fitness/printer"
+	"fitness/quantity"
+	"fitness/stats"
+	"fitness/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// statsWeek buckets weeks from Monday in UTC
+var statsWeek = utils.Bucketer{Period: utils.Week, WeekStart: time.Monday, Zone: utc}
+
+// groupWorkouts groups the workouts by the dimensions and measures, failing the test on errors
+func groupWorkouts(t *testing.T, workouts []models.Workout, by, measure string) stats.Table {
+	dimensions, err := stats.ParseDimensions(by, fields.Workouts, statsWeek)
+	assert.NoError(t, err)
+	measures, err := stats.ParseMeasures(measure, fields.Workouts)
+	assert.NoError(t, err)
+	ctx := fields.Context{Units: quantity.Metric, Zone: utc}
+	return stats.Group(workouts, dimensions, measures, ctx)
+}
+
+// rowText formats every row of a table as its keys followed by its measures
+func rowText(table stats.Table) [][]string {
+	var rows [][]string
+	for _, row := range table.Rows {
+		cells := append([]string(nil), row.Keys...)
+		for i := range table.Measures {
+			cells = append(cells, table.Format(row, i))
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+func TestStatCompute(t *testing.T) {
+	values := []float64{4, 1, 3, 2, 10}
+	compute := func(name string, values []float64) (float64, bool) {
+		stat, err := stats.ParseStat(name)
+		assert.NoError(t, err)
+		return stat.Compute(values)
+	}
+
+	// Test 1: Order statistics interpolate between the closest values
+	median, _ := compute("median", values)
+	assert.Equal(t, 3.0, median)
+	p90, _ := compute("p90", values)
+	assert.InDelta(t, 7.6, p90, 1e-9)
+
+	// Test 2: Aliases and the population standard deviation
+	mean, _ := compute("avg", values)
+	assert.Equal(t, 4.0, mean)
+	stddev, _ := compute("std", []float64{2, 4, 4, 4, 5, 5, 7, 9})
+	assert.Equal(t, 2.0, stddev)
+
+	// Test 3: Count and sum of nothing are zero, other statistics have no value
+	total, ok := compute("sum", nil)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, total)
+	_, ok = compute("mean", nil)
+	assert.False(t, ok)
+
+	// Test 4: Unknown statistics and percentiles out of range are rejected
+	_, err := stats.ParseStat("mode")
+	assert.ErrorContains(t, err, `unknown statistic "mode"`)
+	_, err = stats.ParseStat("p100")
+	assert.Error(t, err)
+}
+
+func TestGroupWorkouts(t *testing.T) {
+	location := "Park"
+	workouts := []models.Workout{
+		{Name: "Run", Start: ts("2024-07-01T07:00:00Z"), Duration: 1800, Distance: &models.Measurement{Qty: 5, Units: "km"}, Tags: []string{"easy"}},
+		{Name: "Run", Start: ts("2024-07-20T07:00:00Z"), Duration: 2400, Distance: &models.Measurement{Qty: 5, Units: "mi"}, Tags: []string{"long", "easy"}},
+		{Name: "Swim", Start: ts("2024-07-03T07:00:00Z"), Duration: 1200, Location: &location},
+		{Name: "Run", Start: ts("2024-08-04T07:00:00Z"), Duration: 3000, Distance: &models.Measurement{Qty: 10, Units: "km"}},
+	}
+
+	// Test 1: Rows for every combination of keys, measured in the display units
+	table := groupWorkouts(t, workouts, "name,month", "distance:sum,duration:mean")
+	assert.Equal(t, []string{"name", "month"}, table.Dimensions)
+	assert.Equal(t, []string{"distance:sum", "duration:mean"}, table.Measures)
+	assert.Equal(t, [][]string{
+		{"Run", "2024-07", "13.05 km", "35:00"},
+		{"Run", "2024-08", "10.00 km", "50:00"},
+		{"Swim", "2024-07", "0.00 km", "20:00"},
+	}, rowText(table))
+
+	// Test 2: Workouts fall in a group per tag, untagged ones under (none) which sorts last
+	table = groupWorkouts(t, workouts, "tag", "count,distance:max")
+	assert.Equal(t, [][]string{
+		{"easy", "2", "8.05 km"},
+		{"long", "1", "8.05 km"},
+		{"(none)", "2", "10.00 km"},
+	}, rowText(table))
+
+	// Test 3: Weekdays are ordered from the first day of the week
+	table = groupWorkouts(t, workouts, "weekday", "count")
+	assert.Equal(t, [][]string{{"monday", "1"}, {"wednesday", "1"}, {"saturday", "1"}, {"sunday", "1"}}, rowText(table))
+
+	// Test 4: Statistics without values are shown as -
+	table = groupWorkouts(t, workouts, "location", "distance:mean")
+	assert.Equal(t, [][]string{{"Park", "-"}, {"(none)", "7.68 km"}}, rowText(table))
+
+	// Test 5: Sorting by value puts the largest first with -value
+	table = groupWorkouts(t, workouts, "name", "duration:sum")
+	opts := printer.DefaultPrintOptions()
+	opts.SortBy = "-value"
+	sorted, err := printer.SortStats(table, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"Run", "120:00"}, {"Swim", "20:00"}}, rowText(sorted))
+}
+
+func TestParseStatsErrors(t *testing.T) {
+	// Test 1: Unknown dimensions suggest close fields and periods
+	_, err := stats.ParseDimensions("locaton", fields.Workouts, statsWeek)
+	assert.EqualError(t, err, `unknown dimension "locaton" (did you mean location?)`)
+	_, err = stats.ParseDimensions("mnth", fields.Workouts, statsWeek)
+	assert.ErrorContains(t, err, "did you mean month?")
+
+	// Test 2: Times and quantities cannot be grouped by directly
+	_, err = stats.ParseDimensions("start", fields.Workouts, statsWeek)
+	assert.ErrorContains(t, err, "cannot group by start")
+
+	// Test 3: Only number fields can be measured
+	_, err = stats.ParseMeasures("name:sum", fields.Workouts)
+	assert.ErrorContains(t, err, "cannot measure name")
+	_, err = stats.ParseMeasures("vo2max:mean", fields.Workouts)
+	assert.ErrorContains(t, err, `unknown measure field "vo2max"`)
+	_, err = stats.ParseMeasures(" ", fields.Workouts)
+	assert.Error(t, err)
+}
+
+func TestReportsOverSelection(t *testing.T) {
+	workouts := []models.Workout{
+		{ID: "1", Name: "Run", Start: ts("2024-07-01T07:00:00Z"), Distance: &models.Measurement{Qty: 5, Units: "km"}},
+		{ID: "2", Name: "Swim", Start: ts("2024-07-02T07:00:00Z"), Distance: &models.Measurement{Qty: 1, Units: "km"}},
+		{ID: "3", Name: "Run", Start: ts("2024-07-09T07:00:00Z"), Distance: &models.Measurement{Qty: 8, Units: "km"}},
+	}
+	opts := printer.DefaultPrintOptions()
+	opts.Units = quantity.Metric
+	opts.Filter = func(v interface{}) bool { return v.(models.Workout).Name == "Run" }
+	ctx := fields.Context{Units: quantity.Metric, Zone: utc}
+
+	// Test 1: Reports cover the workouts the filter selects
+	selected := printer.SelectWorkouts(workouts, opts)
+	assert.Equal(t, []string{"1", "3"}, workoutIDs(selected))
+	report, err := stats.ParseReport(stats.DistancePerWeek.By, stats.DistancePerWeek.Measure, fields.Workouts, statsWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"2024-07-01..2024-07-07", "5.00 km"}, {"2024-07-08..2024-07-14", "8.00 km"}}, rowText(report.Group(selected, ctx)))
+
+	// Test 2: Metrics keep the data points the point filter selects, then the metrics the filter selects
+	rest := models.Metric{Name: "resting_heart_rate", Units: "count/min", Data: []models.MetricData{
+		{Date: ts("2024-07-01T00:00:00Z"), Qty: 50},
+		{Date: ts("2024-07-03T00:00:00Z"), Qty: 54},
+		{Date: ts("2024-07-09T00:00:00Z"), Qty: 80},
+		{Date: ts("2024-07-10T00:00:00Z"), Qty: 48},
+	}}
+	steps := models.Metric{Name: "step_count", Units: "count", Data: []models.MetricData{{Date: ts("2024-07-01T00:00:00Z"), Qty: 9000}}}
+	opts.PointFilter = func(_ models.Metric, d models.MetricData) bool { return d.Qty < 70 }
+	opts.Filter = func(v interface{}) bool { return v.(models.Metric).Name == "resting_heart_rate" }
+	metrics := printer.SelectMetrics([]models.Metric{rest, steps}, opts)
+	assert.Len(t, metrics, 1)
+	assert.Len(t, metrics[0].Data, 3)
+
+	// Test 3: Data points group like workouts, e.g. the mean resting heart rate per week
+	points, err := stats.ParseReport("name,week", "value:mean,count", fields.Points, statsWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"resting_heart_rate", "2024-07-01..2024-07-07", "52.00", "2"},
+		{"resting_heart_rate", "2024-07-08..2024-07-14", "48.00", "1"},
+	}, rowText(points.Group(fields.PointsOf(metrics), ctx)))
+}
+
+func TestPresetReportOptions(t *testing.T) {
+	workouts := []models.Workout{
+		{ID: "1", Name: "Run", Start: ts("2024-07-01T07:00:00Z"), Distance: &models.Measurement{Qty: 5, Units: "km"}},
+		{ID: "2", Name: "Run", Start: ts("2024-07-09T07:00:00Z"), Distance: &models.Measurement{Qty: 8, Units: "km"}},
+		{ID: "3", Name: "Run", Start: ts("2024-07-16T07:00:00Z"), Distance: &models.Measurement{Qty: 3, Units: "km"}},
+	}
+	report := func(maxItems int, sortBy string, desc bool) []string {
+		opts := printer.DefaultPrintOptions()
+		opts.Units = quantity.Metric
+		opts.Zone = utc
+		opts.Week = statsWeek
+		opts.MaxItems, opts.SortBy, opts.SortDesc = maxItems, sortBy, desc
+		out := captureStdout(t, func() { printer.PrintDistancePerWeek(workouts, opts) })
+		var weeks []string
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, "2024-") {
+				weeks = append(weeks, line[:10])
+			}
+		}
+		return weeks
+	}
+
+	// Test 1: -n limits the reports as it limits the listing
+	assert.Equal(t, []string{"2024-07-01", "2024-07-08", "2024-07-15"}, report(0, "", false))
+	assert.Equal(t, []string{"2024-07-01"}, report(1, "", false))
+
+	// Test 2: Listing sort fields leave the reports in key order, which -desc reverses
+	assert.Equal(t, []string{"2024-07-01", "2024-07-08", "2024-07-15"}, report(0, "distance", false))
+	assert.Equal(t, []string{"2024-07-15", "2024-07-08", "2024-07-01"}, report(0, "distance", true))
+	assert.Equal(t, []string{"2024-07-15", "2024-07-08"}, report(2, "", true))
+
+	// Test 3: Sorting by value orders the reports
+	assert.Equal(t, []string{"2024-07-08", "2024-07-01", "2024-07-15"}, report(0, "distance,-value", false))
+}
+
+func TestMetricPresetReports(t *testing.T) {
+	metrics := []models.Metric{
+		{Name: "walking_running_distance", Units: "km", Data: []models.MetricData{
+			{Date: ts("2024-07-01T00:00:00Z"), Qty: 4},
+			{Date: ts("2024-07-02T00:00:00Z"), Qty: 6},
+			{Date: ts("2024-07-09T00:00:00Z"), Qty: 3},
+		}},
+		{Name: "weight_body_mass", Units: "kg", Data: []models.MetricData{{Date: ts("2024-07-01T00:00:00Z"), Qty: 80}}},
+	}
+	report := func(configure func(*printer.PrintOptions)) string {
+		opts := printer.DefaultPrintOptions()
+		opts.Units = quantity.Metric
+		opts.Zone = utc
+		opts.Week = statsWeek
+		opts.ReportsOnly = true
+		configure(&opts)
+		return captureStdout(t, func() { assert.NoError(t, printer.PrintMetrics(metrics, opts)) })
+	}
+
+	// Test 1: Distance per week adds up the distance metrics of each week, other metrics are left out
+	out := report(func(opts *printer.PrintOptions) { opts.DistancePerWeek = true })
+	assert.Regexp(t, `walking_running_distance +2024-07-01\.\.2024-07-07 +10\.00`, out)
+	assert.Regexp(t, `walking_running_distance +2024-07-08\.\.2024-07-14 +3\.00`, out)
+	assert.NotContains(t, out, "weight_body_mass")
+	assert.NotContains(t, out, "Metric:")
+
+	// Test 2: The monthly report counts the data points of every selected metric
+	out = report(func(opts *printer.PrintOptions) {
+		opts.WorkoutsPerMonth = true
+		opts.PointFilter = func(_ models.Metric, d models.MetricData) bool { return d.Qty < 50 }
+	})
+	assert.Regexp(t, `walking_running_distance +2024-07 +3`, out)
+	assert.NotContains(t, out, "weight_body_mass")
+}
@@ -0,0 +1,78 @@
+// test/store_test.go
+
+package test
+
+import (
+	"fitness/data"
+	"fitness/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newWorkout builds a workout starting at the given time
+func newWorkout(id, name, start string) models.Workout {
+	return models.Workout{ID: id, Name: name, Start: ts(start), End: ts(start), Duration: 1800}
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+	store := data.NewMemoryStore(data.MergeNewest)
+
+	store.UpsertWorkouts([]models.Workout{
+		newWorkout("a", "Outdoor Run", "2024-01-01 07:00:00 +0000"),
+		newWorkout("b", "Pool Swim", "2024-01-08 07:00:00 +0000"),
+		newWorkout("c", "Outdoor Walk", "2024-01-15 07:00:00 +0000"),
+	})
+	store.UpsertMetrics([]models.Metric{{Name: "step_count", Units: "count", Data: []models.MetricData{
+		{Date: ts("2024-01-01 00:00:00 +0000"), Qty: 8000},
+		{Date: ts("2024-01-09 00:00:00 +0000"), Qty: 9000},
+	}}})
+
+	// Test 1: Querying by time range selects workouts and metric data points
+	r := data.TimeRange{
+		From: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+	}
+	workouts := store.Workouts(r)
+	assert.Len(t, workouts, 1)
+	assert.Equal(t, "b", workouts[0].ID)
+	metrics := store.Metrics(r)
+	assert.Len(t, metrics, 1)
+	assert.Len(t, metrics[0].Data, 1)
+
+	// Test 2: Upserting an existing workout replaces it
+	updated := newWorkout("b", "Pool Swim", "2024-01-08 07:00:00 +0000")
+	updated.Duration = 2400
+	store.UpsertWorkouts([]models.Workout{updated})
+	assert.Len(t, store.Workouts(data.AllTime), 3)
+	assert.Equal(t, 2400.0, store.Workouts(r)[0].Duration)
+
+	// Test 3: Deleting removes the workout by key
+	assert.True(t, store.DeleteWorkout("a"))
+	assert.False(t, store.DeleteWorkout("a"))
+	assert.Len(t, store.Snapshot().Data.Workouts, 2)
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	// Save a store with a workout and a manifest entry
+	store := data.NewFileStore(path, data.MergeNewest)
+	assert.NoError(t, store.Load())
+	store.UpsertWorkouts([]models.Workout{newWorkout("a", "Outdoor Run", "2024-01-01 07:00:00 +0000")})
+	store.RecordFile(models.ManifestEntry{Path: "/exports/a.json", Status: models.FileIngested})
+	assert.True(t, store.Changed())
+	assert.NoError(t, store.Save())
+	assert.False(t, store.Changed())
+
+	// Test 1: A fresh store reads back the same data
+	reloaded := data.NewFileStore(path, data.MergeNewest)
+	assert.NoError(t, reloaded.Load())
+	assert.Len(t, reloaded.Workouts(data.AllTime), 1)
+	assert.Contains(t, reloaded.Manifest(), "/exports/a.json")
+	assert.False(t, reloaded.Changed())
+}
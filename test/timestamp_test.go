@@ -0,0 +1,64 @@
+// test/timestamp_test.go
+
+package test
+
+import (
+	"encoding/json"
+	"fitness/data"
+	"fitness/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	// Test 1: Health Auto Export, RFC3339 and date-only values are accepted
+	hae, err := models.ParseTimestamp("2024-03-01 07:30:00 -0500")
+	assert.NoError(t, err)
+	rfc, err := models.ParseTimestamp("2024-03-01T12:30:00Z")
+	assert.NoError(t, err)
+	assert.True(t, hae.Time.Equal(rfc.Time))
+	assert.Equal(t, hae.Key(), rfc.Key(), "Expected the same instant to have the same key.")
+
+	day, err := models.ParseTimestamp("2024-03-01")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, day.Time.Day())
+	assert.Equal(t, 0, day.Time.Hour())
+
+	// Test 2: Unrecognized values keep their text and report an error
+	bad, err := models.ParseTimestamp("yesterday")
+	assert.Error(t, err)
+	assert.False(t, bad.Valid())
+	assert.Error(t, bad.Err())
+	assert.Equal(t, "yesterday", bad.String())
+
+	// Test 3: Timestamps sort chronologically, unparseable ones last
+	assert.True(t, rfc.Before(ts("2024-03-02")))
+	assert.True(t, rfc.Before(bad))
+	assert.False(t, bad.Before(rfc))
+}
+
+func TestTimestampJSON(t *testing.T) {
+	input := `{"date":"2024-03-01 00:00:00 -0500","qty":1}`
+
+	// Test 1: The original text is written back unchanged
+	var point models.MetricData
+	assert.NoError(t, json.Unmarshal([]byte(input), &point))
+	assert.Equal(t, time.March, point.Date.Time.Month())
+	output, err := json.Marshal(point)
+	assert.NoError(t, err)
+	assert.JSONEq(t, input, string(output))
+
+	// Test 2: An unreadable date does not fail the record but is reported as a warning
+	var health models.HealthData
+	content := `{"data":{"workouts":[{"name":"Outdoor Run","start":"not a time","end":""}],
+		"metrics":[{"name":"step_count","data":[{"date":"2024-03-01T00:00:00Z","qty":5}]}]}}`
+	assert.NoError(t, json.Unmarshal([]byte(content), &health))
+	warnings := data.TimestampWarnings(&health)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "Outdoor Run")
+
+	// Test 3: Values that are not strings are rejected
+	assert.Error(t, json.Unmarshal([]byte(`{"date":42}`), &point))
+}
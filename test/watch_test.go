@@ -0,0 +1,92 @@
+// test/watch_test.go
+
+package test
+
+import (
+	"context"
+	"fitness/data"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	store := data.NewMemoryStore(data.MergeNewest)
+	watcher := data.NewWatcher(store, dir)
+	watcher.Settle = time.Hour
+
+	export := `{"data": {"workouts": [{"id": "a", "name": "Outdoor Run", "start": "2024-01-01 07:00:00 -0500", "duration": 1800}],
+		"metrics": [{"name": "step_count", "units": "count", "data": [{"date": "2024-01-01 00:00:00 -0500", "qty": 9000}]}]}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "HealthAutoExport-2024-01-01.json"), []byte(export), 0644))
+
+	// Test 1: A file that was just written is held back until it stops changing
+	summary, err := watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, summary.Files)
+	assert.Equal(t, 1, summary.Waiting)
+	assert.Empty(t, store.Workouts(data.AllTime))
+
+	// Test 2: The file is ingested once it is unchanged since the previous poll
+	summary, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Files)
+	assert.Equal(t, 1, summary.Workouts)
+	assert.Equal(t, 1, summary.Metrics)
+	assert.Len(t, store.Workouts(data.AllTime), 1)
+
+	// Test 3: Nothing is added once the directory is fully ingested
+	summary, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, data.WatchSummary{}, summary)
+}
+
+func TestWatcherRun(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "icloud")
+	store := data.NewMemoryStore(data.MergeNewest)
+	watcher := data.NewWatcher(store, dir)
+	watcher.Interval = 10 * time.Millisecond
+	watcher.Settle = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	failed := make(chan error, 100)
+	reported := make(chan data.WatchSummary, 100)
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx, func(summary data.WatchSummary) { reported <- summary }, func(err error) { failed <- err })
+		close(done)
+	}()
+
+	// Test 1: A directory that cannot be scanned is reported without ending the watch
+	select {
+	case err := <-failed:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan error was not reported")
+	}
+
+	// Test 2: Files are ingested once the directory appears
+	export := `{"data": {"workouts": [{"id": "a", "name": "Outdoor Run", "start": "2024-01-01 07:00:00 -0500", "duration": 1800}], "metrics": []}}`
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "HealthAutoExport-2024-01-01.json"), []byte(export), 0644))
+	select {
+	case summary := <-reported:
+		assert.Equal(t, 1, summary.Workouts)
+	case <-time.After(5 * time.Second):
+		t.Fatal("file was not ingested")
+	}
+
+	// Test 3: Run returns once the context is cancelled
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop")
+	}
+}
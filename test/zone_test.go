@@ -0,0 +1,46 @@
+// test/zone_test.go
+
+package test
+
+import (
+	"fitness/data"
+	"fitness/models"
+	"fitness/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZonePolicy(t *testing.T) {
+	// A late evening workout logged on the US west coast
+	workouts := []models.Workout{{Name: "Outdoor Run", Start: ts("2024-01-31 23:30:00 -0800")}}
+	utc, err := models.ParseZone("utc")
+	assert.NoError(t, err)
+	tokyo, err := models.ParseZone("Asia/Tokyo")
+	assert.NoError(t, err)
+
+	// Test 1: The original policy keeps the workout on the day it happened locally
+	month, _ := utils.Bucketer{Period: utils.Month}.Bucket(workouts[0].Start)
+	assert.Equal(t, "2024-01", month.Label)
+	assert.Equal(t, "2024-01-31 23:30", models.Zone{}.Format(workouts[0].Start, "2006-01-02 15:04"))
+
+	// Test 2: Converting to another zone moves it to that zone's day
+	month, _ = utils.Bucketer{Period: utils.Month, Zone: utc}.Bucket(workouts[0].Start)
+	assert.Equal(t, "2024-02", month.Label)
+	assert.Equal(t, "2024-02-01 16:30", tokyo.Format(workouts[0].Start, "2006-01-02 15:04"))
+
+	// Test 3: Date filters compare calendar days in the zone
+	filtered, ok := data.FilterDate(workouts, "2024-02-01", true, models.Zone{})
+	assert.False(t, ok)
+	assert.Empty(t, filtered)
+	filtered, ok = data.FilterDate(workouts, "2024-02-01", true, utc)
+	assert.True(t, ok)
+	assert.Len(t, filtered, 1)
+	filtered, ok = data.FilterDate(workouts, "2024-01-31", false, models.Zone{})
+	assert.True(t, ok)
+	assert.Len(t, filtered, 1)
+
+	// Test 4: Unknown zones are rejected
+	_, err = models.ParseZone("Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
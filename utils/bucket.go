@@ -0,0 +1,145 @@
+// utils/bucket.go
+package utils
+
+import (
+	"fitness/config"
+	"fitness/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is the length of the calendar buckets used by aggregations
+type Period string
+
+const (
+	Day     Period = "day"     // Calendar days, labelled 2024-01-05
+	Week    Period = "week"    // Weeks starting on Bucketer.WeekStart, labelled 2024-01-01..2024-01-07
+	ISOWeek Period = "isoweek" // ISO 8601 weeks starting on Monday, labelled 2024-W01
+	Month   Period = "month"   // Calendar months, labelled 2024-01
+	Quarter Period = "quarter" // Calendar quarters, labelled 2024-Q1
+	Year    Period = "year"    // Calendar years, labelled 2024
+	Rolling Period = "rolling" // Windows of Bucketer.Days days ending on Bucketer.Anchor, labelled 2024-01-01..2024-01-07
+)
+
+// Bucketer places timestamps into calendar buckets
+// Labels sort chronologically, so sorting a map's keys orders the buckets by time
+type Bucketer struct {
+	Period    Period       // Length of the buckets
+	WeekStart time.Weekday // First day of Week buckets
+	Days      int          // Length of Rolling windows in days
+	Anchor    time.Time    // Last day of the most recent Rolling window, the zero time aligns windows to 1970-01-01
+	Zone      models.Zone  // Time zone policy deciding which day a timestamp falls on
+}
+
+// Bucket is a single calendar bucket
+type Bucket struct {
+	Label string    // Unambiguous, chronologically sortable name of the bucket
+	Start time.Time // First instant of the bucket
+	End   time.Time // First instant after the bucket
+}
+
+// ParsePeriod reads a bucket period: day, week, isoweek, month, quarter, year or a rolling window such as 7d
+func ParsePeriod(spec string) (Bucketer, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	switch Period(spec) {
+	case Day, Month, Quarter, Year, ISOWeek:
+		return Bucketer{Period: Period(spec)}, nil
+	case Week:
+		return Bucketer{Period: Week, WeekStart: time.Monday}, nil
+	}
+	if days, err := strconv.Atoi(strings.TrimSuffix(spec, "d")); err == nil && strings.HasSuffix(spec, "d") && days > 0 {
+		return Bucketer{Period: Rolling, Days: days}, nil
+	}
+	return Bucketer{}, fmt.Errorf("unknown period %q (expected day, week, isoweek, month, quarter, year or a number of days such as 7d)", spec)
+}
+
+// ParseWeekStart builds the weekly bucketer for a first day of the week, or ISO weeks for "iso"
+func ParseWeekStart(name string) (Bucketer, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "iso" {
+		return Bucketer{Period: ISOWeek}, nil
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if name == strings.ToLower(day.String()) {
+			return Bucketer{Period: Week, WeekStart: day}, nil
+		}
+	}
+	return Bucketer{}, fmt.Errorf("unknown week start %q (expected a weekday or iso)", name)
+}
+
+// Bucket returns the bucket a timestamp falls in, false if it has no valid time
+func (b Bucketer) Bucket(ts models.Timestamp) (Bucket, bool) {
+	if !ts.Valid() {
+		return Bucket{}, false
+	}
+	return b.BucketOf(b.Zone.Time(ts)), true
+}
+
+// BucketOf returns the bucket of a time that is already in the zone it should be bucketed in
+func (b Bucketer) BucketOf(t time.Time) Bucket {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch b.Period {
+	case Week:
+		offset := (int(day.Weekday()) - int(b.WeekStart) + 7) % 7
+		return spanBucket(day.AddDate(0, 0, -offset), 7)
+	case ISOWeek:
+		year, week := day.ISOWeek()
+		offset := (int(day.Weekday()) + 6) % 7
+		start := day.AddDate(0, 0, -offset)
+		return Bucket{Label: fmt.Sprintf("%04d-W%02d", year, week), Start: start, End: start.AddDate(0, 0, 7)}
+	case Month:
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		return Bucket{Label: start.Format("2006-01"), Start: start, End: start.AddDate(0, 1, 0)}
+	case Quarter:
+		quarter := (int(day.Month()) - 1) / 3
+		start := time.Date(day.Year(), time.Month(quarter*3+1), 1, 0, 0, 0, 0, day.Location())
+		return Bucket{Label: fmt.Sprintf("%04d-Q%d", day.Year(), quarter+1), Start: start, End: start.AddDate(0, 3, 0)}
+	case Year:
+		start := time.Date(day.Year(), 1, 1, 0, 0, 0, 0, day.Location())
+		return Bucket{Label: start.Format("2006"), Start: start, End: start.AddDate(1, 0, 0)}
+	case Rolling:
+		days := b.Days
+		if days < 1 {
+			days = 1
+		}
+		// Count windows back from the anchor day, windows after the anchor continue the sequence
+		behind := daysBetween(day, b.Anchor)
+		window := floorDiv(behind, days)
+		last := day.AddDate(0, 0, behind-window*days)
+		return spanBucket(last.AddDate(0, 0, -days+1), days)
+	default:
+		return Bucket{Label: day.Format(config.DateFormat), Start: day, End: day.AddDate(0, 0, 1)}
+	}
+}
+
+// spanBucket is a bucket of several days labelled with its first and last day
+func spanBucket(start time.Time, days int) Bucket {
+	end := start.AddDate(0, 0, days)
+	return Bucket{
+		Label: start.Format(config.DateFormat) + ".." + end.AddDate(0, 0, -1).Format(config.DateFormat),
+		Start: start,
+		End:   end,
+	}
+}
+
+// daysBetween counts the calendar days from day to the anchor's calendar day
+func daysBetween(day, anchor time.Time) int {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !anchor.IsZero() {
+		to = time.Date(anchor.Year(), anchor.Month(), anchor.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
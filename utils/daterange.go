@@ -0,0 +1,155 @@
+// utils/daterange.go
+package utils
+
+import (
+	"fitness/config"
+	"fitness/models"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateResolver turns the date expressions of --since, --until and --period into spans of time
+type DateResolver struct {
+	Now  func() time.Time // Clock relative expressions resolve against, time.Now if nil
+	Week Bucketer         // Weeks used by this-week and last-week, Monday weeks if unset; its zone decides where days begin
+}
+
+// Patterns of the absolute and relative date expressions
+var (
+	quarterPattern  = regexp.MustCompile(`^(\d{4})-q([1-4])$`)
+	isoWeekPattern  = regexp.MustCompile(`^(\d{4})-w(\d{1,2})$`)
+	relativePattern = regexp.MustCompile(`^(\d+)(d|w|mo|y)$`)
+	namedPattern    = regexp.MustCompile(`^(this|last)-(week|month|quarter|year)$`)
+)
+
+// Time returns the current time of the resolver's clock
+func (r DateResolver) Time() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// today returns midnight of the current day in the resolver's zone
+func (r DateResolver) today() time.Time {
+	now := r.Week.Zone.In(r.Time())
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// Span resolves a date expression to the span of time it names
+// Absolute expressions are 2024, 2024-07, 2024-Q3, 2024-W05, 2024-07-14 or a full timestamp
+// Relative expressions are today, yesterday, ytd, this- or last-week, month, quarter or year,
+// and 7d, 2w, 3mo or 1y for that many days, weeks, months or years up to and including today
+// Months are mo, as m is a minute in -filter and -where
+func (r DateResolver) Span(expr string) (Bucket, error) {
+	raw := strings.TrimSpace(expr)
+	expr = strings.ToLower(raw)
+	today := r.today()
+	tomorrow := today.AddDate(0, 0, 1)
+	loc := today.Location()
+
+	switch expr {
+	case "today":
+		return Bucket{Label: expr, Start: today, End: tomorrow}, nil
+	case "yesterday":
+		return Bucket{Label: expr, Start: today.AddDate(0, 0, -1), End: today}, nil
+	case "ytd":
+		return Bucket{Label: expr, Start: time.Date(today.Year(), 1, 1, 0, 0, 0, 0, loc), End: tomorrow}, nil
+	}
+
+	// The current or the previous calendar period
+	if match := namedPattern.FindStringSubmatch(expr); match != nil {
+		b := Bucketer{Period: Period(match[2]), WeekStart: time.Monday}
+		if b.Period == Week && r.Week.Period != "" {
+			b = r.Week
+		}
+		bucket := b.BucketOf(today)
+		if match[1] == "last" {
+			bucket = b.BucketOf(bucket.Start.AddDate(0, 0, -1))
+		}
+		return bucket, nil
+	}
+
+	// A number of days, weeks, months or years up to and including today
+	if match := relativePattern.FindStringSubmatch(expr); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		if n < 1 {
+			return Bucket{}, fmt.Errorf("invalid date %q (the number must be at least 1)", expr)
+		}
+		start := map[string]time.Time{
+			"d":  tomorrow.AddDate(0, 0, -n),
+			"w":  tomorrow.AddDate(0, 0, -7*n),
+			"mo": tomorrow.AddDate(0, -n, 0),
+			"y":  tomorrow.AddDate(-n, 0, 0),
+		}[match[2]]
+		return Bucket{Label: expr, Start: start, End: tomorrow}, nil
+	}
+
+	// A bare m would be minutes anywhere else, so months have to be spelled out
+	if strings.HasSuffix(expr, "m") && relativePattern.MatchString(expr+"o") {
+		return Bucket{}, fmt.Errorf("invalid date %q (write %so for months)", expr, expr)
+	}
+
+	// Calendar periods by their label
+	if match := quarterPattern.FindStringSubmatch(expr); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		quarter, _ := strconv.Atoi(match[2])
+		return Bucketer{Period: Quarter}.BucketOf(time.Date(year, time.Month(quarter*3), 1, 0, 0, 0, 0, loc)), nil
+	}
+	if match := isoWeekPattern.FindStringSubmatch(expr); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		week, _ := strconv.Atoi(match[2])
+		// January 4th is always in the first ISO week of its year
+		bucket := Bucketer{Period: ISOWeek}.BucketOf(time.Date(year, 1, 4, 0, 0, 0, 0, loc).AddDate(0, 0, 7*(week-1)))
+		if week < 1 || week > 53 || !strings.HasSuffix(bucket.Label, fmt.Sprintf("-W%02d", week)) {
+			return Bucket{}, fmt.Errorf("invalid date %q (%d has no week %d)", expr, year, week)
+		}
+		return bucket, nil
+	}
+	for layout, period := range map[string]Period{"2006": Year, "2006-01": Month, "2006-01-02": Day} {
+		if t, err := time.ParseInLocation(layout, expr, loc); err == nil {
+			return Bucketer{Period: period}.BucketOf(t), nil
+		}
+	}
+	// A timestamp names a single instant, which its span includes
+	if ts, err := models.ParseTimestamp(raw); err == nil {
+		return Bucket{Label: raw, Start: ts.Time, End: ts.Time.Add(time.Nanosecond)}, nil
+	}
+
+	return Bucket{}, fmt.Errorf("invalid date %q (expected a date such as 2024-07-14, 2024-07, 2024-Q3 or 2024-W05, "+
+		"or a relative date such as 7d, today, last-week, this-month or ytd)", expr)
+}
+
+// Range resolves --since, --until and --period into the start and end of the selected time
+// The period selects its whole span, since and until override its start and end, and an
+// unset side is left as the zero time. The end is exclusive, so --until 2024-07 includes July
+func (r DateResolver) Range(since, until, period string) (from, to time.Time, err error) {
+	if period != "" {
+		span, err := r.Span(period)
+		if err != nil {
+			return from, to, err
+		}
+		from, to = span.Start, span.End
+	}
+	if since != "" {
+		span, err := r.Span(since)
+		if err != nil {
+			return from, to, err
+		}
+		from = span.Start
+	}
+	if until != "" {
+		span, err := r.Span(until)
+		if err != nil {
+			return from, to, err
+		}
+		to = span.End
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, fmt.Errorf("the date range is empty (it starts on %s and ends before %s)", from.Format(config.DateFormat), to.Format(config.DateFormat))
+	}
+	return from, to, nil
+}